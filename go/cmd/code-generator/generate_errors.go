@@ -28,7 +28,7 @@ type Data struct {
 var lexingErrors = Data{
 	Package:   "lexer",
 	ErrorKind: "LexingError",
-	Imports:   []string{"fmt"},
+	Imports:   []string{"fmt", "github.com/fpotier/lox/go/pkg/loxerror"},
 	Types: []ErrorType{
 		{
 			Name: "UnexpectedCharacter",
@@ -42,6 +42,20 @@ var lexingErrors = Data{
 			Fields:  []Field{},
 			Message: "Unterminated string literal",
 		},
+		{
+			Name: "InvalidEscapeSequence",
+			Fields: []Field{
+				{Name: "sequence", Type: "string"},
+			},
+			Message: "Invalid escape sequence '%s'",
+		},
+		{
+			Name: "InvalidCodePoint",
+			Fields: []Field{
+				{Name: "sequence", Type: "string"},
+			},
+			Message: "Invalid unicode code point '%s'",
+		},
 		{
 			Name: "InvalidFloat",
 			Fields: []Field{
@@ -55,7 +69,7 @@ var lexingErrors = Data{
 var runtimeErrors = Data{
 	Package:   "runtime",
 	ErrorKind: "RuntimeError",
-	Imports:   []string{"fmt"},
+	Imports:   []string{"fmt", "github.com/fpotier/lox/go/pkg/loxerror"},
 	Types: []ErrorType{
 		{
 			Name: "UndefinedVariable",
@@ -64,6 +78,13 @@ var runtimeErrors = Data{
 			},
 			Message: "Undefined variable '%s'",
 		},
+		{
+			Name: "InvalidStringConversion",
+			Fields: []Field{
+				{Name: "class", Type: "string"},
+			},
+			Message: "Method '__str__' of class '%s' must return a string",
+		},
 		{
 			Name: "UndefinedProperty",
 			Fields: []Field{
@@ -105,6 +126,20 @@ var runtimeErrors = Data{
 			},
 			Message: "Can't return from %s",
 		},
+		{
+			Name: "InvalidLoopControl",
+			Fields: []Field{
+				{Name: "keyword", Type: "string"},
+			},
+			Message: "Can't use '%s' outside of a loop",
+		},
+		{
+			Name: "UndefinedLabel",
+			Fields: []Field{
+				{Name: "label", Type: "string"},
+			},
+			Message: "Undefined loop label '%s'",
+		},
 		{
 			Name: "VariableRedeclaration",
 			Fields: []Field{
@@ -148,6 +183,224 @@ var runtimeErrors = Data{
 			},
 			Message: "Function '%s' expected %d arguments but got %d",
 		},
+		{
+			Name:    "StackOverflow",
+			Fields:  []Field{},
+			Message: "Stack overflow",
+		},
+		{
+			Name: "ExecutionInterrupted",
+			Fields: []Field{
+				{Name: "reason", Type: "string"},
+			},
+			Message: "Execution interrupted: %s",
+		},
+		{
+			Name: "StepBudgetExceeded",
+			Fields: []Field{
+				{Name: "budget", Type: "int"},
+			},
+			Message: "Step budget of %d exceeded",
+		},
+		{
+			Name: "NativeFunctionError",
+			Fields: []Field{
+				{Name: "functionName", Type: "string"},
+				{Name: "errorMsg", Type: "string"},
+			},
+			Message: "Native function '%s' failed: %s",
+		},
+		{
+			Name: "InvalidHostProperty",
+			Fields: []Field{
+				{Name: "propertyName", Type: "string"},
+				{Name: "errorMsg", Type: "string"},
+			},
+			Message: "Invalid property '%s': %s",
+		},
+		{
+			Name: "NotIndexable",
+			Fields: []Field{
+				{Name: "kind", Type: "string"},
+			},
+			Message: "Can't index a value of type '%s'",
+		},
+		{
+			Name: "NotIterable",
+			Fields: []Field{
+				{Name: "kind", Type: "string"},
+			},
+			Message: "Can't iterate over a value of type '%s'",
+		},
+		{
+			Name: "InvalidIterator",
+			Fields: []Field{
+				{Name: "kind", Type: "string"},
+			},
+			Message: "Method 'iter' must return an iterable or an object with 'done' and 'next', got '%s'",
+		},
+		{
+			Name: "InvalidIndex",
+			Fields: []Field{
+				{Name: "index", Type: "string"},
+			},
+			Message: "List index must be an integer, got '%s'",
+		},
+		{
+			Name: "IndexOutOfRange",
+			Fields: []Field{
+				{Name: "index", Type: "int"},
+				{Name: "length", Type: "int"},
+			},
+			Message: "Index %d out of range for a list of length %d",
+		},
+		{
+			Name: "InvalidSliceBounds",
+			Fields: []Field{
+				{Name: "start", Type: "int"},
+				{Name: "end", Type: "int"},
+				{Name: "length", Type: "int"},
+			},
+			Message: "Invalid slice bounds [%d:%d] for a list of length %d",
+		},
+		{
+			Name:    "EmptyList",
+			Fields:  []Field{},
+			Message: "Can't pop from an empty list",
+		},
+		{
+			Name: "UnhashableKey",
+			Fields: []Field{
+				{Name: "kind", Type: "string"},
+			},
+			Message: "Map keys must be strings, numbers, booleans or nil, got '%s'",
+		},
+		{
+			Name: "UndefinedKey",
+			Fields: []Field{
+				{Name: "key", Type: "string"},
+			},
+			Message: "Undefined key '%s'",
+		},
+		{
+			Name: "ModuleNotFound",
+			Fields: []Field{
+				{Name: "path", Type: "string"},
+			},
+			Message: "Module '%s' not found",
+		},
+		{
+			Name: "CircularImport",
+			Fields: []Field{
+				{Name: "path", Type: "string"},
+			},
+			Message: "Circular import of module '%s'",
+		},
+		{
+			Name: "UndefinedExport",
+			Fields: []Field{
+				{Name: "name", Type: "string"},
+				{Name: "module", Type: "string"},
+			},
+			Message: "Undefined variable '%s' in module '%s'",
+		},
+		{
+			Name: "ImmutableModule",
+			Fields: []Field{
+				{Name: "name", Type: "string"},
+				{Name: "module", Type: "string"},
+			},
+			Message: "Can't assign '%s' of module '%s'",
+		},
+	},
+}
+
+var compileErrors = Data{
+	Package:   "vm",
+	ErrorKind: "CompileError",
+	Imports:   []string{"fmt", "github.com/fpotier/lox/go/pkg/loxerror"},
+	Types: []ErrorType{
+		{
+			Name:    "TooManyConstants",
+			Fields:  []Field{},
+			Message: "Too many constants in one chunk",
+		},
+		{
+			Name:    "TooManyLocals",
+			Fields:  []Field{},
+			Message: "Too many local variables in function",
+		},
+		{
+			Name:    "TooManyUpvalues",
+			Fields:  []Field{},
+			Message: "Too many closure variables in function",
+		},
+		{
+			Name:    "JumpTooLarge",
+			Fields:  []Field{},
+			Message: "Too much code to jump over",
+		},
+		{
+			Name:    "LoopTooLarge",
+			Fields:  []Field{},
+			Message: "Loop body too large",
+		},
+		{
+			Name: "TooManyElements",
+			Fields: []Field{
+				{Name: "literal", Type: "string"},
+			},
+			Message: "Too many elements in a %s literal",
+		},
+	},
+}
+
+var typeErrors = Data{
+	Package:   "checker",
+	ErrorKind: "TypeError",
+	Imports:   []string{"fmt", "github.com/fpotier/lox/go/pkg/loxerror"},
+	Types: []ErrorType{
+		{
+			Name: "UnknownType",
+			Fields: []Field{
+				{Name: "name", Type: "string"},
+			},
+			Message: "Unknown type '%s'",
+		},
+		{
+			Name: "TypeMismatch",
+			Fields: []Field{
+				{Name: "target", Type: "string"},
+				{Name: "expected", Type: "string"},
+				{Name: "actual", Type: "string"},
+			},
+			Message: "Type mismatch for %s: expected '%s' but got '%s'",
+		},
+		{
+			Name: "IncompatibleOperands",
+			Fields: []Field{
+				{Name: "operator", Type: "string"},
+				{Name: "lhsType", Type: "string"},
+				{Name: "rhsType", Type: "string"},
+			},
+			Message: "Operator '%s': incompatible types '%s' and '%s'",
+		},
+		{
+			Name: "IncompatibleOperand",
+			Fields: []Field{
+				{Name: "operator", Type: "string"},
+				{Name: "operandType", Type: "string"},
+			},
+			Message: "Operator '%s': incompatible type '%s'",
+		},
+		{
+			Name: "MissingReturn",
+			Fields: []Field{
+				{Name: "function", Type: "string"},
+				{Name: "returnType", Type: "string"},
+			},
+			Message: "Function '%s' may end without returning a value of type '%s'",
+		},
 	},
 }
 
@@ -169,6 +422,10 @@ func main() {
 		data = lexingErrors
 	case "runtime":
 		data = runtimeErrors
+	case "vm":
+		data = compileErrors
+	case "checker":
+		data = typeErrors
 	default:
 		fmt.Fprintf(os.Stderr, "Unknown argument %s", os.Args[1])
 		return
@@ -1,129 +1,284 @@
 package main
 
 import (
-	"bufio"
+	"errors"
+	"flag"
 	"fmt"
 	"io"
 	"log"
+	"net"
 	"os"
+	"path/filepath"
+	"strings"
 
-	"github.com/fpotier/lox/go/pkg/lexer"
+	"github.com/fpotier/lox/go/pkg/debug"
+	"github.com/fpotier/lox/go/pkg/format"
+	"github.com/fpotier/lox/go/pkg/lox"
 	"github.com/fpotier/lox/go/pkg/loxerror"
-	"github.com/fpotier/lox/go/pkg/parser"
-	"github.com/fpotier/lox/go/pkg/runtime"
+	"github.com/fpotier/lox/go/pkg/lsp"
+	"github.com/fpotier/lox/go/pkg/repl"
+	"github.com/pkg/diff"
 	"github.com/sean-/sysexits"
 )
 
 type Lox struct {
-	hadError       bool
-	lexer          *lexer.Lexer
-	parser         *parser.Parser
-	resolver       *runtime.Resolver
-	interpreter    *runtime.Interpreter
-	stdout         io.Writer
-	stderr         io.Writer
-	errorFormatter loxerror.ErrorFormatter
+	engine *lox.Engine
+	stderr io.Writer
 }
 
-func NewLox(fds ...io.Writer) *Lox {
-	lox := Lox{
-		hadError:       false,
-		lexer:          nil,
-		parser:         nil,
-		resolver:       nil,
-		interpreter:    nil,
-		stdout:         os.Stdout,
-		stderr:         os.Stderr,
-		errorFormatter: loxerror.NewJSONErrorFormatter(),
-	}
-	for i, fd := range fds {
-		switch i {
-		case 0:
-			lox.stdout = fd
-		case 1:
-			lox.stderr = fd
-		}
+func NewLox(stdout io.Writer, stderr io.Writer, options ...lox.Option) *Lox {
+	defaultOptions := []lox.Option{
+		lox.WithStdout(stdout),
+		lox.WithErrorFormatter(loxerror.NewJSONErrorFormatter()),
 	}
-	lox.interpreter = runtime.NewInterpreter(lox.stdout, lox.errorFormatter)
 
-	return &lox
+	return &Lox{
+		engine: lox.NewEngine(append(defaultOptions, options...)...),
+		stderr: stderr,
+	}
 }
 
+// RunPrompt evaluates the inputs of the standard input, the lines typed are saved in ~/.glox_history
 func (l *Lox) RunPrompt() {
-	scanner := bufio.NewScanner(os.Stdin)
-	for {
-		fmt.Print("lox> ")
-		scanner.Scan()
-		if err := scanner.Err(); err != nil {
-			log.Fatal(err)
-		}
-		if input := scanner.Text(); input == "exit" || len(input) == 0 {
-			break
-		}
-		l.run(scanner.Text())
-		l.hadError = false
+	prompt := repl.New(l.engine, os.Stdin, os.Stdout, l.stderr)
+	if home, err := os.UserHomeDir(); err == nil {
+		prompt.HistoryFile = filepath.Join(home, ".glox_history")
+	}
+	if err := prompt.Run(); err != nil {
+		log.Fatal(err)
 	}
 }
 
 func (l *Lox) RunFile(filepath string) int {
-	sourceCode, err := os.ReadFile(filepath)
-	if err != nil {
-		log.Fatal(err)
-	}
-	l.run(string(sourceCode))
+	return l.report(l.engine.EvalFile(filepath))
+}
+
+// run evaluates the source code, prints the errors and returns the matching exit code
+func (l *Lox) run(sourceCode string) int {
+	return l.report(l.engine.Eval(sourceCode))
+}
 
-	if l.hadError {
+// report prints the errors of an evaluation and returns the matching exit code
+func (l *Lox) report(err error) int {
+	var loxError *lox.Error
+	switch {
+	case err == nil:
+		return 0
+	case errors.As(err, &loxError):
+		l.PrintAll(loxError)
+		if loxError.Runtime {
+			return sysexits.Software
+		}
 		return sysexits.DataErr
+	default:
+		fmt.Fprintln(l.stderr, err)
+		return sysexits.Software
+	}
+}
+
+func (l *Lox) PrintAll(loxError *lox.Error) {
+	for _, e := range loxError.Errors {
+		fmt.Fprint(l.stderr, l.engine.ErrorFormatter().Format(e))
+	}
+}
+
+// runLanguageServer answers the requests of an editor on the standard streams until it exits
+func runLanguageServer() int {
+	if err := lsp.NewServer(os.Stdin, os.Stdout).Run(); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return sysexits.Software
 	}
 
 	return 0
 }
 
-func (l *Lox) run(sourceCode string) {
-	// TODO: avoid to recreate all components each time
-	l.lexer = lexer.NewLexer(l.errorFormatter, sourceCode)
-	tokens := l.lexer.Tokens()
-	l.PrintAll()
+// runDebugger debugs a script from the terminal, or serves a client of the Debug Adapter Protocol with -dap
+func runDebugger(args []string) int {
+	flags := flag.NewFlagSet("debug", flag.ExitOnError)
+	dap := flags.Bool("dap", false, "serve a Debug Adapter Protocol client instead of reading commands from the terminal")
+	listen := flags.String("listen", "", "with -dap, accept a client on this TCP address (e.g. 127.0.0.1:4711) instead of the standard streams")
+	flags.Usage = func() {
+		fmt.Fprintln(flags.Output(), "Usage: glox debug script")
+		fmt.Fprintln(flags.Output(), "       glox debug -dap [-listen address]")
+		flags.PrintDefaults()
+	}
+	_ = flags.Parse(args)
+
+	if *dap {
+		var reader io.Reader = os.Stdin
+		var writer io.Writer = os.Stdout
+		if *listen != "" {
+			listener, err := net.Listen("tcp", *listen)
+			if err != nil {
+				fmt.Fprintln(os.Stderr, err)
+				return sysexits.Unavailable
+			}
+			defer listener.Close()
+			connection, err := listener.Accept()
+			if err != nil {
+				fmt.Fprintln(os.Stderr, err)
+				return sysexits.IOErr
+			}
+			defer connection.Close()
+			reader, writer = connection, connection
+		}
 
-	l.parser = parser.NewParser(l.errorFormatter, tokens)
-	statements := l.parser.Parse()
-	if l.errorFormatter.HasErrors() {
-		l.PrintAll()
-		return
+		// The standard input may carry the requests, the script reads an empty input
+		server := debug.NewDAPServer(reader, writer, lox.WithStdin(strings.NewReader("")))
+		if err := server.Run(); err != nil && !errors.Is(err, io.EOF) {
+			fmt.Fprintln(os.Stderr, err)
+			return sysexits.Software
+		}
+		return 0
 	}
 
-	// printer := ast.NewAstPrinter(os.Stdout, 2)
-	// printer.Dump(statements)
+	if flags.NArg() != 1 {
+		flags.Usage()
+		return sysexits.Usage
+	}
+	glox := NewLox(os.Stdout, os.Stderr, lox.WithErrorFormatter(loxerror.NewTextErrorFormatter(false)))
+	glox.engine.Interpreter().Debugger = debug.NewTerminal(os.Stdin, os.Stdout, flags.Arg(0))
+
+	return glox.RunFile(flags.Arg(0))
+}
 
-	l.resolver = runtime.NewResolver(l.errorFormatter, l.interpreter)
-	l.resolver.ResolveProgram(statements)
-	if l.errorFormatter.HasErrors() {
-		l.PrintAll()
-		return
+// runFormatter prints the scripts in their canonical form, or the standard input without script
+func runFormatter(args []string) int {
+	flags := flag.NewFlagSet("fmt", flag.ExitOnError)
+	write := flags.Bool("w", false, "write the formatted code to the scripts instead of the standard output")
+	showDiff := flags.Bool("d", false, "print the changes as a unified diff instead of the formatted code")
+	flags.Usage = func() {
+		fmt.Fprintln(flags.Output(), "Usage: glox fmt [-w] [-d] [script ...]")
+		flags.PrintDefaults()
+	}
+	_ = flags.Parse(args)
+
+	if flags.NArg() == 0 {
+		if *write {
+			fmt.Fprintln(os.Stderr, "Can't write the standard input")
+			return sysexits.Usage
+		}
+		sourceCode, err := io.ReadAll(os.Stdin)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			return sysexits.IOErr
+		}
+		return formatScript("", string(sourceCode), false, *showDiff)
 	}
 
-	l.interpreter.Eval(statements)
-	l.PrintAll()
+	status := 0
+	for _, file := range flags.Args() {
+		sourceCode, err := os.ReadFile(file)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			status = sysexits.NoInput
+			continue
+		}
+		if code := formatScript(file, string(sourceCode), *write, *showDiff); code != 0 {
+			status = code
+		}
+	}
+
+	return status
 }
 
-func (l *Lox) PrintAll() {
-	for l.errorFormatter.HasErrors() {
-		loxError, _ := l.errorFormatter.PopError()
-		fmt.Fprint(l.stderr, l.errorFormatter.Format(loxError))
+// formatScript formats the source code of a file, it's printed, written back to the file or compared to the original
+func formatScript(file string, sourceCode string, write bool, showDiff bool) int {
+	errorFormatter := loxerror.NewTextErrorFormatter(false)
+	errorFormatter.SetSource(file, sourceCode)
+	formatted, err := format.Source(errorFormatter, file, sourceCode)
+	switch {
+	case errors.Is(err, format.ErrSyntax):
+		for _, e := range errorFormatter.Errors() {
+			fmt.Fprint(os.Stderr, errorFormatter.Format(e))
+		}
+		return sysexits.DataErr
+	case err != nil:
+		fmt.Fprintln(os.Stderr, err)
+		return sysexits.Software
+	}
+
+	if showDiff && formatted != sourceCode {
+		name := file
+		if name == "" {
+			name = "<standard input>"
+		}
+		if err := diff.Text(name+".orig", name, sourceCode, formatted, os.Stdout); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			return sysexits.IOErr
+		}
+	}
+	if write && formatted != sourceCode {
+		info, err := os.Stat(file)
+		if err == nil {
+			err = os.WriteFile(file, []byte(formatted), info.Mode().Perm())
+		}
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			return sysexits.CantCreate
+		}
+	}
+	if !write && !showDiff {
+		fmt.Print(formatted)
 	}
+
+	return 0
 }
 
 func main() {
-	const maxArgs = 2
-	nbArgs := len(os.Args)
-	lox := NewLox(os.Stdout, os.Stderr)
-	switch {
-	case nbArgs > maxArgs:
-		fmt.Println("Usage: glox [script]")
+	if len(os.Args) > 1 {
+		switch os.Args[1] {
+		case "lsp":
+			os.Exit(runLanguageServer())
+		case "debug":
+			os.Exit(runDebugger(os.Args[2:]))
+		case "fmt":
+			os.Exit(runFormatter(os.Args[2:]))
+		}
+	}
+
+	errorFormat := flag.String("format", "json", "format of the error messages: json or text")
+	color := flag.Bool("color", false, "colorize the error messages of the text format")
+	timeout := flag.Duration("timeout", 0, "interrupt the script after this duration (e.g. 500ms, 2s)")
+	maxSteps := flag.Int("max-steps", 0, "interrupt the script after this number of steps (statements and expressions, or VM instructions)")
+	backend := flag.String("backend", "tree", "how the script is evaluated: tree (tree-walking interpreter) or vm (bytecode VM)")
+	flag.Usage = func() {
+		fmt.Fprintln(flag.CommandLine.Output(), "Usage: glox [options] [script]")
+		fmt.Fprintln(flag.CommandLine.Output(), "       glox lsp")
+		fmt.Fprintln(flag.CommandLine.Output(), "       glox debug [-dap] [script]")
+		fmt.Fprintln(flag.CommandLine.Output(), "       glox fmt [-w] [-d] [script ...]")
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+
+	options := []lox.Option{lox.WithTimeout(*timeout), lox.WithStepBudget(*maxSteps)}
+	switch *errorFormat {
+	case "json":
+	case "text":
+		options = append(options, lox.WithErrorFormatter(loxerror.NewTextErrorFormatter(*color)))
+	default:
+		fmt.Fprintf(os.Stderr, "Unknown error format '%s'\n", *errorFormat)
+		flag.Usage()
 		os.Exit(sysexits.Usage)
-	case nbArgs == maxArgs:
-		os.Exit(lox.RunFile(os.Args[1]))
+	}
+	switch *backend {
+	case "tree":
+	case "vm":
+		options = append(options, lox.WithBackend(lox.BytecodeVM))
 	default:
-		lox.RunPrompt()
+		fmt.Fprintf(os.Stderr, "Unknown backend '%s'\n", *backend)
+		flag.Usage()
+		os.Exit(sysexits.Usage)
+	}
+
+	glox := NewLox(os.Stdout, os.Stderr, options...)
+	switch flag.NArg() {
+	case 0:
+		glox.RunPrompt()
+	case 1:
+		os.Exit(glox.RunFile(flag.Arg(0)))
+	default:
+		flag.Usage()
+		os.Exit(sysexits.Usage)
 	}
 }
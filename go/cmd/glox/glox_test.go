@@ -1,17 +1,22 @@
 package main
 
 import (
+	"bytes"
+	"encoding/json"
 	"io"
 	"os"
 	"path/filepath"
 	"regexp"
 	"strings"
 	"testing"
+	"time"
 
+	"github.com/fpotier/lox/go/pkg/lox"
 	"github.com/pkg/diff"
 )
 
 const TestDirectory = "../../../test/official_tests"
+const GloxTestDirectory = "../../../test/glox_tests"
 const BenchmarkDirectory = "../../../benchmark/official_benchmarks"
 
 var testedDirectories = [...]string{
@@ -29,7 +34,7 @@ var testedDirectories = [...]string{
 	"function",
 	"if",
 	"inheritance",
-	// "limit",
+	"limit",
 	"logical_operator",
 	"method",
 	"nil",
@@ -45,30 +50,120 @@ var testedDirectories = [...]string{
 	"while",
 }
 
+// Tests of the features specific to this implementation
+var gloxTestedDirectories = [...]string{
+	"class_member",
+	"exception",
+	"for_in",
+	"lambda",
+	"list",
+	"loop_control",
+	"map",
+	"module",
+	"operator",
+	"span",
+	"string",
+	"stack_trace",
+	"type",
+}
+
+// The limits of the bytecode VM, the tree-walking interpreter doesn't have them
+var vmOnlyFiles = [...]string{
+	"limit/loop_too_large.lox",
+	"limit/no_reuse_constants.lox",
+	"limit/too_many_constants.lox",
+	"limit/too_many_locals.lox",
+	"limit/too_many_upvalues.lox",
+}
+
+var backends = []struct {
+	name    string
+	backend lox.Backend
+}{
+	{"tree", lox.TreeWalker},
+	{"vm", lox.BytecodeVM},
+}
+
 func loxFilesInDir(path string) ([]string, error) {
 	return filepath.Glob(filepath.Join(path, "/*.lox"))
 }
 
 func TestRunFile(t *testing.T) {
 	t.Parallel()
-	for _, dir := range testedDirectories {
-		absolutePath, err := filepath.Abs(TestDirectory + "/" + dir)
+	runTestDirectories(t, TestDirectory, testedDirectories[:], lox.TreeWalker)
+}
+
+func TestRunFileVM(t *testing.T) {
+	t.Parallel()
+	runTestDirectories(t, TestDirectory, testedDirectories[:], lox.BytecodeVM)
+}
+
+func TestRunGloxFile(t *testing.T) {
+	t.Parallel()
+	runTestDirectories(t, GloxTestDirectory, gloxTestedDirectories[:], lox.TreeWalker)
+}
+
+func TestRunGloxFileVM(t *testing.T) {
+	t.Parallel()
+	runTestDirectories(t, GloxTestDirectory, gloxTestedDirectories[:], lox.BytecodeVM)
+}
+
+func TestExecutionLimits(t *testing.T) {
+	t.Parallel()
+	tests := []struct {
+		name     string
+		timeout  time.Duration
+		maxSteps int
+		expected string
+	}{
+		{"timeout", 10 * time.Millisecond, 0, "Execution interrupted: context deadline exceeded"},
+		{"step budget", 0, 1000, "Step budget of 1000 exceeded"},
+	}
+
+	for _, test := range tests {
+		for _, backend := range backends {
+			test, backend := test, backend
+			t.Run(test.name+"/"+backend.name, func(t *testing.T) {
+				t.Parallel()
+				stdoutBuilder := strings.Builder{}
+				stderrBuilder := strings.Builder{}
+				glox := NewLox(&stdoutBuilder, &stderrBuilder,
+					lox.WithBackend(backend.backend), lox.WithTimeout(test.timeout), lox.WithStepBudget(test.maxSteps))
+				glox.run("fun loop() {\n  while (true) {}\n}\nloop();")
+
+				expected := `{"line":2,"message":"` + test.expected + `","type":"RuntimeError"}` + "\n"
+				if actual := projectErrors(stderrBuilder.String(), expected); actual != expected {
+					t.Fatalf("expected %s, got %s", expected, actual)
+				}
+			})
+		}
+	}
+}
+
+func BenchmarkRunFile(b *testing.B) {
+	for _, backend := range backends {
+		b.Run(backend.name, func(b *testing.B) {
+			runFilesInDirBench(b, BenchmarkDirectory, backend.backend)
+		})
+	}
+}
+
+func runTestDirectories(t *testing.T, root string, directories []string, backend lox.Backend) {
+	t.Helper()
+	for _, dir := range directories {
+		absolutePath, err := filepath.Abs(root + "/" + dir)
 		if err != nil {
 			t.Fatal(err.Error())
 		}
 
 		t.Run(dir, func(t *testing.T) {
 			t.Parallel()
-			runFilesInDir(t, absolutePath)
+			runFilesInDir(t, absolutePath, backend)
 		})
 	}
 }
 
-func BenchmarkRunFile(b *testing.B) {
-	runFilesInDirBench(b, BenchmarkDirectory)
-}
-
-func runFilesInDir(t *testing.T, dirPath string) {
+func runFilesInDir(t *testing.T, dirPath string, backend lox.Backend) {
 	t.Helper()
 	loxFiles, err := loxFilesInDir(dirPath)
 	if err != nil || len(loxFiles) == 0 {
@@ -78,8 +173,12 @@ func runFilesInDir(t *testing.T, dirPath string) {
 
 	for _, file := range loxFiles {
 		t.Run(file, func(t *testing.T) {
+			if backend == lox.TreeWalker && isVMOnly(file) {
+				t.Skip("limit of the bytecode VM")
+			}
+
 			diffReport := strings.Builder{}
-			err := checkRunOutput(file, &diffReport)
+			err := checkRunOutput(file, &diffReport, lox.WithBackend(backend))
 			if diffReport.Len() > 0 {
 				t.Fatal(diffReport.String())
 			}
@@ -90,7 +189,17 @@ func runFilesInDir(t *testing.T, dirPath string) {
 	}
 }
 
-func runFilesInDirBench(b *testing.B, dirPath string) {
+func isVMOnly(file string) bool {
+	for _, vmOnlyFile := range vmOnlyFiles {
+		if strings.HasSuffix(filepath.ToSlash(file), vmOnlyFile) {
+			return true
+		}
+	}
+
+	return false
+}
+
+func runFilesInDirBench(b *testing.B, dirPath string, backend lox.Backend) {
 	b.Helper()
 	loxFiles, err := loxFilesInDir(dirPath)
 	if err != nil || len(loxFiles) == 0 {
@@ -105,14 +214,14 @@ func runFilesInDirBench(b *testing.B, dirPath string) {
 					stdoutBuilder = strings.Builder{}
 					stderrBuilder = strings.Builder{}
 				)
-				lox := NewLox(&stdoutBuilder, &stderrBuilder)
-				lox.RunFile(file)
+				glox := NewLox(&stdoutBuilder, &stderrBuilder, lox.WithBackend(backend))
+				glox.RunFile(file)
 			}
 		})
 	}
 }
 
-func checkRunOutput(filename string, diffOutput io.Writer) error {
+func checkRunOutput(filename string, diffOutput io.Writer, options ...lox.Option) error {
 	var (
 		outputPattern = regexp.MustCompile("^.*expect: (.*)$")
 		errorPattern  = regexp.MustCompile("^.*error: (.*)$")
@@ -120,8 +229,8 @@ func checkRunOutput(filename string, diffOutput io.Writer) error {
 		stderrBuilder = strings.Builder{}
 	)
 
-	lox := NewLox(&stdoutBuilder, &stderrBuilder)
-	lox.RunFile(filename)
+	glox := NewLox(&stdoutBuilder, &stderrBuilder, options...)
+	glox.RunFile(filename)
 	programOutput := stdoutBuilder.String()
 	programErr := stderrBuilder.String()
 
@@ -156,6 +265,7 @@ func checkRunOutput(filename string, diffOutput io.Writer) error {
 		}
 	}
 	expectedError := expectedStderrBuilder.String()
+	programErr = projectErrors(programErr, expectedError)
 	if expectedError != programErr {
 		err := diff.Text(filename, filename+".expected", programErr, expectedError, diffOutput)
 		if err != nil {
@@ -165,3 +275,32 @@ func checkRunOutput(filename string, diffOutput io.Writer) error {
 
 	return nil
 }
+
+// projectErrors drops from each reported JSON error the keys its expectation doesn't mention
+// so that test files only have to assert on the fields they care about (line, type, message)
+func projectErrors(actual string, expected string) string {
+	actualLines := strings.SplitAfter(actual, "\n")
+	expectedLines := strings.SplitAfter(expected, "\n")
+	for i := 0; i < len(actualLines) && i < len(expectedLines); i++ {
+		var actualError, expectedError map[string]any
+		if json.Unmarshal([]byte(actualLines[i]), &actualError) != nil ||
+			json.Unmarshal([]byte(expectedLines[i]), &expectedError) != nil {
+			continue
+		}
+
+		for key := range actualError {
+			if _, ok := expectedError[key]; !ok {
+				delete(actualError, key)
+			}
+		}
+
+		var buffer bytes.Buffer
+		encoder := json.NewEncoder(&buffer)
+		encoder.SetEscapeHTML(false)
+		if encoder.Encode(actualError) == nil {
+			actualLines[i] = buffer.String()
+		}
+	}
+
+	return strings.Join(actualLines, "")
+}
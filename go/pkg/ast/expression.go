@@ -25,6 +25,10 @@ func NewCallExpression(callee Expression, position lexer.Token, args []Expressio
 	}
 }
 
+func NewFunctionExpression(function *FunctionStatement) *FunctionExpression {
+	return &FunctionExpression{Function: function}
+}
+
 func NewGetExpression(object Expression, name lexer.Token) *GetExpression {
 	return &GetExpression{Object: object, Name: name}
 }
@@ -35,8 +39,41 @@ func NewGroupingExpression(expr Expression) *GroupingExpression {
 	}
 }
 
-func NewLiteralExpression(value LoxValue) *LiteralExpression {
+func NewIndexGetExpression(object Expression, bracket lexer.Token, index Expression) *IndexGetExpression {
+	return &IndexGetExpression{
+		Object:  object,
+		Bracket: bracket,
+		Index:   index,
+	}
+}
+
+func NewIndexSetExpression(object Expression, bracket lexer.Token, index Expression, value Expression) *IndexSetExpression {
+	return &IndexSetExpression{
+		Object:  object,
+		Bracket: bracket,
+		Index:   index,
+		Value:   value,
+	}
+}
+
+func NewListExpression(bracket lexer.Token, elements []Expression) *ListExpression {
+	return &ListExpression{
+		Bracket:  bracket,
+		Elements: elements,
+	}
+}
+
+func NewMapExpression(brace lexer.Token, keys []Expression, values []Expression) *MapExpression {
+	return &MapExpression{
+		Brace:  brace,
+		Keys:   keys,
+		Values: values,
+	}
+}
+
+func NewLiteralExpression(token lexer.Token, value LoxValue) *LiteralExpression {
 	return &LiteralExpression{
+		Token: token,
 		value: value,
 	}
 }
@@ -57,6 +94,10 @@ func NewSetExpression(object Expression, name lexer.Token, value Expression) *Se
 	}
 }
 
+func NewStringifyExpression(position lexer.Token, value Expression) *StringifyExpression {
+	return &StringifyExpression{Position: position, Value: value}
+}
+
 func NewSuperExpression(keyword lexer.Token, method lexer.Token) *SuperExpression {
 	return &SuperExpression{Keyword: keyword, Method: method}
 }
@@ -99,6 +140,16 @@ type CallExpression struct {
 
 func (e *CallExpression) Accept(visitor Visitor) { visitor.VisitCallExpression(e) }
 
+// AnonymousFunctionName is the name of the functions defined by an expression in the errors and stack traces
+const AnonymousFunctionName = "<anonymous>"
+
+// FunctionExpression is an anonymous function, the name of its declaration is empty and located at 'fun' or '=>'
+type FunctionExpression struct {
+	Function *FunctionStatement
+}
+
+func (e *FunctionExpression) Accept(visitor Visitor) { visitor.VisitFunctionExpression(e) }
+
 type GetExpression struct {
 	Object Expression
 	Name   lexer.Token
@@ -112,7 +163,46 @@ type GroupingExpression struct {
 
 func (e *GroupingExpression) Accept(visitor Visitor) { visitor.VisitGroupingExpression(e) }
 
-type LiteralExpression struct{ value LoxValue }
+type IndexGetExpression struct {
+	Object Expression
+	// Bracket is the opening bracket of the index
+	Bracket lexer.Token
+	Index   Expression
+}
+
+func (e *IndexGetExpression) Accept(visitor Visitor) { visitor.VisitIndexGetExpression(e) }
+
+type IndexSetExpression struct {
+	Object  Expression
+	Bracket lexer.Token
+	Index   Expression
+	Value   Expression
+}
+
+func (e *IndexSetExpression) Accept(visitor Visitor) { visitor.VisitIndexSetExpression(e) }
+
+type ListExpression struct {
+	// Bracket is the opening bracket of the literal
+	Bracket  lexer.Token
+	Elements []Expression
+}
+
+func (e *ListExpression) Accept(visitor Visitor) { visitor.VisitListExpression(e) }
+
+type MapExpression struct {
+	// Brace is the opening brace of the literal
+	Brace lexer.Token
+	// Keys and Values hold the entries in the order they're written
+	Keys   []Expression
+	Values []Expression
+}
+
+func (e *MapExpression) Accept(visitor Visitor) { visitor.VisitMapExpression(e) }
+
+type LiteralExpression struct {
+	Token lexer.Token
+	value LoxValue
+}
 
 func (e *LiteralExpression) LoxValue() LoxValue     { return e.value }
 func (e *LiteralExpression) Accept(visitor Visitor) { visitor.VisitLiteralExpression(e) }
@@ -133,6 +223,15 @@ type SetExpression struct {
 
 func (e *SetExpression) Accept(visitor Visitor) { visitor.VisitSetExpression(e) }
 
+// StringifyExpression converts its value to a string, it's an expression interpolated in a string literal
+type StringifyExpression struct {
+	// Position is the part of the string literal before the expression
+	Position lexer.Token
+	Value    Expression
+}
+
+func (e *StringifyExpression) Accept(visitor Visitor) { visitor.VisitStringifyExpression(e) }
+
 type ThisExpression struct {
 	Keyword lexer.Token
 }
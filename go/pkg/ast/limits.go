@@ -1,7 +1,9 @@
 package ast
 
 var Limits = struct {
-	MaxArgs int
+	MaxArgs      int
+	MaxCallDepth int
 }{
-	MaxArgs: 255,
+	MaxArgs:      255,
+	MaxCallDepth: 10000,
 }
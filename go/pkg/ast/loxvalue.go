@@ -16,6 +16,11 @@ const (
 	Function
 	Class
 	Instance
+	HostObject
+	List
+	Map
+	Module
+	Iterator
 )
 
 var KindString = map[Kind]string{
@@ -27,6 +32,11 @@ var KindString = map[Kind]string{
 	Function:   "function",
 	Class:      "class",
 	Instance:   "instance",
+	HostObject: "host object",
+	List:       "list",
+	Map:        "map",
+	Module:     "module",
+	Iterator:   "iterator",
 }
 
 type LoxValue interface {
@@ -36,6 +46,18 @@ type LoxValue interface {
 	Equals(v LoxValue) bool
 }
 
+// HashKey identifies a value used as a map key, equal values have the same key
+type HashKey struct {
+	kind  Kind
+	value any
+}
+
+// Hashable is implemented by the values that can be used as map keys: booleans, strings, numbers and nil
+type Hashable interface {
+	LoxValue
+	HashKey() HashKey
+}
+
 type BooleanValue struct{ Value bool }
 
 var True = &BooleanValue{Value: true}
@@ -47,9 +69,10 @@ func NewBooleanValue(v bool) *BooleanValue {
 	}
 	return False
 }
-func (b BooleanValue) Kind() Kind     { return Boolean }
-func (b BooleanValue) IsTruthy() bool { return b.Value }
-func (b BooleanValue) String() string { return strconv.FormatBool(b.Value) }
+func (b BooleanValue) Kind() Kind       { return Boolean }
+func (b BooleanValue) IsTruthy() bool   { return b.Value }
+func (b BooleanValue) String() string   { return strconv.FormatBool(b.Value) }
+func (b BooleanValue) HashKey() HashKey { return HashKey{kind: Boolean, value: b.Value} }
 func (b BooleanValue) Equals(v LoxValue) bool {
 	if v, ok := v.(*BooleanValue); ok {
 		return b.Value == v.Value
@@ -63,6 +86,7 @@ func NewStringValue(v string) *StringValue { return &StringValue{Value: v} }
 func (s StringValue) Kind() Kind           { return String }
 func (s StringValue) IsTruthy() bool       { return true }
 func (s StringValue) String() string       { return s.Value }
+func (s StringValue) HashKey() HashKey     { return HashKey{kind: String, value: s.Value} }
 func (s StringValue) Equals(v LoxValue) bool {
 	if v, ok := v.(*StringValue); ok {
 		return s.Value == v.Value
@@ -76,6 +100,7 @@ func NewNumberValue(v float64) *NumberValue { return &NumberValue{Value: v} }
 func (n NumberValue) Kind() Kind            { return Number }
 func (n NumberValue) IsTruthy() bool        { return true }
 func (n NumberValue) String() string        { return fmt.Sprintf("%v", n.Value) }
+func (n NumberValue) HashKey() HashKey      { return HashKey{kind: Number, value: n.Value} }
 func (n NumberValue) Equals(v LoxValue) bool {
 	if v, ok := v.(*NumberValue); ok {
 		return n.Value == v.Value
@@ -87,10 +112,11 @@ type NilValue struct{}
 
 var NilVal = &NilValue{}
 
-func NewNilValue() *NilValue      { return NilVal }
-func (n NilValue) Kind() Kind     { return Nil }
-func (n NilValue) IsTruthy() bool { return false }
-func (n NilValue) String() string { return "nil" }
+func NewNilValue() *NilValue        { return NilVal }
+func (n NilValue) Kind() Kind       { return Nil }
+func (n NilValue) IsTruthy() bool   { return false }
+func (n NilValue) String() string   { return "nil" }
+func (n NilValue) HashKey() HashKey { return HashKey{kind: Nil, value: nil} }
 func (n NilValue) Equals(v LoxValue) bool {
 	if _, ok := v.(*NilValue); ok {
 		return true
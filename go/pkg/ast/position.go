@@ -0,0 +1,85 @@
+package ast
+
+import "github.com/fpotier/lox/go/pkg/lexer"
+
+// StatementPosition returns the first token of a statement kept by the AST, it's nil for a block which only groups
+// statements
+func StatementPosition(s Statement) *lexer.Token {
+	switch s := s.(type) {
+	case *BreakStatement:
+		return &s.Keyword
+	case *ClassStatement:
+		return &s.Name
+	case *ContinueStatement:
+		return &s.Keyword
+	case *ExpressionStatement:
+		return ExpressionPosition(s.Expression)
+	case *ForInStatement:
+		return &s.Keyword
+	case *FunctionStatement:
+		return &s.Name
+	case *IfStatement:
+		return ExpressionPosition(s.Condition)
+	case *ImportStatement:
+		return &s.Keyword
+	case *PrintStatement:
+		return &s.Keyword
+	case *ReturnStatement:
+		return &s.Keyword
+	case *ThrowStatement:
+		return &s.Keyword
+	case *TryStatement:
+		return &s.Keyword
+	case *VariableStatement:
+		return &s.Name
+	case *WhileStatement:
+		return &s.Keyword
+	}
+
+	return nil
+}
+
+// ExpressionPosition returns the first token of an expression kept by the AST,
+// the parentheses of a grouping aren't kept so it's the first token of the grouped expression
+func ExpressionPosition(e Expression) *lexer.Token {
+	switch e := e.(type) {
+	case *AssignmentExpression:
+		return &e.Name
+	case *BinaryExpression:
+		return ExpressionPosition(e.LHS)
+	case *CallExpression:
+		return ExpressionPosition(e.Callee)
+	case *FunctionExpression:
+		return &e.Function.Name
+	case *GetExpression:
+		return ExpressionPosition(e.Object)
+	case *GroupingExpression:
+		return ExpressionPosition(e.Expr)
+	case *IndexGetExpression:
+		return ExpressionPosition(e.Object)
+	case *IndexSetExpression:
+		return ExpressionPosition(e.Object)
+	case *ListExpression:
+		return &e.Bracket
+	case *MapExpression:
+		return &e.Brace
+	case *LiteralExpression:
+		return &e.Token
+	case *LogicalExpression:
+		return ExpressionPosition(e.LHS)
+	case *SetExpression:
+		return ExpressionPosition(e.Object)
+	case *StringifyExpression:
+		return &e.Position
+	case *SuperExpression:
+		return &e.Keyword
+	case *ThisExpression:
+		return &e.Keyword
+	case *UnaryExpression:
+		return &e.Operator
+	case *VariableExpression:
+		return &e.Name
+	}
+
+	return nil
+}
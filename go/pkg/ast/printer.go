@@ -4,6 +4,8 @@ import (
 	"fmt"
 	"io"
 	"strings"
+
+	"github.com/fpotier/lox/go/pkg/lexer"
 )
 
 const DefaultTabSize = 2
@@ -79,6 +81,13 @@ func (astPrinter *Printer) VisitCallExpression(callExpression *CallExpression) {
 	astPrinter.identationLevel--
 }
 
+func (astPrinter *Printer) VisitFunctionExpression(functionExpression *FunctionExpression) {
+	astPrinter.write("FunctionExpression")
+	astPrinter.identationLevel++
+	functionExpression.Function.Accept(astPrinter)
+	astPrinter.identationLevel--
+}
+
 func (astPrinter *Printer) VisitGetExpression(getExpression *GetExpression) {
 	astPrinter.write("GetExpression")
 	astPrinter.identationLevel++
@@ -105,6 +114,71 @@ func (astPrinter *Printer) VisitGroupingExpression(groupingExpression *GroupingE
 	astPrinter.identationLevel--
 }
 
+func (astPrinter *Printer) VisitIndexGetExpression(indexGetExpression *IndexGetExpression) {
+	astPrinter.write("IndexGetExpression")
+	astPrinter.identationLevel++
+
+	astPrinter.write("object:")
+	astPrinter.identationLevel++
+	indexGetExpression.Object.Accept(astPrinter)
+	astPrinter.identationLevel--
+
+	astPrinter.write("index:")
+	astPrinter.identationLevel++
+	indexGetExpression.Index.Accept(astPrinter)
+	astPrinter.identationLevel--
+
+	astPrinter.identationLevel--
+}
+
+func (astPrinter *Printer) VisitIndexSetExpression(indexSetExpression *IndexSetExpression) {
+	astPrinter.write("IndexSetExpression")
+	astPrinter.identationLevel++
+
+	astPrinter.write("object:")
+	astPrinter.identationLevel++
+	indexSetExpression.Object.Accept(astPrinter)
+	astPrinter.identationLevel--
+
+	astPrinter.write("index:")
+	astPrinter.identationLevel++
+	indexSetExpression.Index.Accept(astPrinter)
+	astPrinter.identationLevel--
+
+	astPrinter.write("value:")
+	astPrinter.identationLevel++
+	indexSetExpression.Value.Accept(astPrinter)
+	astPrinter.identationLevel--
+
+	astPrinter.identationLevel--
+}
+
+func (astPrinter *Printer) VisitListExpression(listExpression *ListExpression) {
+	astPrinter.write("ListExpression")
+	astPrinter.identationLevel++
+
+	if len(listExpression.Elements) > 0 {
+		astPrinter.writeExpression("elements", listExpression.Elements)
+	}
+
+	astPrinter.identationLevel--
+}
+
+func (astPrinter *Printer) VisitMapExpression(mapExpression *MapExpression) {
+	astPrinter.write("MapExpression")
+	astPrinter.identationLevel++
+
+	for index, key := range mapExpression.Keys {
+		astPrinter.write("entry:")
+		astPrinter.identationLevel++
+		key.Accept(astPrinter)
+		mapExpression.Values[index].Accept(astPrinter)
+		astPrinter.identationLevel--
+	}
+
+	astPrinter.identationLevel--
+}
+
 func (astPrinter *Printer) VisitLiteralExpression(literalExpression *LiteralExpression) {
 	astPrinter.write("LiteralExpression")
 	astPrinter.identationLevel++
@@ -150,6 +224,18 @@ func (astPrinter *Printer) VisitSetExpression(setExpression *SetExpression) {
 	astPrinter.identationLevel--
 }
 
+func (astPrinter *Printer) VisitStringifyExpression(stringifyExpression *StringifyExpression) {
+	astPrinter.write("StringifyExpression")
+	astPrinter.identationLevel++
+
+	astPrinter.write("value: ")
+	astPrinter.identationLevel++
+	stringifyExpression.Value.Accept(astPrinter)
+	astPrinter.identationLevel--
+
+	astPrinter.identationLevel--
+}
+
 func (astPrinter *Printer) VisitSuperExpression(superExpression *SuperExpression) {
 	astPrinter.write("SuperExpression")
 	astPrinter.identationLevel++
@@ -191,6 +277,10 @@ func (astPrinter *Printer) VisitBlockStatement(blockStatement *BlockStatement) {
 	astPrinter.identationLevel--
 }
 
+func (astPrinter *Printer) VisitBreakStatement(breakStatement *BreakStatement) {
+	astPrinter.writeLoopControl("BreakStatement", breakStatement.Label)
+}
+
 func (astPrinter *Printer) VisitClassStatement(classStatement *ClassStatement) {
 	astPrinter.write("ClassStatement")
 	astPrinter.identationLevel++
@@ -221,9 +311,40 @@ func (astPrinter *Printer) VisitClassStatement(classStatement *ClassStatement) {
 		astPrinter.identationLevel--
 	}
 
+	if len(classStatement.ClassMethods) > 0 {
+		astPrinter.write("class_methods:")
+		astPrinter.identationLevel++
+		for _, method := range classStatement.ClassMethods {
+			method.Accept(astPrinter)
+		}
+		astPrinter.identationLevel--
+	}
+
+	if len(classStatement.ClassFields) > 0 {
+		astPrinter.write("class_fields:")
+		astPrinter.identationLevel++
+		for _, field := range classStatement.ClassFields {
+			field.Accept(astPrinter)
+		}
+		astPrinter.identationLevel--
+	}
+
+	if len(classStatement.Fields) > 0 {
+		astPrinter.write("fields:")
+		astPrinter.identationLevel++
+		for _, field := range classStatement.Fields {
+			field.Accept(astPrinter)
+		}
+		astPrinter.identationLevel--
+	}
+
 	astPrinter.identationLevel--
 }
 
+func (astPrinter *Printer) VisitContinueStatement(continueStatement *ContinueStatement) {
+	astPrinter.writeLoopControl("ContinueStatement", continueStatement.Label)
+}
+
 func (astPrinter *Printer) VisitExpressionStatement(expressionStatement *ExpressionStatement) {
 	astPrinter.write("ExpressionStatement")
 	astPrinter.identationLevel++
@@ -236,6 +357,29 @@ func (astPrinter *Printer) VisitExpressionStatement(expressionStatement *Express
 	astPrinter.identationLevel--
 }
 
+func (astPrinter *Printer) VisitForInStatement(forInStatement *ForInStatement) {
+	astPrinter.write("ForInStatement")
+	astPrinter.identationLevel++
+
+	if forInStatement.Label != nil {
+		astPrinter.write("label: " + forInStatement.Label.Lexeme)
+	}
+
+	astPrinter.write("name: " + forInStatement.Name.Lexeme)
+
+	astPrinter.write("iterable:")
+	astPrinter.identationLevel++
+	forInStatement.Iterable.Accept(astPrinter)
+	astPrinter.identationLevel--
+
+	astPrinter.write("body:")
+	astPrinter.identationLevel++
+	forInStatement.Body.Accept(astPrinter)
+	astPrinter.identationLevel--
+
+	astPrinter.identationLevel--
+}
+
 func (astPrinter *Printer) VisitFunctionStatement(functionStatement *FunctionStatement) {
 	astPrinter.write("FunctionStatement")
 	astPrinter.identationLevel++
@@ -245,11 +389,15 @@ func (astPrinter *Printer) VisitFunctionStatement(functionStatement *FunctionSta
 	if len(functionStatement.Parameters) > 0 {
 		for i, parameter := range functionStatement.Parameters {
 			astPrinter.identationLevel++
-			astPrinter.write(fmt.Sprintf("%d: %s", i, parameter.Lexeme))
+			astPrinter.write(fmt.Sprintf("%d: %s%s", i, parameter.Lexeme, typeSuffix(functionStatement.ParameterTypes[i])))
 			astPrinter.identationLevel--
 		}
 	}
 
+	if functionStatement.ReturnType != nil {
+		astPrinter.write("return_type: " + functionStatement.ReturnType.Lexeme)
+	}
+
 	astPrinter.writeStatements("body", functionStatement.Body)
 
 	astPrinter.identationLevel--
@@ -279,6 +427,21 @@ func (astPrinter *Printer) VisitIfStatement(ifStatement *IfStatement) {
 	astPrinter.identationLevel--
 }
 
+func (astPrinter *Printer) VisitImportStatement(importStatement *ImportStatement) {
+	astPrinter.write("ImportStatement")
+	astPrinter.identationLevel++
+
+	astPrinter.write("path: " + importStatement.ModulePath())
+	if importStatement.Alias != nil {
+		astPrinter.write("alias: " + importStatement.Alias.Lexeme)
+	}
+	for i, name := range importStatement.Names {
+		astPrinter.write(fmt.Sprintf("%d: %s", i, name.Lexeme))
+	}
+
+	astPrinter.identationLevel--
+}
+
 func (astPrinter *Printer) VisitPrintStatement(printStatement *PrintStatement) {
 	astPrinter.write("PrintStatement")
 	astPrinter.identationLevel++
@@ -305,11 +468,43 @@ func (astPrinter *Printer) VisitReturnStatement(returnStatement *ReturnStatement
 	astPrinter.identationLevel--
 }
 
+func (astPrinter *Printer) VisitThrowStatement(throwStatement *ThrowStatement) {
+	astPrinter.write("ThrowStatement")
+	astPrinter.identationLevel++
+
+	astPrinter.write("value:")
+	astPrinter.identationLevel++
+	throwStatement.Value.Accept(astPrinter)
+	astPrinter.identationLevel--
+
+	astPrinter.identationLevel--
+}
+
+func (astPrinter *Printer) VisitTryStatement(tryStatement *TryStatement) {
+	astPrinter.write("TryStatement")
+	astPrinter.identationLevel++
+
+	astPrinter.writeStatements("body", tryStatement.Body)
+
+	if tryStatement.Catch != nil {
+		astPrinter.write("catch: " + tryStatement.Catch.Name.Lexeme)
+		astPrinter.identationLevel++
+		astPrinter.writeStatements("body", tryStatement.Catch.Body)
+		astPrinter.identationLevel--
+	}
+
+	if tryStatement.Finally != nil {
+		astPrinter.writeStatements("finally", tryStatement.Finally.Statements)
+	}
+
+	astPrinter.identationLevel--
+}
+
 func (astPrinter *Printer) VisitVariableStatement(variableStatement *VariableStatement) {
 	astPrinter.write("VariableStatement")
 	astPrinter.identationLevel++
 
-	astPrinter.write("name: " + variableStatement.Name.Lexeme)
+	astPrinter.write("name: " + variableStatement.Name.Lexeme + typeSuffix(variableStatement.Type))
 
 	if variableStatement.Initializer != nil {
 		astPrinter.write("initializer: ")
@@ -325,11 +520,22 @@ func (astPrinter *Printer) VisitWhileStatement(whileStatement *WhileStatement) {
 	astPrinter.write("WhileStatement")
 	astPrinter.identationLevel++
 
+	if whileStatement.Label != nil {
+		astPrinter.write("label: " + whileStatement.Label.Lexeme)
+	}
+
 	astPrinter.write("condition:")
 	astPrinter.identationLevel++
 	whileStatement.Condition.Accept(astPrinter)
 	astPrinter.identationLevel--
 
+	if whileStatement.Increment != nil {
+		astPrinter.write("increment:")
+		astPrinter.identationLevel++
+		whileStatement.Increment.Accept(astPrinter)
+		astPrinter.identationLevel--
+	}
+
 	astPrinter.write("body:")
 	astPrinter.identationLevel++
 	whileStatement.Body.Accept(astPrinter)
@@ -338,6 +544,15 @@ func (astPrinter *Printer) VisitWhileStatement(whileStatement *WhileStatement) {
 	astPrinter.identationLevel--
 }
 
+func (astPrinter *Printer) writeLoopControl(name string, label *lexer.Token) {
+	astPrinter.write(name)
+	if label != nil {
+		astPrinter.identationLevel++
+		astPrinter.write("label: " + label.Lexeme)
+		astPrinter.identationLevel--
+	}
+}
+
 func (astPrinter *Printer) write(value string) {
 	fmt.Fprintf(astPrinter.output,
 		"%s%s\n",
@@ -373,3 +588,12 @@ func (astPrinter *Printer) writeExpression(name string, expressions []Expression
 		astPrinter.identationLevel--
 	}
 }
+
+// typeSuffix returns the type annotation of a declaration as written after its name
+func typeSuffix(annotation *lexer.Token) string {
+	if annotation == nil {
+		return ""
+	}
+
+	return ": " + annotation.Lexeme
+}
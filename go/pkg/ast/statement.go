@@ -8,11 +8,35 @@ func NewBlockStatement(statements []Statement) *BlockStatement {
 	}
 }
 
-func NewClassStatement(name lexer.Token, superclass *VariableExpression, methods []*FunctionStatement) *ClassStatement {
+func NewBreakStatement(keyword lexer.Token, label *lexer.Token) *BreakStatement {
+	return &BreakStatement{
+		Keyword: keyword,
+		Label:   label,
+	}
+}
+
+func NewClassStatement(
+	name lexer.Token,
+	superclass *VariableExpression,
+	methods []*FunctionStatement,
+	classMethods []*FunctionStatement,
+	classFields []*VariableStatement,
+	fields []*VariableStatement,
+) *ClassStatement {
 	return &ClassStatement{
-		Name:       name,
-		Superclass: superclass,
-		Methods:    methods,
+		Name:         name,
+		Superclass:   superclass,
+		Methods:      methods,
+		ClassMethods: classMethods,
+		ClassFields:  classFields,
+		Fields:       fields,
+	}
+}
+
+func NewContinueStatement(keyword lexer.Token, label *lexer.Token) *ContinueStatement {
+	return &ContinueStatement{
+		Keyword: keyword,
+		Label:   label,
 	}
 }
 
@@ -20,11 +44,50 @@ func NewExpressionStatement(expression Expression) *ExpressionStatement {
 	return &ExpressionStatement{Expression: expression}
 }
 
-func NewFunctionStatement(name lexer.Token, parameters []lexer.Token, body []Statement) *FunctionStatement {
+func NewForInStatement(
+	keyword lexer.Token,
+	label *lexer.Token,
+	name lexer.Token,
+	iterable Expression,
+	body Statement,
+	end lexer.Token,
+) *ForInStatement {
+	return &ForInStatement{
+		Keyword:  keyword,
+		Label:    label,
+		Name:     name,
+		Iterable: iterable,
+		Body:     body,
+		End:      end,
+	}
+}
+
+func NewFunctionStatement(
+	name lexer.Token,
+	parameters []lexer.Token,
+	parameterTypes []*lexer.Token,
+	returnType *lexer.Token,
+	body []Statement,
+) *FunctionStatement {
+	return &FunctionStatement{
+		Name:           name,
+		Parameters:     parameters,
+		ParameterTypes: parameterTypes,
+		ReturnType:     returnType,
+		Body:           body,
+		IsGetter:       false,
+	}
+}
+
+// NewGetterStatement creates a method declared without parameter list, it's called when its property is accessed
+func NewGetterStatement(name lexer.Token, returnType *lexer.Token, body []Statement) *FunctionStatement {
 	return &FunctionStatement{
-		Name:       name,
-		Parameters: parameters,
-		Body:       body,
+		Name:           name,
+		Parameters:     make([]lexer.Token, 0),
+		ParameterTypes: make([]*lexer.Token, 0),
+		ReturnType:     returnType,
+		Body:           body,
+		IsGetter:       true,
 	}
 }
 
@@ -36,8 +99,17 @@ func NewIfStatment(condition Expression, thenCode Statement, elseCode Statement)
 	}
 }
 
-func NewPrintStatement(expression Expression) *PrintStatement {
-	return &PrintStatement{Expression: expression}
+func NewImportStatement(keyword lexer.Token, path lexer.Token, alias *lexer.Token, names []lexer.Token) *ImportStatement {
+	return &ImportStatement{
+		Keyword: keyword,
+		Path:    path,
+		Alias:   alias,
+		Names:   names,
+	}
+}
+
+func NewPrintStatement(keyword lexer.Token, expression Expression) *PrintStatement {
+	return &PrintStatement{Keyword: keyword, Expression: expression}
 }
 
 func NewReturnStatement(keyword lexer.Token, value Expression) *ReturnStatement {
@@ -47,17 +119,52 @@ func NewReturnStatement(keyword lexer.Token, value Expression) *ReturnStatement
 	}
 }
 
-func NewVariableStatement(name lexer.Token, initializer Expression) *VariableStatement {
+func NewThrowStatement(keyword lexer.Token, value Expression) *ThrowStatement {
+	return &ThrowStatement{
+		Keyword: keyword,
+		Value:   value,
+	}
+}
+
+func NewTryStatement(keyword lexer.Token, body []Statement, catch *CatchClause, finally *BlockStatement) *TryStatement {
+	return &TryStatement{
+		Keyword: keyword,
+		Body:    body,
+		Catch:   catch,
+		Finally: finally,
+	}
+}
+
+func NewCatchClause(name lexer.Token, body []Statement) *CatchClause {
+	return &CatchClause{
+		Name: name,
+		Body: body,
+	}
+}
+
+func NewVariableStatement(name lexer.Token, variableType *lexer.Token, initializer Expression) *VariableStatement {
 	return &VariableStatement{
 		Name:        name,
+		Type:        variableType,
 		Initializer: initializer,
 	}
 }
 
-func NewWhileStatement(condition Expression, body Statement) *WhileStatement {
+func NewWhileStatement(
+	keyword lexer.Token,
+	label *lexer.Token,
+	condition Expression,
+	increment Expression,
+	body Statement,
+	end lexer.Token,
+) *WhileStatement {
 	return &WhileStatement{
+		Keyword:   keyword,
+		Label:     label,
 		Condition: condition,
+		Increment: increment,
 		Body:      body,
+		End:       end,
 	}
 }
 
@@ -67,26 +174,70 @@ type BlockStatement struct {
 
 func (s *BlockStatement) Accept(visitor Visitor) { visitor.VisitBlockStatement(s) }
 
+type BreakStatement struct {
+	Keyword lexer.Token
+	// Label is the loop to exit, nil for the innermost loop
+	Label *lexer.Token
+}
+
+func (s *BreakStatement) Accept(visitor Visitor) { visitor.VisitBreakStatement(s) }
+
 type ClassStatement struct {
 	Name       lexer.Token
 	Superclass *VariableExpression
 	Methods    []*FunctionStatement
+	// ClassMethods are called on the class itself, 'this' is the class
+	ClassMethods []*FunctionStatement
+	// ClassFields are the properties of the class itself, they are initialized once the class is defined
+	ClassFields []*VariableStatement
+	// Fields declare the types of the instance fields ("x: number;"), they are only read by the type checker
+	Fields []*VariableStatement
 }
 
 func (s *ClassStatement) Accept(visitor Visitor) { visitor.VisitClassStatement(s) }
 
+type ContinueStatement struct {
+	Keyword lexer.Token
+	// Label is the loop to continue, nil for the innermost loop
+	Label *lexer.Token
+}
+
+func (s *ContinueStatement) Accept(visitor Visitor) { visitor.VisitContinueStatement(s) }
+
 type ExpressionStatement struct {
 	Expression Expression
 }
 
 func (s *ExpressionStatement) Accept(visitor Visitor) { visitor.VisitExpressionStatement(s) }
 
+// ForInStatement runs Body for each value of Iterable bound to Name: "for (name in iterable) body"
+type ForInStatement struct {
+	Keyword lexer.Token
+	// Label names the loop for the break and continue statements, it's nil for unlabelled loops
+	Label    *lexer.Token
+	Name     lexer.Token
+	Iterable Expression
+	Body     Statement
+	// End is the last token of the body
+	End lexer.Token
+}
+
+func (s *ForInStatement) Accept(visitor Visitor) { visitor.VisitForInStatement(s) }
+
 type FunctionStatement struct {
 	Name       lexer.Token
 	Parameters []lexer.Token
+	// ParameterTypes are the type annotations of the parameters, nil for a parameter without annotation
+	ParameterTypes []*lexer.Token
+	// ReturnType is the type annotation of the returned value, nil if there is none
+	ReturnType *lexer.Token
 	Body       []Statement
+	IsGetter   bool
 }
 
+// IsAnonymous tells if the function is declared by a function expression
+func (s *FunctionStatement) IsAnonymous() bool { return s.Name.Lexeme == "" }
+
 func (s *FunctionStatement) Accept(visitor Visitor) { visitor.VisitFunctionStatement(s) }
 
 type IfStatement struct {
@@ -97,7 +248,24 @@ type IfStatement struct {
 
 func (s *IfStatement) Accept(visitor Visitor) { visitor.VisitIfStatement(s) }
 
+// ImportStatement binds a module to Alias ("import" path "as" alias),
+// or the variables of the module to Names ("from" path "import" names)
+type ImportStatement struct {
+	// Keyword is either 'import' or 'from'
+	Keyword lexer.Token
+	// Path is the string literal of the module path, relative to the importing file
+	Path  lexer.Token
+	Alias *lexer.Token
+	Names []lexer.Token
+}
+
+// ModulePath returns the path of the imported module
+func (s *ImportStatement) ModulePath() string { return s.Path.Literal.(*lexer.StringLiteral).Value }
+
+func (s *ImportStatement) Accept(visitor Visitor) { visitor.VisitImportStatement(s) }
+
 type PrintStatement struct {
+	Keyword    lexer.Token
 	Expression Expression
 }
 
@@ -110,16 +278,49 @@ type ReturnStatement struct {
 
 func (s *ReturnStatement) Accept(visitor Visitor) { visitor.VisitReturnStatement(s) }
 
+type ThrowStatement struct {
+	Keyword lexer.Token
+	Value   Expression
+}
+
+func (s *ThrowStatement) Accept(visitor Visitor) { visitor.VisitThrowStatement(s) }
+
+// TryStatement has at least a catch or a finally clause, the missing one is nil
+type TryStatement struct {
+	Keyword lexer.Token
+	Body    []Statement
+	Catch   *CatchClause
+	Finally *BlockStatement
+}
+
+func (s *TryStatement) Accept(visitor Visitor) { visitor.VisitTryStatement(s) }
+
+// CatchClause binds the caught value to Name in the scope of Body
+type CatchClause struct {
+	Name lexer.Token
+	Body []Statement
+}
+
 type VariableStatement struct {
-	Name        lexer.Token
+	Name lexer.Token
+	// Type is the type annotation of the variable, nil if there is none
+	Type        *lexer.Token
 	Initializer Expression
 }
 
 func (s *VariableStatement) Accept(visitor Visitor) { visitor.VisitVariableStatement(s) }
 
 type WhileStatement struct {
+	// Keyword is either 'while' or 'for' which is desugared into a while loop
+	Keyword lexer.Token
+	// Label names the loop for the break and continue statements, it's nil for unlabelled loops
+	Label     *lexer.Token
 	Condition Expression
+	// Increment is the increment clause of a for loop, it's run after the body even when it continues
+	Increment Expression
 	Body      Statement
+	// End is the last token of the body
+	End lexer.Token
 }
 
 func (s *WhileStatement) Accept(visitor Visitor) { visitor.VisitWhileStatement(s) }
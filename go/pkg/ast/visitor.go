@@ -12,23 +12,35 @@ type Visitor interface {
 	VisitAssignmentExpression(assignementExpression *AssignmentExpression)
 	VisitBinaryExpression(binaryExpression *BinaryExpression)
 	VisitCallExpression(callExpression *CallExpression)
+	VisitFunctionExpression(functionExpression *FunctionExpression)
 	VisitGetExpression(getExpression *GetExpression)
 	VisitGroupingExpression(groupingExpression *GroupingExpression)
+	VisitIndexGetExpression(indexGetExpression *IndexGetExpression)
+	VisitIndexSetExpression(indexSetExpression *IndexSetExpression)
+	VisitListExpression(listExpression *ListExpression)
+	VisitMapExpression(mapExpression *MapExpression)
 	VisitLiteralExpression(literalExpression *LiteralExpression)
 	VisitLogicalExpression(logicalExpression *LogicalExpression)
 	VisitSetExpression(setExpression *SetExpression)
+	VisitStringifyExpression(stringifyExpression *StringifyExpression)
 	VisitSuperExpression(superExpression *SuperExpression)
 	VisitThisExpression(thisExpression *ThisExpression)
 	VisitUnaryExpression(unaryExpression *UnaryExpression)
 	VisitVariableExpression(variableExpression *VariableExpression)
 
 	VisitBlockStatement(blockStatement *BlockStatement)
+	VisitBreakStatement(breakStatement *BreakStatement)
 	VisitClassStatement(classStatement *ClassStatement)
+	VisitContinueStatement(continueStatement *ContinueStatement)
 	VisitExpressionStatement(expressionStatement *ExpressionStatement)
+	VisitForInStatement(forInStatement *ForInStatement)
 	VisitFunctionStatement(functionStatement *FunctionStatement)
 	VisitIfStatement(ifStatement *IfStatement)
+	VisitImportStatement(importStatement *ImportStatement)
 	VisitPrintStatement(printStatement *PrintStatement)
 	VisitReturnStatement(returnStatement *ReturnStatement)
+	VisitThrowStatement(throwStatement *ThrowStatement)
+	VisitTryStatement(tryStatement *TryStatement)
 	VisitVariableStatement(variableStatement *VariableStatement)
 	VisitWhileStatement(whileStatement *WhileStatement)
 }
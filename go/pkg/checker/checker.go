@@ -0,0 +1,678 @@
+//go:generate go run ../../cmd/code-generator checker
+package checker
+
+import (
+	"fmt"
+
+	"github.com/fpotier/lox/go/pkg/ast"
+	"github.com/fpotier/lox/go/pkg/lexer"
+	"github.com/fpotier/lox/go/pkg/loxerror"
+)
+
+// Checker verifies the type annotations before the program runs.
+// The values without annotation have the type any: a program without annotations has no type errors
+// and the values only meet a check when they reach an annotated variable, parameter, field or return value.
+type Checker struct {
+	errorFormatter loxerror.ErrorFormatter
+	// scopes map the variables to their types, the first scope holds the global variables
+	scopes []map[string]*variable
+	// classes are the classes declared in the program by name
+	classes map[string]*class
+	// classReferences are the annotations naming a class, they're checked at the end since a class can be used
+	// in an annotation before its declaration
+	classReferences []lexer.Token
+	// function is the signature of the function being checked, nil at the top level
+	function *signature
+	// this is the type of 'this' in the method being checked
+	this *Type
+	// expressionType is the type of the last checked expression
+	expressionType *Type
+}
+
+type variable struct {
+	variableType *Type
+	// annotated is set for the variables declared with a type, the values assigned to them are checked
+	annotated bool
+}
+
+func NewChecker(errorFormatter loxerror.ErrorFormatter) *Checker {
+	return &Checker{
+		errorFormatter:  errorFormatter,
+		scopes:          []map[string]*variable{make(map[string]*variable)},
+		classes:         make(map[string]*class),
+		classReferences: make([]lexer.Token, 0),
+		function:        nil,
+		this:            newType(anyType, false),
+		expressionType:  nil,
+	}
+}
+
+// CheckProgram checks a program, its global declarations are kept to check the next programs like the globals of an
+// engine are kept from one evaluation to the next. The declarations of a program with errors are discarded since it
+// doesn't run.
+func (c *Checker) CheckProgram(program []ast.Statement) {
+	globals := make(map[string]*variable, len(c.scopes[0]))
+	for name, global := range c.scopes[0] {
+		globals[name] = global
+	}
+	classes := make(map[string]*class, len(c.classes))
+	for name, declared := range c.classes {
+		classes[name] = declared
+	}
+
+	for _, statement := range program {
+		statement.Accept(c)
+	}
+
+	for _, reference := range c.classReferences {
+		if _, ok := c.classes[reference.Lexeme]; !ok {
+			c.errorFormatter.PushError(NewUnknownType(reference.Span, reference.Lexeme))
+		}
+	}
+	c.classReferences = c.classReferences[:0]
+
+	if c.errorFormatter.HasErrors() {
+		c.scopes[0] = globals
+		c.classes = classes
+	}
+}
+
+func (c *Checker) VisitAssignmentExpression(e *ast.AssignmentExpression) {
+	value := c.check(e.Value)
+	if variable := c.lookup(e.Name.Lexeme); variable != nil && variable.annotated {
+		c.expect(value, variable.variableType, fmt.Sprintf("variable '%s'", e.Name.Lexeme), e.Name.Span)
+	} else if variable != nil {
+		// The variable may hold either value after the assignment, like a function or a class replaced by another value
+		c.forget(e.Name.Lexeme)
+	}
+
+	c.expressionType = value
+}
+
+func (c *Checker) VisitBinaryExpression(e *ast.BinaryExpression) {
+	lhs := c.check(e.LHS)
+	rhs := c.check(e.RHS)
+	declared := lhs.declared || rhs.declared
+
+	// The instances may overload the operators with their special methods
+	if lhs.name == anyType || lhs.isInstance() {
+		c.expressionType = newType(anyType, false)
+		return
+	}
+	// Any two values can be compared for equality
+	if e.Operator.Type == lexer.EqualEqual || e.Operator.Type == lexer.BangEqual {
+		c.expressionType = newType(boolType, declared)
+		return
+	}
+
+	operandType, resultType := binaryOperation(e.Operator.Type, lhs.name)
+	if resultType == "" || (rhs.name != anyType && rhs.name != operandType) {
+		if declared {
+			c.errorFormatter.PushError(NewIncompatibleOperands(e.Operator.Span, e.Operator.Lexeme, lhs.name, rhs.name))
+		}
+		c.expressionType = newType(anyType, false)
+		return
+	}
+
+	c.expressionType = newType(resultType, declared)
+}
+
+// binaryOperation returns the type of the right operand and of the result of a binary operator given the type
+// of its left operand, they are empty if the operator doesn't apply to the left operand
+func binaryOperation(operator lexer.TokenType, lhs string) (string, string) {
+	switch operator {
+	case lexer.Plus:
+		if lhs == numberType || lhs == stringType {
+			return lhs, lhs
+		}
+	case lexer.Dash, lexer.Star, lexer.Slash:
+		if lhs == numberType {
+			return numberType, numberType
+		}
+	case lexer.Greater, lexer.GreaterEqual, lexer.Less, lexer.LessEqual:
+		if lhs == numberType {
+			return numberType, boolType
+		}
+	}
+
+	return "", ""
+}
+
+func (c *Checker) VisitCallExpression(e *ast.CallExpression) {
+	callee := c.check(e.Callee)
+	arguments := make([]*Type, 0, len(e.Args))
+	for _, argument := range e.Args {
+		arguments = append(arguments, c.check(argument))
+	}
+
+	switch {
+	case callee.class != nil:
+		if initializer, ok := c.findProperty(callee.class, "init", false); ok && initializer.signature != nil {
+			c.checkArguments(initializer.signature, arguments, e.Position.Span)
+		}
+		c.expressionType = newType(callee.class.name, false)
+	case callee.signature != nil:
+		c.checkArguments(callee.signature, arguments, e.Position.Span)
+		c.expressionType = callee.signature.returnType
+	default:
+		c.expressionType = newType(anyType, false)
+	}
+}
+
+func (c *Checker) checkArguments(signature *signature, arguments []*Type, span loxerror.Span) {
+	for index := 0; index < len(arguments) && index < len(signature.parameters); index++ {
+		target := fmt.Sprintf("parameter '%s' of '%s'", signature.parameters[index].Lexeme, signature.name)
+		c.expect(arguments[index], signature.parameterTypes[index], target, span)
+	}
+}
+
+func (c *Checker) VisitFunctionExpression(e *ast.FunctionExpression) {
+	signature := c.signature(e.Function)
+	c.checkFunction(e.Function, signature, c.this)
+	c.expressionType = &Type{name: functionType, declared: false, signature: signature, class: nil}
+}
+
+func (c *Checker) VisitGetExpression(e *ast.GetExpression) {
+	object := c.check(e.Object)
+	c.expressionType = newType(anyType, false)
+	if class, static := c.classOf(object); class != nil {
+		if property, ok := c.findProperty(class, e.Name.Lexeme, static); ok {
+			c.expressionType = property
+		}
+	}
+}
+
+func (c *Checker) VisitGroupingExpression(e *ast.GroupingExpression) {
+	c.expressionType = c.check(e.Expr)
+}
+
+func (c *Checker) VisitIndexGetExpression(e *ast.IndexGetExpression) {
+	c.check(e.Object)
+	c.check(e.Index)
+	c.expressionType = newType(anyType, false)
+}
+
+func (c *Checker) VisitIndexSetExpression(e *ast.IndexSetExpression) {
+	c.check(e.Object)
+	c.check(e.Index)
+	c.expressionType = c.check(e.Value)
+}
+
+func (c *Checker) VisitListExpression(e *ast.ListExpression) {
+	for _, element := range e.Elements {
+		c.check(element)
+	}
+	c.expressionType = newType(listType, false)
+}
+
+func (c *Checker) VisitMapExpression(e *ast.MapExpression) {
+	for index := range e.Keys {
+		c.check(e.Keys[index])
+		c.check(e.Values[index])
+	}
+	c.expressionType = newType(mapType, false)
+}
+
+func (c *Checker) VisitLiteralExpression(e *ast.LiteralExpression) {
+	switch e.LoxValue().Kind() {
+	case ast.Boolean:
+		c.expressionType = newType(boolType, false)
+	case ast.Number:
+		c.expressionType = newType(numberType, false)
+	case ast.String:
+		c.expressionType = newType(stringType, false)
+	default:
+		c.expressionType = newType(nilType, false)
+	}
+}
+
+func (c *Checker) VisitLogicalExpression(e *ast.LogicalExpression) {
+	lhs := c.check(e.LHS)
+	rhs := c.check(e.RHS)
+	if lhs.name == rhs.name {
+		c.expressionType = newType(lhs.name, lhs.declared && rhs.declared)
+		return
+	}
+
+	c.expressionType = newType(anyType, false)
+}
+
+func (c *Checker) VisitSetExpression(e *ast.SetExpression) {
+	object := c.check(e.Object)
+	value := c.check(e.Value)
+	if class, static := c.classOf(object); class != nil {
+		if fieldType, ok := c.findField(class, e.Name.Lexeme, static); ok {
+			c.expect(value, fieldType, fmt.Sprintf("field '%s' of '%s'", e.Name.Lexeme, class.name), e.Name.Span)
+		}
+	}
+
+	c.expressionType = value
+}
+
+func (c *Checker) VisitStringifyExpression(e *ast.StringifyExpression) {
+	c.check(e.Value)
+	c.expressionType = newType(stringType, false)
+}
+
+func (c *Checker) VisitSuperExpression(_ *ast.SuperExpression) {
+	c.expressionType = newType(anyType, false)
+}
+
+func (c *Checker) VisitThisExpression(_ *ast.ThisExpression) {
+	c.expressionType = c.this
+}
+
+func (c *Checker) VisitUnaryExpression(e *ast.UnaryExpression) {
+	operand := c.check(e.RHS)
+	if e.Operator.Type == lexer.Bang {
+		c.expressionType = newType(boolType, false)
+		return
+	}
+
+	switch {
+	case operand.name == numberType:
+		c.expressionType = operand
+	case operand.declared && !operand.isInstance():
+		c.errorFormatter.PushError(NewIncompatibleOperand(e.Operator.Span, e.Operator.Lexeme, operand.name))
+		c.expressionType = newType(anyType, false)
+	default:
+		c.expressionType = newType(anyType, false)
+	}
+}
+
+func (c *Checker) VisitVariableExpression(e *ast.VariableExpression) {
+	if variable := c.lookup(e.Name.Lexeme); variable != nil {
+		c.expressionType = variable.variableType
+		return
+	}
+
+	c.expressionType = newType(anyType, false)
+}
+
+func (c *Checker) VisitBlockStatement(s *ast.BlockStatement) {
+	c.block(s.Statements)
+}
+
+func (c *Checker) VisitBreakStatement(_ *ast.BreakStatement) {}
+
+func (c *Checker) VisitClassStatement(s *ast.ClassStatement) {
+	var superclass *class
+	if s.Superclass != nil {
+		c.check(s.Superclass)
+		superclass = c.classes[s.Superclass.Name.Lexeme]
+	}
+
+	declared := newClass(s.Name.Lexeme, superclass)
+	c.classes[declared.name] = declared
+	classValue := &Type{name: classType, declared: false, signature: nil, class: declared}
+	c.declare(s.Name.Lexeme, classValue, false)
+
+	for _, field := range s.Fields {
+		declared.instance.fields[field.Name.Lexeme] = c.annotationType(field.Type)
+	}
+	for _, field := range s.ClassFields {
+		if field.Type != nil {
+			declared.static.fields[field.Name.Lexeme] = c.annotationType(field.Type)
+		}
+	}
+
+	// The signatures are known before the bodies are checked, the methods can call each other
+	methods := c.declareMethods(declared.instance, s.Methods)
+	classMethods := c.declareMethods(declared.static, s.ClassMethods)
+	instance := newType(declared.name, false)
+	for index, method := range s.Methods {
+		c.checkFunction(method, methods[index], instance)
+	}
+	for index, method := range s.ClassMethods {
+		c.checkFunction(method, classMethods[index], classValue)
+	}
+
+	for _, field := range s.ClassFields {
+		value := newType(nilType, false)
+		if field.Initializer != nil {
+			value = c.check(field.Initializer)
+		}
+		if fieldType, ok := declared.static.fields[field.Name.Lexeme]; ok {
+			c.expect(value, fieldType, fmt.Sprintf("field '%s' of '%s'", field.Name.Lexeme, declared.name), field.Name.Span)
+		}
+	}
+}
+
+// declareMethods adds the methods to the members of a class and returns their signatures
+func (c *Checker) declareMethods(members members, methods []*ast.FunctionStatement) []*signature {
+	signatures := make([]*signature, 0, len(methods))
+	for _, method := range methods {
+		signature := c.signature(method)
+		if method.IsGetter {
+			members.getters[method.Name.Lexeme] = signature.returnType
+		} else {
+			members.methods[method.Name.Lexeme] = &Type{name: functionType, declared: false, signature: signature, class: nil}
+		}
+		signatures = append(signatures, signature)
+	}
+
+	return signatures
+}
+
+func (c *Checker) VisitContinueStatement(_ *ast.ContinueStatement) {}
+
+func (c *Checker) VisitExpressionStatement(s *ast.ExpressionStatement) {
+	c.check(s.Expression)
+}
+
+func (c *Checker) VisitForInStatement(s *ast.ForInStatement) {
+	c.check(s.Iterable)
+	c.beginScope()
+	c.declare(s.Name.Lexeme, newType(anyType, false), false)
+	s.Body.Accept(c)
+	c.endScope()
+}
+
+func (c *Checker) VisitFunctionStatement(s *ast.FunctionStatement) {
+	signature := c.signature(s)
+	c.declare(s.Name.Lexeme, &Type{name: functionType, declared: false, signature: signature, class: nil}, false)
+	c.checkFunction(s, signature, c.this)
+}
+
+func (c *Checker) VisitIfStatement(s *ast.IfStatement) {
+	c.check(s.Condition)
+	s.ThenCode.Accept(c)
+	if s.ElseCode != nil {
+		s.ElseCode.Accept(c)
+	}
+}
+
+func (c *Checker) VisitImportStatement(s *ast.ImportStatement) {
+	if s.Alias != nil {
+		c.declare(s.Alias.Lexeme, newType(anyType, false), false)
+	}
+	for _, name := range s.Names {
+		c.declare(name.Lexeme, newType(anyType, false), false)
+	}
+}
+
+func (c *Checker) VisitPrintStatement(s *ast.PrintStatement) {
+	c.check(s.Expression)
+}
+
+func (c *Checker) VisitReturnStatement(s *ast.ReturnStatement) {
+	value := newType(nilType, false)
+	if s.Value != nil {
+		value = c.check(s.Value)
+	}
+
+	if c.function != nil {
+		c.expect(value, c.function.returnType, fmt.Sprintf("return value of '%s'", c.function.name), s.Keyword.Span)
+	}
+}
+
+func (c *Checker) VisitThrowStatement(s *ast.ThrowStatement) {
+	c.check(s.Value)
+}
+
+func (c *Checker) VisitTryStatement(s *ast.TryStatement) {
+	c.block(s.Body)
+	if s.Catch != nil {
+		c.beginScope()
+		c.declare(s.Catch.Name.Lexeme, newType(anyType, false), false)
+		c.block(s.Catch.Body)
+		c.endScope()
+	}
+	if s.Finally != nil {
+		s.Finally.Accept(c)
+	}
+}
+
+func (c *Checker) VisitVariableStatement(s *ast.VariableStatement) {
+	variableType := c.annotationType(s.Type)
+	// A variable without initializer is nil
+	value := newType(nilType, false)
+	if s.Initializer != nil {
+		value = c.check(s.Initializer)
+	}
+	c.expect(value, variableType, fmt.Sprintf("variable '%s'", s.Name.Lexeme), s.Name.Span)
+
+	c.declare(s.Name.Lexeme, variableType, s.Type != nil)
+}
+
+func (c *Checker) VisitWhileStatement(s *ast.WhileStatement) {
+	c.check(s.Condition)
+	s.Body.Accept(c)
+	if s.Increment != nil {
+		c.check(s.Increment)
+	}
+}
+
+// check returns the type of an expression
+func (c *Checker) check(expression ast.Expression) *Type {
+	expression.Accept(c)
+	return c.expressionType
+}
+
+// expect reports a value whose type doesn't match the type of its target
+func (c *Checker) expect(value *Type, target *Type, description string, span loxerror.Span) {
+	if !c.isAssignable(value, target) {
+		c.errorFormatter.PushError(NewTypeMismatch(span, description, target.name, value.name))
+	}
+}
+
+// isAssignable tells if a value of a type can be used where the target type is expected
+func (c *Checker) isAssignable(value *Type, target *Type) bool {
+	switch {
+	case value.name == anyType || target.name == anyType || value.name == target.name:
+		return true
+	case target.name == functionType:
+		return value.name == classType
+	case value.isInstance():
+		class, ok := c.classes[value.name]
+		return ok && class.isSubclassOf(target.name)
+	default:
+		return false
+	}
+}
+
+// annotationType returns the type of an annotation, a declaration without annotation has the type any
+func (c *Checker) annotationType(annotation *lexer.Token) *Type {
+	if annotation == nil {
+		return newType(anyType, false)
+	}
+	if !builtinTypes[annotation.Lexeme] {
+		c.classReferences = append(c.classReferences, *annotation)
+	}
+
+	return newType(annotation.Lexeme, true)
+}
+
+func (c *Checker) signature(declaration *ast.FunctionStatement) *signature {
+	name := declaration.Name.Lexeme
+	if declaration.IsAnonymous() {
+		name = ast.AnonymousFunctionName
+	}
+
+	parameterTypes := make([]*Type, 0, len(declaration.ParameterTypes))
+	for _, annotation := range declaration.ParameterTypes {
+		parameterTypes = append(parameterTypes, c.annotationType(annotation))
+	}
+
+	return &signature{
+		name:           name,
+		parameters:     declaration.Parameters,
+		parameterTypes: parameterTypes,
+		returnType:     c.annotationType(declaration.ReturnType),
+	}
+}
+
+// checkFunction checks the body of a function, this is the type of 'this' in the body
+func (c *Checker) checkFunction(declaration *ast.FunctionStatement, signature *signature, this *Type) {
+	enclosingFunction, enclosingThis := c.function, c.this
+	c.function, c.this = signature, this
+	defer func() { c.function, c.this = enclosingFunction, enclosingThis }()
+
+	c.beginScope()
+	for index, parameter := range declaration.Parameters {
+		c.declare(parameter.Lexeme, signature.parameterTypes[index], declaration.ParameterTypes[index] != nil)
+	}
+	for _, statement := range declaration.Body {
+		statement.Accept(c)
+	}
+	c.endScope()
+
+	// The end of the body returns nil
+	nilValue := newType(nilType, false)
+	if declaration.ReturnType != nil && !c.isAssignable(nilValue, signature.returnType) && !terminatesAll(declaration.Body) {
+		c.errorFormatter.PushError(NewMissingReturn(declaration.ReturnType.Span, signature.name, signature.returnType.name))
+	}
+}
+
+// terminatesAll tells if a list of statements never reaches its end
+func terminatesAll(statements []ast.Statement) bool {
+	for _, statement := range statements {
+		if terminates(statement) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// terminates tells if a statement never completes: it always returns, throws or loops forever
+func terminates(statement ast.Statement) bool {
+	switch s := statement.(type) {
+	case *ast.ReturnStatement, *ast.ThrowStatement:
+		return true
+	case *ast.BlockStatement:
+		return terminatesAll(s.Statements)
+	case *ast.IfStatement:
+		return s.ElseCode != nil && terminates(s.ThenCode) && terminates(s.ElseCode)
+	case *ast.TryStatement:
+		if s.Finally != nil && terminates(s.Finally) {
+			return true
+		}
+		return terminatesAll(s.Body) && (s.Catch == nil || terminatesAll(s.Catch.Body))
+	case *ast.WhileStatement:
+		condition, ok := s.Condition.(*ast.LiteralExpression)
+		return ok && condition.LoxValue().IsTruthy() && !breaks(s.Body, s.Label, false)
+	default:
+		return false
+	}
+}
+
+// breaks tells if a statement of a loop body contains a break leaving the loop with the given label.
+// nested is set in the body of an inner loop, where only the labelled breaks leave the outer loop.
+func breaks(statement ast.Statement, label *lexer.Token, nested bool) bool {
+	switch s := statement.(type) {
+	case *ast.BreakStatement:
+		if s.Label == nil {
+			return !nested
+		}
+		return label != nil && s.Label.Lexeme == label.Lexeme
+	case *ast.BlockStatement:
+		return breaksAny(s.Statements, label, nested)
+	case *ast.IfStatement:
+		return breaks(s.ThenCode, label, nested) || (s.ElseCode != nil && breaks(s.ElseCode, label, nested))
+	case *ast.TryStatement:
+		return breaksAny(s.Body, label, nested) ||
+			(s.Catch != nil && breaksAny(s.Catch.Body, label, nested)) ||
+			(s.Finally != nil && breaks(s.Finally, label, nested))
+	case *ast.WhileStatement:
+		return breaks(s.Body, label, true)
+	case *ast.ForInStatement:
+		return breaks(s.Body, label, true)
+	default:
+		return false
+	}
+}
+
+func breaksAny(statements []ast.Statement, label *lexer.Token, nested bool) bool {
+	for _, statement := range statements {
+		if breaks(statement, label, nested) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// classOf returns the class of an instance, or the class itself with static set
+func (c *Checker) classOf(object *Type) (*class, bool) {
+	if object.class != nil {
+		return object.class, true
+	}
+	if object.isInstance() {
+		return c.classes[object.name], false
+	}
+
+	return nil, false
+}
+
+// findProperty returns the type of a property of a class or of its instances, the superclasses are searched too
+func (c *Checker) findProperty(class *class, name string, static bool) (*Type, bool) {
+	for current := class; current != nil; current = current.superclass {
+		members := current.instance
+		if static {
+			members = current.static
+		}
+		if property, ok := members.property(name); ok {
+			return property, true
+		}
+	}
+
+	return nil, false
+}
+
+// findField returns the type of an annotated field of a class or of its instances
+func (c *Checker) findField(class *class, name string, static bool) (*Type, bool) {
+	for current := class; current != nil; current = current.superclass {
+		members := current.instance
+		if static {
+			members = current.static
+		}
+		if fieldType, ok := members.fields[name]; ok {
+			return fieldType, true
+		}
+	}
+
+	return nil, false
+}
+
+func (c *Checker) block(statements []ast.Statement) {
+	c.beginScope()
+	for _, statement := range statements {
+		statement.Accept(c)
+	}
+	c.endScope()
+}
+
+func (c *Checker) beginScope() {
+	c.scopes = append(c.scopes, make(map[string]*variable))
+}
+
+func (c *Checker) endScope() {
+	c.scopes = c.scopes[:len(c.scopes)-1]
+}
+
+func (c *Checker) declare(name string, variableType *Type, annotated bool) {
+	c.scopes[len(c.scopes)-1][name] = &variable{variableType: variableType, annotated: annotated}
+}
+
+// forget gives the type any to the innermost variable with the given name, the variable is replaced since the globals
+// are shared with the snapshot of CheckProgram
+func (c *Checker) forget(name string) {
+	for index := len(c.scopes) - 1; index >= 0; index-- {
+		if _, ok := c.scopes[index][name]; ok {
+			c.scopes[index][name] = &variable{variableType: newType(anyType, false), annotated: false}
+			return
+		}
+	}
+}
+
+// lookup returns the innermost variable with the given name, nil if it isn't declared in the program
+func (c *Checker) lookup(name string) *variable {
+	for index := len(c.scopes) - 1; index >= 0; index-- {
+		if variable, ok := c.scopes[index][name]; ok {
+			return variable
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,106 @@
+package checker
+
+import "github.com/fpotier/lox/go/pkg/lexer"
+
+// The names of the types of the annotations, the other names are class names whose values are instances
+const (
+	anyType      = "any"
+	boolType     = "bool"
+	numberType   = "number"
+	stringType   = "string"
+	nilType      = "nil"
+	listType     = "list"
+	mapType      = "map"
+	functionType = "function"
+	// classType is the type of the classes themselves, it can't be written in an annotation
+	classType = "class"
+)
+
+var builtinTypes = map[string]bool{
+	anyType:      true,
+	boolType:     true,
+	numberType:   true,
+	stringType:   true,
+	nilType:      true,
+	listType:     true,
+	mapType:      true,
+	functionType: true,
+}
+
+// Type is the static type of an expression, a value of the type any is never reported
+type Type struct {
+	name string
+	// declared is set for the types coming from an annotation, the operators only check the declared operands
+	declared bool
+	// signature is known for the functions declared in the program
+	signature *signature
+	// class is set for the type of a class, not for its instances
+	class *class
+}
+
+func newType(name string, declared bool) *Type {
+	return &Type{name: name, declared: declared, signature: nil, class: nil}
+}
+
+// isInstance tells if the values of the type are instances of a class
+func (t *Type) isInstance() bool {
+	return !builtinTypes[t.name] && t.name != classType
+}
+
+type signature struct {
+	name           string
+	parameters     []lexer.Token
+	parameterTypes []*Type
+	// returnType is any for the functions without return type annotation
+	returnType *Type
+}
+
+// members are the properties of the instances of a class or of the class itself
+type members struct {
+	// fields are the annotated fields, the other fields have the type any
+	fields  map[string]*Type
+	getters map[string]*Type
+	methods map[string]*Type
+}
+
+func newMembers() members {
+	return members{
+		fields:  make(map[string]*Type),
+		getters: make(map[string]*Type),
+		methods: make(map[string]*Type),
+	}
+}
+
+func (m members) property(name string) (*Type, bool) {
+	if fieldType, ok := m.fields[name]; ok {
+		return fieldType, true
+	}
+	if returnType, ok := m.getters[name]; ok {
+		return returnType, true
+	}
+	method, ok := m.methods[name]
+
+	return method, ok
+}
+
+type class struct {
+	name       string
+	superclass *class
+	instance   members
+	static     members
+}
+
+func newClass(name string, superclass *class) *class {
+	return &class{name: name, superclass: superclass, instance: newMembers(), static: newMembers()}
+}
+
+// isSubclassOf tells if the class is the given class or inherits from it
+func (c *class) isSubclassOf(name string) bool {
+	for current := c; current != nil; current = current.superclass {
+		if current.name == name {
+			return true
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,437 @@
+package debug
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/fpotier/lox/go/pkg/lox"
+	"github.com/fpotier/lox/go/pkg/loxerror"
+)
+
+// threadID is the only thread of the programs
+const threadID = 1
+
+// DAPServer debugs a script for a client of the Debug Adapter Protocol.
+// The requests are read on the calling goroutine and the script runs on another one, the requests inspecting it are
+// answered while it's paused.
+type DAPServer struct {
+	reader *bufio.Reader
+	writer io.Writer
+	// options are the engine options, the output of the script is sent to the client
+	options  []lox.Option
+	debugger *Debugger
+	// writeMutex serializes the messages sent by the two goroutines
+	writeMutex sync.Mutex
+	seq        int
+	launch     *LaunchArguments
+	// afterResponse is set by a handler to act once its response is sent
+	afterResponse func()
+	configured    bool
+	running       bool
+	// mutex protects pause and stopping, pause is nil while the script runs
+	mutex    sync.Mutex
+	pause    *Pause
+	stopping bool
+	resume   chan Action
+}
+
+func NewDAPServer(reader io.Reader, writer io.Writer, options ...lox.Option) *DAPServer {
+	s := &DAPServer{
+		reader:        bufio.NewReader(reader),
+		writer:        writer,
+		options:       options,
+		debugger:      nil,
+		writeMutex:    sync.Mutex{},
+		seq:           0,
+		launch:        nil,
+		afterResponse: nil,
+		configured:    false,
+		running:       false,
+		mutex:         sync.Mutex{},
+		pause:         nil,
+		stopping:      false,
+		resume:        make(chan Action),
+	}
+	s.debugger = NewDebugger(s, false)
+
+	return s
+}
+
+type dapHandler func(s *DAPServer, arguments json.RawMessage) (any, error)
+
+var dapHandlers = map[string]dapHandler{
+	"initialize":        (*DAPServer).initialize,
+	"launch":            (*DAPServer).launchProgram,
+	"attach":            (*DAPServer).launchProgram,
+	"setBreakpoints":    (*DAPServer).setBreakpoints,
+	"configurationDone": (*DAPServer).configurationDone,
+	"threads":           (*DAPServer).threads,
+	"stackTrace":        (*DAPServer).stackTrace,
+	"scopes":            (*DAPServer).scopes,
+	"variables":         (*DAPServer).variables,
+	"continue":          resumeWith(Continue),
+	"next":              resumeWith(StepOver),
+	"stepIn":            resumeWith(StepIn),
+	"stepOut":           resumeWith(StepOut),
+	"pause":             (*DAPServer).pauseProgram,
+}
+
+// Run answers the requests until the client disconnects
+func (s *DAPServer) Run() error {
+	for {
+		request, err := s.read()
+		if err != nil {
+			return err
+		}
+
+		if request.Command == "disconnect" || request.Command == "terminate" {
+			s.stop()
+			return s.respond(request, nil, nil)
+		}
+
+		handle, ok := dapHandlers[request.Command]
+		if !ok {
+			err = fmt.Errorf("unsupported request '%s'", request.Command)
+		} else {
+			var body any
+			body, err = handle(s, request.Arguments)
+			if err == nil {
+				if err := s.respond(request, body, nil); err != nil {
+					return err
+				}
+				if s.afterResponse != nil {
+					s.afterResponse()
+					s.afterResponse = nil
+				}
+				continue
+			}
+		}
+		if err := s.respond(request, nil, err); err != nil {
+			return err
+		}
+	}
+}
+
+// Paused tells the client where the script stopped and waits for a request resuming it
+func (s *DAPServer) Paused(pause *Pause) Action {
+	s.mutex.Lock()
+	if s.stopping {
+		s.mutex.Unlock()
+		return Stop
+	}
+	s.pause = pause
+	s.mutex.Unlock()
+
+	s.event("stopped", StoppedEvent{Reason: pause.Reason, ThreadID: threadID, AllThreadsStopped: true})
+
+	return <-s.resume
+}
+
+func (s *DAPServer) read() (*dapRequest, error) {
+	length := -1
+	for {
+		line, err := s.reader.ReadString('\n')
+		if err != nil {
+			return nil, err
+		}
+		line = strings.TrimRight(line, "\r\n")
+		if line == "" {
+			break
+		}
+		name, value, ok := strings.Cut(line, ":")
+		if ok && strings.EqualFold(strings.TrimSpace(name), "Content-Length") {
+			if length, err = strconv.Atoi(strings.TrimSpace(value)); err != nil {
+				return nil, fmt.Errorf("invalid Content-Length header: %w", err)
+			}
+		}
+	}
+	if length < 0 {
+		return nil, errors.New("missing Content-Length header")
+	}
+
+	content := make([]byte, length)
+	if _, err := io.ReadFull(s.reader, content); err != nil {
+		return nil, err
+	}
+	var request dapRequest
+	if err := json.Unmarshal(content, &request); err != nil {
+		return nil, fmt.Errorf("invalid message: %w", err)
+	}
+
+	return &request, nil
+}
+
+func (s *DAPServer) respond(request *dapRequest, body any, err error) error {
+	s.writeMutex.Lock()
+	defer s.writeMutex.Unlock()
+
+	s.seq++
+	response := dapResponse{
+		Seq:        s.seq,
+		Type:       "response",
+		RequestSeq: request.Seq,
+		Success:    err == nil,
+		Command:    request.Command,
+		Message:    "",
+		Body:       body,
+	}
+	if err != nil {
+		response.Message = err.Error()
+	}
+
+	return s.write(response)
+}
+
+// event sends an event, a failure to send it is reported by the next response
+func (s *DAPServer) event(name string, body any) {
+	s.writeMutex.Lock()
+	defer s.writeMutex.Unlock()
+
+	s.seq++
+	_ = s.write(dapEvent{Seq: s.seq, Type: "event", Event: name, Body: body})
+}
+
+func (s *DAPServer) write(message any) error {
+	content, err := json.Marshal(message)
+	if err != nil {
+		return err
+	}
+	_, err = fmt.Fprintf(s.writer, "Content-Length: %d\r\n\r\n%s", len(content), content)
+
+	return err
+}
+
+// decodeArguments reads the arguments of a request
+func decodeArguments[T any](arguments json.RawMessage) (T, error) {
+	var decoded T
+	if err := json.Unmarshal(arguments, &decoded); err != nil {
+		return decoded, fmt.Errorf("invalid arguments: %w", err)
+	}
+
+	return decoded, nil
+}
+
+func (s *DAPServer) initialize(json.RawMessage) (any, error) {
+	// The client sends the breakpoints once initialized
+	s.afterResponse = func() { s.event("initialized", nil) }
+
+	return Capabilities{SupportsConfigurationDoneRequest: true, SupportsTerminateRequest: true}, nil
+}
+
+func (s *DAPServer) launchProgram(rawArguments json.RawMessage) (any, error) {
+	arguments, err := decodeArguments[LaunchArguments](rawArguments)
+	if err != nil {
+		return nil, err
+	}
+	if arguments.Program == "" {
+		return nil, errors.New("the 'program' argument is missing")
+	}
+	s.launch = &arguments
+	s.start()
+
+	return nil, nil
+}
+
+func (s *DAPServer) configurationDone(json.RawMessage) (any, error) {
+	s.configured = true
+	s.start()
+
+	return nil, nil
+}
+
+// start runs the script once it's launched and the breakpoints are set
+func (s *DAPServer) start() {
+	if s.launch == nil || !s.configured || s.running {
+		return
+	}
+	s.running = true
+	if s.launch.StopOnEntry {
+		s.debugger.action = StepIn
+	}
+
+	program := s.launch.Program
+	s.afterResponse = func() { go s.runProgram(program) }
+}
+
+func (s *DAPServer) runProgram(program string) {
+	options := append([]lox.Option{
+		lox.WithErrorFormatter(loxerror.NewTextErrorFormatter(false)),
+	}, s.options...)
+	options = append(options, lox.WithStdout(outputWriter{server: s, category: "stdout"}))
+	engine := lox.NewEngine(options...)
+	engine.Interpreter().Debugger = s.debugger
+
+	exitCode := 0
+	if err := engine.EvalFile(program); err != nil {
+		exitCode = 1
+		s.event("output", OutputEvent{Category: "stderr", Output: err.Error() + "\n"})
+	}
+	s.event("exited", ExitedEvent{ExitCode: exitCode})
+	s.event("terminated", nil)
+}
+
+// stop interrupts the script on its next statement
+func (s *DAPServer) stop() {
+	s.mutex.Lock()
+	s.stopping = true
+	paused := s.pause != nil
+	s.pause = nil
+	s.mutex.Unlock()
+
+	if paused {
+		s.resume <- Stop
+	} else {
+		s.debugger.RequestPause()
+	}
+}
+
+func (s *DAPServer) setBreakpoints(rawArguments json.RawMessage) (any, error) {
+	arguments, err := decodeArguments[SetBreakpointsArguments](rawArguments)
+	if err != nil {
+		return nil, err
+	}
+
+	lines := make([]int, 0)
+	breakpoints := make([]Breakpoint, 0)
+	for _, breakpoint := range arguments.Breakpoints {
+		lines = append(lines, breakpoint.Line)
+		breakpoints = append(breakpoints, Breakpoint{Verified: true, Line: breakpoint.Line})
+	}
+	s.debugger.SetBreakpoints(arguments.Source.Path, lines)
+
+	return SetBreakpointsResponse{Breakpoints: breakpoints}, nil
+}
+
+func (s *DAPServer) threads(json.RawMessage) (any, error) {
+	return ThreadsResponse{Threads: []Thread{{ID: threadID, Name: "main"}}}, nil
+}
+
+// paused returns the pause of the script, the requests inspecting it fail while it runs
+func (s *DAPServer) paused() (*Pause, error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	if s.pause == nil {
+		return nil, errors.New("the program isn't paused")
+	}
+
+	return s.pause, nil
+}
+
+func (s *DAPServer) stackTrace(json.RawMessage) (any, error) {
+	pause, err := s.paused()
+	if err != nil {
+		return nil, err
+	}
+
+	frames := make([]StackFrame, 0, len(pause.Frames))
+	for index, frame := range pause.Frames {
+		path := absolutePath(frame.Position.Span.File)
+		frames = append(frames, StackFrame{
+			ID:     index,
+			Name:   frame.Function,
+			Source: Source{Name: filepath.Base(path), Path: path},
+			Line:   frame.Position.Line,
+			Column: frame.Position.Span.Start.Column,
+		})
+	}
+
+	return StackTraceResponse{StackFrames: frames, TotalFrames: len(frames)}, nil
+}
+
+// The variables references of a frame are 2 * frame + 1 for its locals and 2 * frame + 2 for the globals
+func (s *DAPServer) scopes(rawArguments json.RawMessage) (any, error) {
+	arguments, err := decodeArguments[ScopesArguments](rawArguments)
+	if err != nil {
+		return nil, err
+	}
+	pause, err := s.paused()
+	if err != nil {
+		return nil, err
+	}
+	if arguments.FrameID < 0 || arguments.FrameID >= len(pause.Frames) {
+		return nil, fmt.Errorf("unknown frame %d", arguments.FrameID)
+	}
+
+	scopes := make([]DAPScope, 0)
+	for index, scope := range pause.Scopes(arguments.FrameID) {
+		scopes = append(scopes, DAPScope{
+			Name:               scope.Name,
+			VariablesReference: 2*arguments.FrameID + index + 1,
+			Expensive:          false,
+		})
+	}
+
+	return ScopesResponse{Scopes: scopes}, nil
+}
+
+func (s *DAPServer) variables(rawArguments json.RawMessage) (any, error) {
+	arguments, err := decodeArguments[VariablesArguments](rawArguments)
+	if err != nil {
+		return nil, err
+	}
+	pause, err := s.paused()
+	if err != nil {
+		return nil, err
+	}
+	frame, scope := (arguments.VariablesReference-1)/2, (arguments.VariablesReference-1)%2
+	if arguments.VariablesReference <= 0 || frame >= len(pause.Frames) {
+		return nil, fmt.Errorf("unknown variables reference %d", arguments.VariablesReference)
+	}
+
+	variables := make([]DAPVariable, 0)
+	for _, variable := range pause.Scopes(frame)[scope].Variables {
+		variables = append(variables, DAPVariable{
+			Name:               variable.Name,
+			Value:              Describe(variable.Value),
+			VariablesReference: 0,
+		})
+	}
+
+	return VariablesResponse{Variables: variables}, nil
+}
+
+// resumeWith creates the handler of a request resuming the script
+func resumeWith(action Action) dapHandler {
+	return func(s *DAPServer, _ json.RawMessage) (any, error) {
+		s.mutex.Lock()
+		if s.pause == nil {
+			s.mutex.Unlock()
+			return nil, errors.New("the program isn't paused")
+		}
+		s.pause = nil
+		s.mutex.Unlock()
+
+		// The script resumes once the response is sent, it may pause again right away
+		s.afterResponse = func() { s.resume <- action }
+		if action == Continue {
+			return ContinueResponse{AllThreadsContinued: true}, nil
+		}
+		return nil, nil
+	}
+}
+
+func (s *DAPServer) pauseProgram(json.RawMessage) (any, error) {
+	s.debugger.RequestPause()
+
+	return nil, nil
+}
+
+// outputWriter sends the output of the script to the client
+type outputWriter struct {
+	server   *DAPServer
+	category string
+}
+
+func (w outputWriter) Write(p []byte) (int, error) {
+	w.server.event("output", OutputEvent{Category: w.category, Output: string(p)})
+
+	return len(p), nil
+}
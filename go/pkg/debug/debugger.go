@@ -0,0 +1,215 @@
+// Package debug pauses the tree-walking interpreter at breakpoints and steps, the paused program is inspected by a
+// terminal or by a client of the Debug Adapter Protocol
+package debug
+
+import (
+	"path/filepath"
+	"strconv"
+	"sync"
+
+	"github.com/fpotier/lox/go/pkg/ast"
+	"github.com/fpotier/lox/go/pkg/lexer"
+	"github.com/fpotier/lox/go/pkg/runtime"
+)
+
+// Action tells how a paused program resumes
+type Action uint8
+
+const (
+	Continue Action = iota
+	// StepIn stops at the next statement, in a called function or not
+	StepIn
+	// StepOver stops at the next statement of the current function or of its callers
+	StepOver
+	// StepOut stops once the current function returns
+	StepOut
+	// Stop interrupts the program
+	Stop
+)
+
+// The reasons of a pause
+const (
+	EntryReason      = "entry"
+	BreakpointReason = "breakpoint"
+	StepReason       = "step"
+	PauseReason      = "pause"
+)
+
+// Pause is a paused program, it can only be inspected until the program resumes
+type Pause struct {
+	Reason string
+	// Frames are the calls in progress, innermost first, the last one is the top-level code
+	Frames      []runtime.Frame
+	interpreter *runtime.Interpreter
+}
+
+// Scopes returns the variables visible from a frame
+func (p *Pause) Scopes(frame int) []runtime.Scope {
+	return p.interpreter.Scopes(p.Frames[frame])
+}
+
+// Frontend is told when the program pauses, it inspects the program and returns how it resumes
+type Frontend interface {
+	Paused(pause *Pause) Action
+}
+
+// location is a statement and the call depth at which it runs
+type location struct {
+	file  string
+	line  int
+	depth int
+}
+
+// Debugger is the runtime.DebugHook deciding where the program pauses
+type Debugger struct {
+	frontend Frontend
+	// mutex protects the breakpoints and the pause request, a frontend may set them while the program runs
+	mutex sync.Mutex
+	// breakpoints holds the lines of the breakpoints by absolute file path
+	breakpoints    map[string]map[int]bool
+	pauseRequested bool
+	started        bool
+	action         Action
+	// resumed is where the program resumed, the steps are relative to it
+	resumed location
+	// previous is the last statement executed, a breakpoint stops on the first statement of its line
+	previous location
+	// paths caches the absolute path of the files of the statements
+	paths map[string]string
+}
+
+// NewDebugger creates a debugger reporting the pauses to a frontend, stopOnEntry pauses on the first statement
+func NewDebugger(frontend Frontend, stopOnEntry bool) *Debugger {
+	action := Continue
+	if stopOnEntry {
+		action = StepIn
+	}
+
+	return &Debugger{
+		frontend:       frontend,
+		mutex:          sync.Mutex{},
+		breakpoints:    make(map[string]map[int]bool),
+		pauseRequested: false,
+		started:        false,
+		action:         action,
+		resumed:        location{file: "", line: 0, depth: 0},
+		previous:       location{file: "", line: 0, depth: 0},
+		paths:          make(map[string]string),
+	}
+}
+
+// SetBreakpoints replaces the breakpoints of a file
+func (d *Debugger) SetBreakpoints(file string, lines []int) {
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+
+	breakpoints := make(map[int]bool)
+	for _, line := range lines {
+		breakpoints[line] = true
+	}
+	d.breakpoints[absolutePath(file)] = breakpoints
+}
+
+// Breakpoints returns the lines of the breakpoints of a file
+func (d *Debugger) Breakpoints(file string) []int {
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+
+	lines := make([]int, 0)
+	for line := range d.breakpoints[absolutePath(file)] {
+		lines = append(lines, line)
+	}
+
+	return lines
+}
+
+// RequestPause pauses the running program on its next statement
+func (d *Debugger) RequestPause() {
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+
+	d.pauseRequested = true
+}
+
+func (d *Debugger) BeforeStatement(interpreter *runtime.Interpreter, position lexer.Token) {
+	path, ok := d.paths[position.Span.File]
+	if !ok {
+		path = absolutePath(position.Span.File)
+		d.paths[position.Span.File] = path
+	}
+	current := location{file: path, line: position.Line, depth: interpreter.CallDepth()}
+	newLine := current != d.previous
+	d.previous = current
+
+	reason := d.reason(current, newLine)
+	if reason == "" {
+		return
+	}
+
+	pause := &Pause{Reason: reason, Frames: interpreter.Frames(position), interpreter: interpreter}
+	d.action = d.frontend.Paused(pause)
+	d.resumed = current
+	if d.action == Stop {
+		panic(runtime.NewExecutionInterrupted(position.Span, "stopped by the debugger"))
+	}
+}
+
+// reason tells why the program pauses before a statement, it's empty if it doesn't
+func (d *Debugger) reason(current location, newLine bool) string {
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+
+	reason := ""
+	switch {
+	case d.pauseRequested:
+		d.pauseRequested = false
+		reason = PauseReason
+	case d.stepEnds(current) && !d.started:
+		reason = EntryReason
+	case d.stepEnds(current):
+		reason = StepReason
+	case newLine && d.breakpoints[current.file][current.line]:
+		reason = BreakpointReason
+	}
+	if reason != "" {
+		d.started = true
+	}
+
+	return reason
+}
+
+// stepEnds tells if the step being run stops before a statement
+func (d *Debugger) stepEnds(current location) bool {
+	sameLine := current.file == d.resumed.file && current.line == d.resumed.line
+	switch d.action {
+	case StepIn:
+		return !sameLine || current.depth != d.resumed.depth
+	case StepOver:
+		return current.depth < d.resumed.depth || (current.depth == d.resumed.depth && !sameLine)
+	case StepOut:
+		return current.depth < d.resumed.depth
+	}
+
+	return false
+}
+
+// absolutePath identifies a file whatever the working directory, the source code not read from a file has no path
+func absolutePath(file string) string {
+	if file == "" {
+		return ""
+	}
+	if path, err := filepath.Abs(file); err == nil {
+		return path
+	}
+
+	return filepath.Clean(file)
+}
+
+// Describe renders a value in the debugger, unlike print the strings are quoted
+func Describe(value ast.LoxValue) string {
+	if str, ok := value.(*ast.StringValue); ok {
+		return strconv.Quote(str.Value)
+	}
+
+	return value.String()
+}
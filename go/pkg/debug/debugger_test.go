@@ -0,0 +1,178 @@
+package debug
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/fpotier/lox/go/pkg/lox"
+)
+
+const script = `var total = 0;
+fun add(amount) {
+  var doubled = amount * 2;
+  total = total + doubled;
+  return total;
+}
+add(1);
+add(2);
+print total;
+`
+
+func writeScript(t *testing.T) string {
+	t.Helper()
+
+	path := filepath.Join(t.TempDir(), "script.lox")
+	if err := os.WriteFile(path, []byte(script), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	return path
+}
+
+func TestTerminal(t *testing.T) {
+	path := writeScript(t)
+	commands := "b 3\nc\nbt\nl\nn\np doubled\no\nclear 3\nc\n"
+	var output bytes.Buffer
+	engine := lox.NewEngine(lox.WithStdout(&output))
+	engine.Interpreter().Debugger = NewTerminal(strings.NewReader(commands), &output, path)
+	if err := engine.EvalFile(path); err != nil {
+		t.Fatal(err)
+	}
+
+	expected := `Paused at script.lox:1 (entry)
+   1 | var total = 0;
+(debug) (debug) Paused at script.lox:3 (breakpoint)
+   3 |   var doubled = amount * 2;
+(debug) * #0 add at script.lox:3
+  #1 <script> at script.lox:7
+(debug) Locals:
+  amount = 1
+Globals:
+  add = <fn add>
+  total = 0
+(debug) Paused at script.lox:4 (step)
+   4 |   total = total + doubled;
+(debug) doubled = 2
+(debug) Paused at script.lox:8 (step)
+   8 | add(2);
+(debug) (debug) 6
+`
+	if actual := strings.ReplaceAll(output.String(), path, "script.lox"); actual != expected {
+		t.Errorf("unexpected session:\n%s", actual)
+	}
+}
+
+// dapClient sends the requests to a DAP server and reads its messages
+type dapClient struct {
+	t      *testing.T
+	writer io.Writer
+	reader *bufio.Reader
+	seq    int
+}
+
+func (c *dapClient) send(command string, arguments any) {
+	c.t.Helper()
+
+	c.seq++
+	content, err := json.Marshal(map[string]any{"seq": c.seq, "type": "request", "command": command, "arguments": arguments})
+	if err != nil {
+		c.t.Fatal(err)
+	}
+	fmt.Fprintf(c.writer, "Content-Length: %d\r\n\r\n%s", len(content), content)
+}
+
+// expect reads the messages until the response to a command or an event, it returns its body
+func (c *dapClient) expect(kind string, name string) map[string]any {
+	c.t.Helper()
+
+	for {
+		var length int
+		if _, err := fmt.Fscanf(c.reader, "Content-Length: %d\r\n\r\n", &length); err != nil {
+			c.t.Fatal(err)
+		}
+		content := make([]byte, length)
+		if _, err := io.ReadFull(c.reader, content); err != nil {
+			c.t.Fatal(err)
+		}
+		var m map[string]any
+		if err := json.Unmarshal(content, &m); err != nil {
+			c.t.Fatal(err)
+		}
+		if m["type"] == kind && (m["command"] == name || m["event"] == name) {
+			if m["type"] == "response" && m["success"] != true {
+				c.t.Fatalf("request %s failed: %v", name, m["message"])
+			}
+			body, _ := m["body"].(map[string]any)
+			return body
+		}
+	}
+}
+
+func TestDAPServer(t *testing.T) {
+	path := writeScript(t)
+	requests, requestWriter := io.Pipe()
+	responseReader, responses := io.Pipe()
+	server := NewDAPServer(requests, responses)
+	done := make(chan error)
+	go func() { done <- server.Run() }()
+	client := &dapClient{t: t, writer: requestWriter, reader: bufio.NewReader(responseReader), seq: 0}
+
+	client.send("initialize", map[string]any{"adapterID": "lox"})
+	client.expect("response", "initialize")
+	client.expect("event", "initialized")
+	client.send("launch", LaunchArguments{Program: path, StopOnEntry: false})
+	client.expect("response", "launch")
+	client.send("setBreakpoints", SetBreakpointsArguments{
+		Source:      Source{Name: "script.lox", Path: path},
+		Breakpoints: []SourceBreakpoint{{Line: 4}},
+	})
+	client.expect("response", "setBreakpoints")
+	client.send("configurationDone", nil)
+	client.expect("response", "configurationDone")
+
+	if stopped := client.expect("event", "stopped"); stopped["reason"] != BreakpointReason {
+		t.Errorf("unexpected stop: %v", stopped)
+	}
+	client.send("stackTrace", map[string]any{"threadId": threadID})
+	frames := client.expect("response", "stackTrace")["stackFrames"].([]any)
+	if len(frames) != 2 || frames[0].(map[string]any)["name"] != "add" || frames[1].(map[string]any)["line"] != 7.0 {
+		t.Errorf("unexpected stack trace: %v", frames)
+	}
+	client.send("scopes", ScopesArguments{FrameID: 0})
+	client.expect("response", "scopes")
+	client.send("variables", VariablesArguments{VariablesReference: 1})
+	variables := client.expect("response", "variables")["variables"]
+	if fmt.Sprint(variables) != "[map[name:amount value:1 variablesReference:0] map[name:doubled value:2 variablesReference:0]]" {
+		t.Errorf("unexpected locals: %v", variables)
+	}
+
+	client.send("stepOut", nil)
+	client.expect("response", "stepOut")
+	if stopped := client.expect("event", "stopped"); stopped["reason"] != StepReason {
+		t.Errorf("unexpected stop: %v", stopped)
+	}
+	client.send("setBreakpoints", SetBreakpointsArguments{Source: Source{Name: "script.lox", Path: path}, Breakpoints: nil})
+	client.expect("response", "setBreakpoints")
+	client.send("continue", nil)
+	client.expect("response", "continue")
+	if output := client.expect("event", "output"); output["output"] != "6\n" {
+		t.Errorf("unexpected output: %v", output)
+	}
+	if exited := client.expect("event", "exited"); exited["exitCode"] != 0.0 {
+		t.Errorf("unexpected exit: %v", exited)
+	}
+	client.expect("event", "terminated")
+
+	client.send("disconnect", nil)
+	client.expect("response", "disconnect")
+	if err := <-done; err != nil {
+		t.Fatal(err)
+	}
+}
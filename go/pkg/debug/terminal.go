@@ -0,0 +1,177 @@
+package debug
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/fpotier/lox/go/pkg/runtime"
+)
+
+const terminalHelp = `Commands:
+  c, continue          resume until the next breakpoint
+  s, step              stop at the next statement, entering the called functions
+  n, next              stop at the next statement of this function or of its callers
+  o, out               stop once this function returns
+  b, break [file:]line set a breakpoint, in the script by default
+  clear [file:]line    remove a breakpoint
+  bt, backtrace        list the calls in progress
+  f, frame index       select a frame of the backtrace
+  l, locals            list the variables of the selected frame
+  p, print name        print a variable of the selected frame
+  q, quit              stop the program
+`
+
+// Terminal debugs a script from a terminal, it pauses on the first statement to let the user set breakpoints
+type Terminal struct {
+	*Debugger
+	input  *bufio.Scanner
+	output io.Writer
+	// script is the file of the breakpoints set without file
+	script string
+	// sources caches the lines of the files shown in the pauses
+	sources map[string][]string
+}
+
+func NewTerminal(input io.Reader, output io.Writer, script string) *Terminal {
+	t := &Terminal{
+		Debugger: nil,
+		input:    bufio.NewScanner(input),
+		output:   output,
+		script:   script,
+		sources:  make(map[string][]string),
+	}
+	t.Debugger = NewDebugger(t, true)
+
+	return t
+}
+
+// Paused reads the commands until one resumes the program, the end of the input lets the program run to its end
+func (t *Terminal) Paused(pause *Pause) Action {
+	frame := 0
+	position := pause.Frames[0].Position
+	fmt.Fprintf(t.output, "Paused at %s:%d (%s)\n", position.Span.File, position.Line, pause.Reason)
+	t.printLine(pause.Frames[0])
+
+	for {
+		fmt.Fprint(t.output, "(debug) ")
+		if !t.input.Scan() {
+			fmt.Fprintln(t.output)
+			return Continue
+		}
+		fields := strings.Fields(t.input.Text())
+		if len(fields) == 0 {
+			continue
+		}
+
+		argument := ""
+		if len(fields) > 1 {
+			argument = fields[1]
+		}
+		switch fields[0] {
+		case "c", "continue":
+			return Continue
+		case "s", "step":
+			return StepIn
+		case "n", "next":
+			return StepOver
+		case "o", "out":
+			return StepOut
+		case "q", "quit":
+			return Stop
+		case "b", "break":
+			t.toggleBreakpoint(argument, true)
+		case "clear":
+			t.toggleBreakpoint(argument, false)
+		case "bt", "backtrace":
+			t.printFrames(pause.Frames, frame)
+		case "f", "frame":
+			index, err := strconv.Atoi(argument)
+			if err != nil || index < 0 || index >= len(pause.Frames) {
+				fmt.Fprintf(t.output, "Expect a frame index between 0 and %d\n", len(pause.Frames)-1)
+				continue
+			}
+			frame = index
+			t.printLine(pause.Frames[frame])
+		case "l", "locals":
+			for _, scope := range pause.Scopes(frame) {
+				fmt.Fprintf(t.output, "%s:\n", scope.Name)
+				for _, variable := range scope.Variables {
+					fmt.Fprintf(t.output, "  %s = %s\n", variable.Name, Describe(variable.Value))
+				}
+			}
+		case "p", "print":
+			t.printVariable(pause.Scopes(frame), argument)
+		case "h", "help":
+			fmt.Fprint(t.output, terminalHelp)
+		default:
+			fmt.Fprintf(t.output, "Unknown command '%s', 'help' lists the commands\n", fields[0])
+		}
+	}
+}
+
+// toggleBreakpoint sets or removes a breakpoint given as "line" or "file:line"
+func (t *Terminal) toggleBreakpoint(argument string, set bool) {
+	file, lineText := t.script, argument
+	if index := strings.LastIndexByte(argument, ':'); index >= 0 {
+		file, lineText = argument[:index], argument[index+1:]
+	}
+	line, err := strconv.Atoi(lineText)
+	if err != nil || line <= 0 {
+		fmt.Fprintln(t.output, "Expect a line number, optionally preceded by a file and ':'")
+		return
+	}
+
+	lines := make([]int, 0)
+	for _, existing := range t.Breakpoints(file) {
+		if existing != line {
+			lines = append(lines, existing)
+		}
+	}
+	if set {
+		lines = append(lines, line)
+	}
+	t.SetBreakpoints(file, lines)
+}
+
+func (t *Terminal) printFrames(frames []runtime.Frame, selected int) {
+	for index, frame := range frames {
+		marker := " "
+		if index == selected {
+			marker = "*"
+		}
+		fmt.Fprintf(t.output, "%s #%d %s at %s:%d\n", marker, index, frame.Function, frame.Position.Span.File,
+			frame.Position.Line)
+	}
+}
+
+func (t *Terminal) printVariable(scopes []runtime.Scope, name string) {
+	for _, scope := range scopes {
+		for _, variable := range scope.Variables {
+			if variable.Name == name {
+				fmt.Fprintf(t.output, "%s = %s\n", name, Describe(variable.Value))
+				return
+			}
+		}
+	}
+	fmt.Fprintf(t.output, "Undefined variable '%s'\n", name)
+}
+
+// printLine shows the line of the statement of a frame, the source code not read from a file isn't shown
+func (t *Terminal) printLine(frame runtime.Frame) {
+	file := frame.Position.Span.File
+	lines, ok := t.sources[file]
+	if !ok && file != "" {
+		if sourceCode, err := os.ReadFile(file); err == nil {
+			lines = strings.Split(string(sourceCode), "\n")
+		}
+		t.sources[file] = lines
+	}
+
+	if line := frame.Position.Line; line > 0 && line <= len(lines) {
+		fmt.Fprintf(t.output, "%4d | %s\n", line, strings.TrimRight(lines[line-1], "\r"))
+	}
+}
@@ -0,0 +1,65 @@
+// Package format reprints the source code of a program in its canonical form: the indentation, the spacing, the
+// placement of the braces and the splitting of the long lists are normalized, the comments are kept
+package format
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/fpotier/lox/go/pkg/lexer"
+	"github.com/fpotier/lox/go/pkg/loxerror"
+	"github.com/fpotier/lox/go/pkg/parser"
+)
+
+// ErrSyntax is returned for a source code which doesn't parse, its errors are pushed to the error formatter
+var ErrSyntax = errors.New("the source code has syntax errors")
+
+// Source formats the source code of a file, the file only locates the syntax errors
+func Source(errorFormatter loxerror.ErrorFormatter, file string, sourceCode string) (string, error) {
+	l := lexer.NewFileLexer(errorFormatter, file, sourceCode)
+	tokens := l.Tokens()
+	statements := parser.NewParser(errorFormatter, tokens).Parse()
+	if errorFormatter.HasErrors() {
+		return "", ErrSyntax
+	}
+
+	p := newPrinter(tokens, l.Comments())
+	if err := p.program(statements); err != nil {
+		return "", err
+	}
+	formatted := string(p.output)
+	if err := verify(tokens, l.Comments(), formatted); err != nil {
+		return "", err
+	}
+
+	return formatted, nil
+}
+
+// verify checks that the formatting only changed the layout, the formatted code has the same tokens and comments
+func verify(tokens []lexer.Token, comments []lexer.Token, formatted string) error {
+	l := lexer.NewLexer(loxerror.NewJSONErrorFormatter(), formatted)
+	formattedTokens := l.Tokens()
+	formattedComments := l.Comments()
+
+	for i, token := range tokens {
+		if i >= len(formattedTokens) || formattedTokens[i].Type != token.Type || formattedTokens[i].Lexeme != token.Lexeme {
+			return fmt.Errorf("%w: the code of line %d is altered", errPrinter, token.Line)
+		}
+	}
+	for i, comment := range comments {
+		if i >= len(formattedComments) || formattedComments[i].Lexeme != commentText(comment) {
+			return fmt.Errorf("%w: the comment of line %d is altered", errPrinter, comment.Line)
+		}
+	}
+	if len(formattedTokens) != len(tokens) || len(formattedComments) != len(comments) {
+		return fmt.Errorf("%w: the formatted code has more tokens", errPrinter)
+	}
+
+	return nil
+}
+
+// commentText returns a comment as it's printed, without its trailing blanks
+func commentText(comment lexer.Token) string {
+	return strings.TrimRight(comment.Lexeme, " \t")
+}
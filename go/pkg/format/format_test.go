@@ -0,0 +1,119 @@
+package format
+
+import (
+	"errors"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/fpotier/lox/go/pkg/loxerror"
+)
+
+const TestDirectory = "../../../test"
+
+func TestSource(t *testing.T) {
+	tests := []struct {
+		name     string
+		source   string
+		expected string
+	}{
+		{
+			name:     "spacing",
+			source:   "var x:number=1+2*-y;print(x);",
+			expected: "var x: number = 1 + 2 * -y;\nprint (x);\n",
+		},
+		{
+			name:     "braces",
+			source:   "fun f(a,b){if(a){return b;}else if(b)return a;else{return nil;}}",
+			expected: "fun f(a, b) {\n  if (a) {\n    return b;\n  } else if (b) return a;\n  else {\n    return nil;\n  }\n}\n",
+		},
+		{
+			name:     "loops",
+			source:   "for(var i=0;i<3;i=i+1)print i;\nouter:for(;;){for(x in xs){continue outer;}}",
+			expected: "for (var i = 0; i < 3; i = i + 1) print i;\nouter: for (;;) {\n  for (x in xs) {\n    continue outer;\n  }\n}\n",
+		},
+		{
+			name:     "comments and blank lines",
+			source:   "// header\n\n\n\nvar a = 1; // one\n{\n\n  // inside\n  a = 2;\n\n}\n// end\n",
+			expected: "// header\n\nvar a = 1; // one\n{\n  // inside\n  a = 2;\n}\n// end\n",
+		},
+		{
+			name:     "functions",
+			source:   "var f = (a:number) => a*2;\nvar g = fun(){};\nxs.map(fun (x) {return x;});",
+			expected: "var f = (a: number) => a * 2;\nvar g = fun () {};\nxs.map(fun (x) {\n  return x;\n});\n",
+		},
+		{
+			name:     "class",
+			source:   "class A<B{init(x){this.x=x;}\nclass var n=0;\nx:number;\narea{return 1;}}",
+			expected: "class A < B {\n  init(x) {\n    this.x = x;\n  }\n  class var n = 0;\n  x: number;\n  area {\n    return 1;\n  }\n}\n",
+		},
+		{
+			name:     "strings",
+			source:   `print "a${ x+1 }b${"c${y}"}\n";`,
+			expected: "print \"a${x + 1}b${\"c${y}\"}\\n\";\n",
+		},
+		{
+			name: "long list",
+			source: "var l = [firstVeryLongFunctionName(1, 2), secondVeryLongFunctionName(3, 4), thirdVeryLongFunctionName(5, 6), 7];\n" +
+				"f(a, // the first\nb);",
+			expected: "var l = [\n  firstVeryLongFunctionName(1, 2),\n  secondVeryLongFunctionName(3, 4),\n  thirdVeryLongFunctionName(5, 6),\n  7\n];\n" +
+				"f(\n  a, // the first\n  b\n);\n",
+		},
+		{
+			name:     "exceptions and modules",
+			source:   "from \"m.lox\" import a,b;\ntry{throw 1;}catch(e){print e;}finally{}",
+			expected: "from \"m.lox\" import a, b;\ntry {\n  throw 1;\n} catch (e) {\n  print e;\n} finally {}\n",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			formatted, err := Source(loxerror.NewJSONErrorFormatter(), "", test.source)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if formatted != test.expected {
+				t.Errorf("expected:\n%s\nactual:\n%s", test.expected, formatted)
+			}
+		})
+	}
+}
+
+func TestSyntaxError(t *testing.T) {
+	errorFormatter := loxerror.NewJSONErrorFormatter()
+	if _, err := Source(errorFormatter, "", "print ;"); !errors.Is(err, ErrSyntax) || !errorFormatter.HasErrors() {
+		t.Errorf("expected a syntax error, got %v", err)
+	}
+}
+
+// TestTestSuite formats the scripts of the test suite, formatting them again doesn't change them
+func TestTestSuite(t *testing.T) {
+	err := filepath.WalkDir(TestDirectory, func(path string, entry fs.DirEntry, err error) error {
+		if err != nil || entry.IsDir() || !strings.HasSuffix(path, ".lox") {
+			return err
+		}
+		sourceCode, err := os.ReadFile(path)
+		if err != nil {
+			return err
+		}
+
+		formatted, err := Source(loxerror.NewJSONErrorFormatter(), path, string(sourceCode))
+		if errors.Is(err, ErrSyntax) {
+			return nil
+		}
+		if err != nil {
+			t.Errorf("%s: %v", path, err)
+			return nil
+		}
+		if again, err := Source(loxerror.NewJSONErrorFormatter(), path, formatted); err != nil || again != formatted {
+			t.Errorf("%s: formatting isn't stable (%v):\n%s", path, err, again)
+		}
+
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+}
@@ -0,0 +1,731 @@
+package format
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/fpotier/lox/go/pkg/ast"
+	"github.com/fpotier/lox/go/pkg/lexer"
+)
+
+const (
+	indentation = "  "
+	// maxWidth is the width beyond which the arguments, the list elements and the map entries are split across lines
+	maxWidth = 100
+)
+
+// errPrinter reports a printer which lost its way in the tokens, it's a bug of the formatter
+var errPrinter = errors.New("internal formatter error")
+
+// unexpectedToken is raised when the printer expects another token than the next one of the source code
+type unexpectedToken struct {
+	token lexer.Token
+}
+
+// printer is the visitor printing the source code of the AST. The AST doesn't hold every token (parentheses,
+// commas, ...) and some statements are desugared, so the printer walks the tokens of the source code along with the
+// AST: each token is printed from the source code, in order, and the comments are printed between them
+type printer struct {
+	tokens   []lexer.Token
+	comments []lexer.Token
+	// next is the index of the next token to print, nextComment the index of the next comment
+	next        int
+	nextComment int
+	output      []byte
+	indent      int
+	// line is the source line on which the last token or comment printed ends
+	line int
+	// lineBreak is set when the next token starts a new line, space when it's preceded by a space
+	lineBreak bool
+	space     bool
+	// compact drops the blank line before the next token, at the start and at the end of a block
+	compact bool
+	// flat keeps the nested lists on one line while a list is tried on one line, the outer lists are split first
+	flat bool
+	// broken is set when a comment breaks the line of a list tried on one line
+	broken bool
+}
+
+func newPrinter(tokens []lexer.Token, comments []lexer.Token) *printer {
+	return &printer{
+		tokens:      tokens,
+		comments:    comments,
+		next:        0,
+		nextComment: 0,
+		output:      make([]byte, 0),
+		indent:      0,
+		line:        1,
+		lineBreak:   false,
+		space:       false,
+		compact:     true,
+		flat:        false,
+		broken:      false,
+	}
+}
+
+func (p *printer) program(statements []ast.Statement) (err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			unexpected, ok := r.(*unexpectedToken)
+			if !ok {
+				panic(r)
+			}
+			err = fmt.Errorf("%w: unexpected token '%s' at line %d", errPrinter, unexpected.token.Lexeme,
+				unexpected.token.Line)
+		}
+	}()
+
+	p.statements(statements)
+	p.flushComments()
+	if len(p.output) > 0 {
+		p.output = append(p.output, '\n')
+	}
+
+	return nil
+}
+
+func (p *printer) statements(statements []ast.Statement) {
+	for _, statement := range statements {
+		p.lineBreak = true
+		statement.Accept(p)
+	}
+}
+
+// token prints the next token of the source code, preceded by the comments before it
+func (p *printer) token(tokenType lexer.TokenType) {
+	token := p.tokens[p.next]
+	if token.Type != tokenType {
+		panic(&unexpectedToken{token: token})
+	}
+	p.flushComments()
+	p.next++
+	p.write(token.Lexeme, token.Line)
+	p.line = token.Span.End.Line
+}
+
+// flushComments prints the comments before the next token, a comment following a token on its line stays on this line
+func (p *printer) flushComments() {
+	for p.hasComment() {
+		comment := p.comments[p.nextComment]
+		p.nextComment++
+		if comment.Line == p.line && len(p.output) > 0 {
+			p.output = append(p.output, ' ')
+			p.output = append(p.output, commentText(comment)...)
+		} else {
+			p.lineBreak = true
+			p.write(commentText(comment), comment.Line)
+		}
+		p.line = comment.Line
+		p.lineBreak = true
+		p.broken = p.broken || p.flat
+	}
+}
+
+// hasComment tells if there is a comment before the next token
+func (p *printer) hasComment() bool {
+	return p.nextComment < len(p.comments) &&
+		p.comments[p.nextComment].Span.Start.Offset < p.tokens[p.next].Span.Start.Offset
+}
+
+// write prints some text at the current position, a new line keeps one of the blank lines preceding it in the source
+func (p *printer) write(text string, line int) {
+	switch {
+	case p.lineBreak && len(p.output) > 0:
+		p.output = append(p.output, '\n')
+		if !p.compact && line > p.line+1 {
+			p.output = append(p.output, '\n')
+		}
+		p.output = append(p.output, strings.Repeat(indentation, p.indent)...)
+	case p.space:
+		p.output = append(p.output, ' ')
+	}
+	p.lineBreak = false
+	p.space = false
+	p.compact = false
+	p.output = append(p.output, text...)
+}
+
+// block prints statements between braces, an empty block is printed on one line
+func (p *printer) block(statements []ast.Statement) {
+	p.token(lexer.LeftBrace)
+	if len(statements) == 0 && !p.hasComment() {
+		p.token(lexer.RightBrace)
+		return
+	}
+
+	// The lists of the statements of a function expression are split independently of the list holding it
+	flat, broken := p.flat, p.broken
+	p.flat = false
+	p.indent++
+	p.compact = true
+	p.statements(statements)
+	p.closeBlock(lexer.RightBrace)
+	p.flat, p.broken = flat, broken
+}
+
+// closeBlock prints the delimiter closing an indented block on its own line, after the comments of the block
+func (p *printer) closeBlock(closing lexer.TokenType) {
+	p.flushComments()
+	p.indent--
+	p.lineBreak = true
+	p.compact = true
+	p.token(closing)
+}
+
+// body prints the body of a control flow statement on the line of its header
+func (p *printer) body(body ast.Statement) {
+	p.space = true
+	body.Accept(p)
+}
+
+func (p *printer) expression(expression ast.Expression) {
+	expression.Accept(p)
+}
+
+// elements prints a list separated by commas followed by its closing delimiter, the list is split with an element per
+// line if it's too wide or if it holds comments
+func (p *printer) elements(count int, element func(index int), closing lexer.TokenType) {
+	if count == 0 {
+		p.token(closing)
+		return
+	}
+
+	saved := *p
+	start := len(p.output)
+	if !saved.flat {
+		p.flat = true
+		p.broken = false
+	}
+	for i := 0; i < count; i++ {
+		if i > 0 {
+			p.token(lexer.Comma)
+			p.space = true
+		}
+		element(i)
+	}
+	p.token(closing)
+	p.flat = saved.flat
+	if saved.flat || (!p.broken && !p.overflows(start)) {
+		return
+	}
+
+	*p = saved
+	p.indent++
+	p.compact = true
+	for i := 0; i < count; i++ {
+		p.lineBreak = true
+		element(i)
+		if i < count-1 {
+			p.token(lexer.Comma)
+		}
+	}
+	p.closeBlock(closing)
+}
+
+// overflows tells if the first or the last line of the output printed since start is wider than maxWidth
+func (p *printer) overflows(start int) bool {
+	lineStart := bytes.LastIndexByte(p.output[:start], '\n') + 1
+	lineEnd := len(p.output)
+	if index := bytes.IndexByte(p.output[start:], '\n'); index >= 0 {
+		lineEnd = start + index
+	}
+	if utf8.RuneCount(p.output[lineStart:lineEnd]) > maxWidth {
+		return true
+	}
+
+	lastLine := bytes.LastIndexByte(p.output[start:], '\n')
+	return lastLine >= 0 && utf8.RuneCount(p.output[start+lastLine+1:]) > maxWidth
+}
+
+// typeAnnotation prints the optional type annotation of a name: ": number"
+func (p *printer) typeAnnotation(typeName *lexer.Token) {
+	if typeName == nil {
+		return
+	}
+	p.token(lexer.Colon)
+	p.space = true
+	p.token(typeName.Type)
+}
+
+// label prints the optional label of a loop: "outer: "
+func (p *printer) label(label *lexer.Token) {
+	if label == nil {
+		return
+	}
+	p.token(lexer.Identifier)
+	p.token(lexer.Colon)
+	p.space = true
+}
+
+// function prints a function from its name or from its parameter list, the keywords before are already printed
+func (p *printer) function(function *ast.FunctionStatement) {
+	if !function.IsAnonymous() {
+		p.token(lexer.Identifier)
+	}
+	if function.IsGetter {
+		p.typeAnnotation(function.ReturnType)
+		p.space = true
+		p.block(function.Body)
+		return
+	}
+
+	p.token(lexer.LeftParenthesis)
+	p.parameters(function)
+	p.typeAnnotation(function.ReturnType)
+	p.space = true
+	p.block(function.Body)
+}
+
+// parameters prints the parameters of a function and its closing parenthesis
+func (p *printer) parameters(function *ast.FunctionStatement) {
+	for i, parameter := range function.Parameters {
+		if i > 0 {
+			p.token(lexer.Comma)
+			p.space = true
+		}
+		p.token(parameter.Type)
+		p.typeAnnotation(function.ParameterTypes[i])
+	}
+	p.token(lexer.RightParenthesis)
+}
+
+// forLoop prints a for loop, the parser desugared it into a while loop preceded by its initializer
+func (p *printer) forLoop(initializer ast.Statement, loop *ast.WhileStatement) {
+	p.label(loop.Label)
+	p.token(lexer.For)
+	p.space = true
+	p.token(lexer.LeftParenthesis)
+	if initializer != nil {
+		initializer.Accept(p)
+	} else {
+		p.token(lexer.Semicolon)
+	}
+	// The missing condition is replaced by true located at the 'for' keyword
+	if literal, ok := loop.Condition.(*ast.LiteralExpression); !ok || literal.Token.Type != lexer.For {
+		p.space = true
+		p.expression(loop.Condition)
+	}
+	p.token(lexer.Semicolon)
+	if loop.Increment != nil {
+		p.space = true
+		p.expression(loop.Increment)
+	}
+	p.token(lexer.RightParenthesis)
+	p.body(loop.Body)
+}
+
+// isInterpolation tells if a concatenation joins the parts of an interpolated string, its operator is located at
+// a part of the string literal instead of a '+'
+func isInterpolation(binaryExpression *ast.BinaryExpression) bool {
+	span := binaryExpression.Operator.Span
+	return span.End.Offset-span.Start.Offset != len(binaryExpression.Operator.Lexeme)
+}
+
+// interpolatedValues returns the expressions interpolated in a string, in order
+func interpolatedValues(expression ast.Expression) []ast.Expression {
+	binaryExpression, ok := expression.(*ast.BinaryExpression)
+	if !ok || !isInterpolation(binaryExpression) {
+		return make([]ast.Expression, 0)
+	}
+
+	values := interpolatedValues(binaryExpression.LHS)
+	if stringify, ok := binaryExpression.RHS.(*ast.StringifyExpression); ok {
+		values = append(values, stringify.Value)
+	}
+
+	return values
+}
+
+// arrowReturn returns the statement returning the expression of an arrow function, it's located at the arrow
+func arrowReturn(function *ast.FunctionStatement) (*ast.ReturnStatement, bool) {
+	if len(function.Body) != 1 {
+		return nil, false
+	}
+	ret, ok := function.Body[0].(*ast.ReturnStatement)
+
+	return ret, ok && ret.Keyword.Type == lexer.Arrow
+}
+
+func (p *printer) VisitAssignmentExpression(assignmentExpression *ast.AssignmentExpression) {
+	p.token(lexer.Identifier)
+	p.space = true
+	p.token(lexer.Equal)
+	p.space = true
+	p.expression(assignmentExpression.Value)
+}
+
+func (p *printer) VisitBinaryExpression(binaryExpression *ast.BinaryExpression) {
+	if isInterpolation(binaryExpression) {
+		values := interpolatedValues(binaryExpression)
+		p.token(lexer.Interpolation)
+		for i, value := range values {
+			p.expression(value)
+			if i < len(values)-1 {
+				p.token(lexer.Interpolation)
+			} else {
+				p.token(lexer.String)
+			}
+		}
+		return
+	}
+
+	p.expression(binaryExpression.LHS)
+	p.space = true
+	p.token(binaryExpression.Operator.Type)
+	p.space = true
+	p.expression(binaryExpression.RHS)
+}
+
+func (p *printer) VisitCallExpression(callExpression *ast.CallExpression) {
+	p.expression(callExpression.Callee)
+	p.token(lexer.LeftParenthesis)
+	p.elements(len(callExpression.Args), func(i int) { p.expression(callExpression.Args[i]) }, lexer.RightParenthesis)
+}
+
+func (p *printer) VisitFunctionExpression(functionExpression *ast.FunctionExpression) {
+	function := functionExpression.Function
+	// An arrow function returns its expression from the arrow
+	if ret, ok := arrowReturn(function); ok {
+		p.token(lexer.LeftParenthesis)
+		p.parameters(function)
+		p.typeAnnotation(function.ReturnType)
+		p.space = true
+		p.token(lexer.Arrow)
+		p.space = true
+		p.expression(ret.Value)
+		return
+	}
+
+	p.token(lexer.Fun)
+	p.space = true
+	p.function(function)
+}
+
+func (p *printer) VisitGetExpression(getExpression *ast.GetExpression) {
+	p.expression(getExpression.Object)
+	p.token(lexer.Dot)
+	p.token(lexer.Identifier)
+}
+
+func (p *printer) VisitGroupingExpression(groupingExpression *ast.GroupingExpression) {
+	p.token(lexer.LeftParenthesis)
+	p.expression(groupingExpression.Expr)
+	p.token(lexer.RightParenthesis)
+}
+
+func (p *printer) VisitIndexGetExpression(indexGetExpression *ast.IndexGetExpression) {
+	p.expression(indexGetExpression.Object)
+	p.token(lexer.LeftBracket)
+	p.expression(indexGetExpression.Index)
+	p.token(lexer.RightBracket)
+}
+
+func (p *printer) VisitIndexSetExpression(indexSetExpression *ast.IndexSetExpression) {
+	p.expression(indexSetExpression.Object)
+	p.token(lexer.LeftBracket)
+	p.expression(indexSetExpression.Index)
+	p.token(lexer.RightBracket)
+	p.space = true
+	p.token(lexer.Equal)
+	p.space = true
+	p.expression(indexSetExpression.Value)
+}
+
+func (p *printer) VisitListExpression(listExpression *ast.ListExpression) {
+	p.token(lexer.LeftBracket)
+	p.elements(len(listExpression.Elements), func(i int) { p.expression(listExpression.Elements[i]) }, lexer.RightBracket)
+}
+
+func (p *printer) VisitMapExpression(mapExpression *ast.MapExpression) {
+	p.token(lexer.LeftBrace)
+	p.elements(len(mapExpression.Keys), func(i int) {
+		p.expression(mapExpression.Keys[i])
+		p.token(lexer.Colon)
+		p.space = true
+		p.expression(mapExpression.Values[i])
+	}, lexer.RightBrace)
+}
+
+func (p *printer) VisitLiteralExpression(literalExpression *ast.LiteralExpression) {
+	p.token(literalExpression.Token.Type)
+}
+
+func (p *printer) VisitLogicalExpression(logicalExpression *ast.LogicalExpression) {
+	p.expression(logicalExpression.LHS)
+	p.space = true
+	p.token(logicalExpression.Operator.Type)
+	p.space = true
+	p.expression(logicalExpression.RHS)
+}
+
+func (p *printer) VisitSetExpression(setExpression *ast.SetExpression) {
+	p.expression(setExpression.Object)
+	p.token(lexer.Dot)
+	p.token(lexer.Identifier)
+	p.space = true
+	p.token(lexer.Equal)
+	p.space = true
+	p.expression(setExpression.Value)
+}
+
+// VisitStringifyExpression prints an interpolated expression, the parts of the string around it are printed by the
+// concatenation holding it
+func (p *printer) VisitStringifyExpression(stringifyExpression *ast.StringifyExpression) {
+	p.expression(stringifyExpression.Value)
+}
+
+func (p *printer) VisitSuperExpression(_ *ast.SuperExpression) {
+	p.token(lexer.Super)
+	p.token(lexer.Dot)
+	p.token(lexer.Identifier)
+}
+
+func (p *printer) VisitThisExpression(_ *ast.ThisExpression) {
+	p.token(lexer.This)
+}
+
+func (p *printer) VisitUnaryExpression(unaryExpression *ast.UnaryExpression) {
+	p.token(unaryExpression.Operator.Type)
+	p.expression(unaryExpression.RHS)
+}
+
+func (p *printer) VisitVariableExpression(_ *ast.VariableExpression) {
+	p.token(lexer.Identifier)
+}
+
+// VisitBlockStatement prints a block, or a for loop with an initializer which the parser wrapped in a block
+func (p *printer) VisitBlockStatement(blockStatement *ast.BlockStatement) {
+	if p.tokens[p.next].Type == lexer.LeftBrace {
+		p.block(blockStatement.Statements)
+		return
+	}
+
+	p.forLoop(blockStatement.Statements[0], blockStatement.Statements[1].(*ast.WhileStatement))
+}
+
+func (p *printer) VisitBreakStatement(breakStatement *ast.BreakStatement) {
+	p.token(lexer.Break)
+	if breakStatement.Label != nil {
+		p.space = true
+		p.token(lexer.Identifier)
+	}
+	p.token(lexer.Semicolon)
+}
+
+// VisitClassStatement prints the members of a class in the order of the source code
+func (p *printer) VisitClassStatement(classStatement *ast.ClassStatement) {
+	p.token(lexer.Class)
+	p.space = true
+	p.token(lexer.Identifier)
+	if classStatement.Superclass != nil {
+		p.space = true
+		p.token(lexer.Less)
+		p.space = true
+		p.token(lexer.Identifier)
+	}
+	p.space = true
+
+	type member struct {
+		name  lexer.Token
+		print func()
+	}
+	members := make([]member, 0)
+	for _, method := range classStatement.Methods {
+		method := method
+		members = append(members, member{name: method.Name, print: func() { p.function(method) }})
+	}
+	for _, method := range classStatement.ClassMethods {
+		method := method
+		members = append(members, member{name: method.Name, print: func() {
+			p.token(lexer.Class)
+			p.space = true
+			p.function(method)
+		}})
+	}
+	for _, field := range classStatement.ClassFields {
+		field := field
+		members = append(members, member{name: field.Name, print: func() {
+			p.token(lexer.Class)
+			p.space = true
+			field.Accept(p)
+		}})
+	}
+	for _, field := range classStatement.Fields {
+		field := field
+		members = append(members, member{name: field.Name, print: func() {
+			p.token(lexer.Identifier)
+			p.typeAnnotation(field.Type)
+			p.token(lexer.Semicolon)
+		}})
+	}
+	sort.Slice(members, func(i, j int) bool { return members[i].name.Span.Start.Offset < members[j].name.Span.Start.Offset })
+
+	p.token(lexer.LeftBrace)
+	if len(members) == 0 && !p.hasComment() {
+		p.token(lexer.RightBrace)
+		return
+	}
+	p.indent++
+	p.compact = true
+	for _, member := range members {
+		p.lineBreak = true
+		member.print()
+	}
+	p.closeBlock(lexer.RightBrace)
+}
+
+func (p *printer) VisitContinueStatement(continueStatement *ast.ContinueStatement) {
+	p.token(lexer.Continue)
+	if continueStatement.Label != nil {
+		p.space = true
+		p.token(lexer.Identifier)
+	}
+	p.token(lexer.Semicolon)
+}
+
+func (p *printer) VisitExpressionStatement(expressionStatement *ast.ExpressionStatement) {
+	p.expression(expressionStatement.Expression)
+	p.token(lexer.Semicolon)
+}
+
+func (p *printer) VisitForInStatement(forInStatement *ast.ForInStatement) {
+	p.label(forInStatement.Label)
+	p.token(lexer.For)
+	p.space = true
+	p.token(lexer.LeftParenthesis)
+	p.token(lexer.Identifier)
+	p.space = true
+	p.token(lexer.Identifier) // in
+	p.space = true
+	p.expression(forInStatement.Iterable)
+	p.token(lexer.RightParenthesis)
+	p.body(forInStatement.Body)
+}
+
+func (p *printer) VisitFunctionStatement(functionStatement *ast.FunctionStatement) {
+	p.token(lexer.Fun)
+	p.space = true
+	p.function(functionStatement)
+}
+
+// VisitIfStatement prints the else clause after the closing brace of a block, on the next line otherwise
+func (p *printer) VisitIfStatement(ifStatement *ast.IfStatement) {
+	p.token(lexer.If)
+	p.space = true
+	p.token(lexer.LeftParenthesis)
+	p.expression(ifStatement.Condition)
+	p.token(lexer.RightParenthesis)
+	p.body(ifStatement.ThenCode)
+
+	if ifStatement.ElseCode == nil {
+		return
+	}
+	if p.output[len(p.output)-1] == '}' {
+		p.space = true
+	} else {
+		p.lineBreak = true
+	}
+	p.token(lexer.Else)
+	p.body(ifStatement.ElseCode)
+}
+
+func (p *printer) VisitImportStatement(importStatement *ast.ImportStatement) {
+	p.token(importStatement.Keyword.Type)
+	p.space = true
+	p.token(lexer.String)
+	p.space = true
+	if importStatement.Alias != nil {
+		p.token(lexer.Identifier) // as
+		p.space = true
+		p.token(lexer.Identifier)
+	} else {
+		p.token(lexer.Import)
+		for i := range importStatement.Names {
+			if i > 0 {
+				p.token(lexer.Comma)
+			}
+			p.space = true
+			p.token(lexer.Identifier)
+		}
+	}
+	p.token(lexer.Semicolon)
+}
+
+func (p *printer) VisitPrintStatement(printStatement *ast.PrintStatement) {
+	p.token(lexer.Print)
+	p.space = true
+	p.expression(printStatement.Expression)
+	p.token(lexer.Semicolon)
+}
+
+func (p *printer) VisitReturnStatement(returnStatement *ast.ReturnStatement) {
+	p.token(lexer.Return)
+	if returnStatement.Value != nil {
+		p.space = true
+		p.expression(returnStatement.Value)
+	}
+	p.token(lexer.Semicolon)
+}
+
+func (p *printer) VisitThrowStatement(throwStatement *ast.ThrowStatement) {
+	p.token(lexer.Throw)
+	p.space = true
+	p.expression(throwStatement.Value)
+	p.token(lexer.Semicolon)
+}
+
+func (p *printer) VisitTryStatement(tryStatement *ast.TryStatement) {
+	p.token(lexer.Try)
+	p.space = true
+	p.block(tryStatement.Body)
+	if tryStatement.Catch != nil {
+		p.space = true
+		p.token(lexer.Catch)
+		p.space = true
+		p.token(lexer.LeftParenthesis)
+		p.token(lexer.Identifier)
+		p.token(lexer.RightParenthesis)
+		p.space = true
+		p.block(tryStatement.Catch.Body)
+	}
+	if tryStatement.Finally != nil {
+		p.space = true
+		p.token(lexer.Finally)
+		p.space = true
+		p.block(tryStatement.Finally.Statements)
+	}
+}
+
+func (p *printer) VisitVariableStatement(variableStatement *ast.VariableStatement) {
+	p.token(lexer.Var)
+	p.space = true
+	p.token(lexer.Identifier)
+	p.typeAnnotation(variableStatement.Type)
+	if variableStatement.Initializer != nil {
+		p.space = true
+		p.token(lexer.Equal)
+		p.space = true
+		p.expression(variableStatement.Initializer)
+	}
+	p.token(lexer.Semicolon)
+}
+
+// VisitWhileStatement prints a while loop, or a for loop without initializer which the parser desugared
+func (p *printer) VisitWhileStatement(whileStatement *ast.WhileStatement) {
+	if whileStatement.Keyword.Type == lexer.For {
+		p.forLoop(nil, whileStatement)
+		return
+	}
+
+	p.label(whileStatement.Label)
+	p.token(lexer.While)
+	p.space = true
+	p.token(lexer.LeftParenthesis)
+	p.expression(whileStatement.Condition)
+	p.token(lexer.RightParenthesis)
+	p.body(whileStatement.Body)
+}
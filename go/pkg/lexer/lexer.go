@@ -4,47 +4,98 @@ package lexer
 
 import (
 	"strconv"
+	"strings"
+	"unicode/utf8"
 
 	"github.com/fpotier/lox/go/pkg/loxerror"
 )
 
 type Lexer struct {
 	ErrorFormatter loxerror.ErrorFormatter
-	sourceCode     string
-	tokens         []Token
-	start          int
-	current        int
-	line           int
+	// file is the path of the source code, it locates the tokens with their span
+	file       string
+	sourceCode string
+	tokens     []Token
+	start      loxerror.Position
+	current    int
+	line       int
+	lineStart  int
+	// interpolations holds the number of braces opened in each interpolated expression being scanned, innermost last
+	interpolations []int
+	// comments are the trivia of the source code, they're kept for the tools reprinting it
+	comments []Token
 }
 
 func NewLexer(errorFormatter loxerror.ErrorFormatter, sourceCode string) *Lexer {
+	return NewFileLexer(errorFormatter, "", sourceCode)
+}
+
+// NewFileLexer creates a lexer for the source code read from a file
+func NewFileLexer(errorFormatter loxerror.ErrorFormatter, file string, sourceCode string) *Lexer {
 	return &Lexer{
 		ErrorFormatter: errorFormatter,
+		file:           file,
 		sourceCode:     sourceCode,
 		tokens:         make([]Token, 0),
-		start:          0,
+		start:          loxerror.Position{Line: 1, Column: 1, Offset: 0},
 		current:        0,
 		line:           1,
+		lineStart:      0,
+		interpolations: make([]int, 0),
+		comments:       make([]Token, 0),
 	}
 }
 
 func (l *Lexer) Tokens() []Token {
 	for !l.isAtEnd() {
-		l.start = l.current
+		l.start = l.position()
 		l.scanToken()
 	}
-	l.tokens = append(l.tokens, *NewToken(EOF, "", nil, l.line))
+	l.start = l.position()
+	l.tokens = append(l.tokens, *NewToken(EOF, "", nil, l.span()))
 
 	return l.tokens
 }
 
+// Comments returns the comments met by Tokens in the order of the source code, the text of a comment starts with "//"
+func (l *Lexer) Comments() []Token {
+	return l.comments
+}
+
 func (l *Lexer) addToken(kind TokenType) {
 	l.addTokenWithLiteral(kind, nil)
 }
 
 func (l *Lexer) addTokenWithLiteral(kind TokenType, literal Literal) {
-	text := l.sourceCode[l.start:l.current]
-	l.tokens = append(l.tokens, *NewToken(kind, text, literal, l.line))
+	text := l.sourceCode[l.start.Offset:l.current]
+	l.tokens = append(l.tokens, *NewToken(kind, text, literal, l.span()))
+}
+
+// position returns the location of the next character to be consumed
+func (l *Lexer) position() loxerror.Position {
+	return loxerror.Position{
+		Line:   l.line,
+		Column: l.current - l.lineStart + 1,
+		Offset: l.current,
+	}
+}
+
+// span returns the location of the lexeme being scanned
+func (l *Lexer) span() loxerror.Span {
+	return l.spanFrom(l.start)
+}
+
+// spanFrom returns the location of the source code from start to the next character to be consumed
+func (l *Lexer) spanFrom(start loxerror.Position) loxerror.Span {
+	span := loxerror.NewSpan(start, l.position())
+	span.File = l.file
+
+	return span
+}
+
+func (l *Lexer) newLine() {
+	l.line++
+	l.lineStart = l.current
 }
 
 func (l *Lexer) scanToken() {
@@ -54,17 +105,35 @@ func (l *Lexer) scanToken() {
 	case '\r':
 	case '\t':
 	case '\n':
-		l.line++
+		l.newLine()
 	case '(':
 		l.addToken(LeftParenthesis)
 	case ')':
 		l.addToken(RightParenthesis)
 	case '{':
+		if len(l.interpolations) > 0 {
+			l.interpolations[len(l.interpolations)-1]++
+		}
 		l.addToken(LeftBrace)
 	case '}':
+		if depth := len(l.interpolations); depth > 0 && l.interpolations[depth-1] == 0 {
+			// The end of an interpolated expression, the string literal goes on
+			l.interpolations = l.interpolations[:depth-1]
+			l.string()
+			return
+		}
+		if len(l.interpolations) > 0 {
+			l.interpolations[len(l.interpolations)-1]--
+		}
 		l.addToken(RightBrace)
+	case '[':
+		l.addToken(LeftBracket)
+	case ']':
+		l.addToken(RightBracket)
 	case ';':
 		l.addToken(Semicolon)
+	case ':':
+		l.addToken(Colon)
 	case ',':
 		l.addToken(Comma)
 	case '.':
@@ -84,6 +153,8 @@ func (l *Lexer) scanToken() {
 	case '=':
 		if l.match('=') {
 			l.addToken(EqualEqual)
+		} else if l.match('>') {
+			l.addToken(Arrow)
 		} else {
 			l.addToken(Equal)
 		}
@@ -104,6 +175,8 @@ func (l *Lexer) scanToken() {
 			for l.peek() != '\n' && !l.isAtEnd() {
 				l.advance()
 			}
+			text := strings.TrimSuffix(l.sourceCode[l.start.Offset:l.current], "\r")
+			l.comments = append(l.comments, *NewToken(Comment, text, nil, l.span()))
 		} else {
 			l.addToken(Slash)
 		}
@@ -116,7 +189,7 @@ func (l *Lexer) scanToken() {
 		case isAlpha(c):
 			l.identifier()
 		default:
-			l.ErrorFormatter.PushError(NewUnexpectedCharacter(l.line, c))
+			l.ErrorFormatter.PushError(NewUnexpectedCharacter(l.span(), c))
 		}
 	}
 }
@@ -125,6 +198,10 @@ func isDigit(character byte) bool {
 	return character >= '0' && character <= '9'
 }
 
+func isHexDigit(character byte) bool {
+	return isDigit(character) || (character >= 'a' && character <= 'f') || (character >= 'A' && character <= 'F')
+}
+
 func isAlpha(character byte) bool {
 	return (character >= 'a' && character <= 'z') ||
 		(character >= 'A' && character <= 'Z') ||
@@ -171,23 +248,94 @@ func (l *Lexer) peekNext() byte {
 	return l.sourceCode[l.current+1]
 }
 
+// string scans a string literal up to its closing quote or to its next interpolated expression
 func (l *Lexer) string() {
+	var value strings.Builder
 	for l.peek() != '"' && !l.isAtEnd() {
-		if l.peek() == '\n' {
-			l.line++
+		switch {
+		case l.peek() == '\\':
+			l.escapeSequence(&value)
+		case l.peek() == '$' && l.peekNext() == '{':
+			l.advance()
+			l.advance()
+			l.interpolations = append(l.interpolations, 0)
+			l.addTokenWithLiteral(Interpolation, &StringLiteral{Value: value.String()})
+			return
+		default:
+			c := l.advance()
+			if c == '\n' {
+				l.newLine()
+			}
+			value.WriteByte(c)
 		}
-
-		l.advance()
 	}
 
 	if l.isAtEnd() {
-		l.ErrorFormatter.PushError(NewUnterminatedString(l.line))
+		l.ErrorFormatter.PushError(NewUnterminatedString(l.span()))
 		return
 	}
 
 	l.advance() // the closing "
-	stringValue := l.sourceCode[l.start+1 : l.current-1]
-	l.addTokenWithLiteral(String, &StringLiteral{Value: stringValue})
+	l.addTokenWithLiteral(String, &StringLiteral{Value: value.String()})
+}
+
+// escapeSequence scans an escape sequence and writes the character it stands for.
+// An invalid sequence is reported at its location, the scanning goes on after it.
+func (l *Lexer) escapeSequence(value *strings.Builder) {
+	start := l.position()
+	l.advance() // the backslash
+	if l.isAtEnd() {
+		// The string is unterminated
+		return
+	}
+
+	c := l.advance()
+	switch c {
+	case 'n':
+		value.WriteByte('\n')
+	case 't':
+		value.WriteByte('\t')
+	case 'r':
+		value.WriteByte('\r')
+	case '0':
+		value.WriteByte(0)
+	case '"', '\\', '$':
+		value.WriteByte(c)
+	case 'u':
+		l.unicodeEscape(value, start)
+	default:
+		if c == '\n' {
+			l.newLine()
+		}
+		sequence := l.sourceCode[start.Offset:l.current]
+		l.ErrorFormatter.PushError(NewInvalidEscapeSequence(l.spanFrom(start), sequence))
+	}
+}
+
+// unicodeEscape scans the code point of a \u{XXXX} escape sequence, it has 1 to 6 hexadecimal digits
+func (l *Lexer) unicodeEscape(value *strings.Builder, start loxerror.Position) {
+	const maxDigits = 6
+
+	if !l.match('{') {
+		l.ErrorFormatter.PushError(NewInvalidEscapeSequence(l.spanFrom(start), l.sourceCode[start.Offset:l.current]))
+		return
+	}
+	digitsStart := l.current
+	for isHexDigit(l.peek()) {
+		l.advance()
+	}
+	digits := l.sourceCode[digitsStart:l.current]
+	if len(digits) == 0 || len(digits) > maxDigits || !l.match('}') {
+		l.ErrorFormatter.PushError(NewInvalidEscapeSequence(l.spanFrom(start), l.sourceCode[start.Offset:l.current]))
+		return
+	}
+
+	codePoint, _ := strconv.ParseUint(digits, 16, 32)
+	if !utf8.ValidRune(rune(codePoint)) {
+		l.ErrorFormatter.PushError(NewInvalidCodePoint(l.spanFrom(start), l.sourceCode[start.Offset:l.current]))
+		return
+	}
+	value.WriteRune(rune(codePoint))
 }
 
 // Lox number should only be of this form: (\d*)(\.?)(\d*)
@@ -205,9 +353,9 @@ func (l *Lexer) number() {
 		}
 	}
 
-	floatValue, err := strconv.ParseFloat(l.sourceCode[l.start:l.current], 64)
+	floatValue, err := strconv.ParseFloat(l.sourceCode[l.start.Offset:l.current], 64)
 	if err != nil {
-		l.ErrorFormatter.PushError(NewInvalidFloat(l.line, l.sourceCode[l.start:l.current]))
+		l.ErrorFormatter.PushError(NewInvalidFloat(l.span(), l.sourceCode[l.start.Offset:l.current]))
 		return
 	}
 	l.addTokenWithLiteral(Number, &NumberLiteral{Value: floatValue})
@@ -218,7 +366,7 @@ func (l *Lexer) identifier() {
 		l.advance()
 	}
 
-	text := l.sourceCode[l.start:l.current]
+	text := l.sourceCode[l.start.Offset:l.current]
 	if tokenType, ok := keywords[text]; ok {
 		l.addToken(tokenType)
 	} else {
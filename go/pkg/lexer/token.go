@@ -1,6 +1,11 @@
 package lexer
 
-import "fmt"
+import (
+	"fmt"
+	"sort"
+
+	"github.com/fpotier/lox/go/pkg/loxerror"
+)
 
 type TokenType int8
 
@@ -8,15 +13,18 @@ type Token struct {
 	Type    TokenType
 	Lexeme  string
 	Literal Literal
-	Line    int
+	// Line is the line on which the token starts, the full location is held by Span
+	Line int
+	Span loxerror.Span
 }
 
-func NewToken(kind TokenType, lexeme string, literal Literal, line int) *Token {
+func NewToken(kind TokenType, lexeme string, literal Literal, span loxerror.Span) *Token {
 	return &Token{
 		Type:    kind,
 		Lexeme:  lexeme,
 		Literal: literal,
-		Line:    line,
+		Line:    span.Start.Line,
+		Span:    span,
 	}
 }
 
@@ -33,11 +41,14 @@ const (
 	RightParenthesis
 	LeftBrace
 	RightBrace
+	LeftBracket
+	RightBracket
 	Comma
 	Dot
 	Dash
 	Plus
 	Semicolon
+	Colon
 	Slash
 	Star
 	Bang
@@ -48,50 +59,83 @@ const (
 	GreaterEqual
 	Less
 	LessEqual
+	Arrow
 
 	// Literals
 	Identifier
 	String
 	Number
+	// Interpolation is the part of a string literal before an interpolated expression ("...${ or }...${)
+	Interpolation
 
 	// Keywords
 	And
+	Break
+	Catch
 	Class
+	Continue
 	Else
 	False
+	Finally
+	From
 	Fun
 	For
 	If
+	Import
 	Nil
 	Or
 	Print
 	Return
 	Super
 	This
+	Throw
 	True
+	Try
 	Var
 	While
 
+	// Comment is a comment, the lexer keeps it apart from the tokens given to the parser
+	Comment
+
 	EOF
 )
 
 var keywords = map[string]TokenType{
-	"and":    And,
-	"class":  Class,
-	"else":   Else,
-	"false":  False,
-	"fun":    Fun,
-	"for":    For,
-	"if":     If,
-	"nil":    Nil,
-	"or":     Or,
-	"print":  Print,
-	"return": Return,
-	"super":  Super,
-	"this":   This,
-	"true":   True,
-	"var":    Var,
-	"while":  While,
+	"and":      And,
+	"break":    Break,
+	"catch":    Catch,
+	"class":    Class,
+	"continue": Continue,
+	"else":     Else,
+	"false":    False,
+	"finally":  Finally,
+	"from":     From,
+	"fun":      Fun,
+	"for":      For,
+	"if":       If,
+	"import":   Import,
+	"nil":      Nil,
+	"or":       Or,
+	"print":    Print,
+	"return":   Return,
+	"super":    Super,
+	"this":     This,
+	"throw":    Throw,
+	"true":     True,
+	"try":      Try,
+	"var":      Var,
+	"while":    While,
+}
+
+// Keywords returns the reserved words of the language, sorted
+func Keywords() []string {
+	words := make([]string, 0, len(keywords))
+	for word := range keywords {
+		words = append(words, word)
+	}
+	sort.Strings(words)
+
+	return words
 }
 
 var tokenRepresentation = []string{
@@ -99,11 +143,14 @@ var tokenRepresentation = []string{
 	"RIGHT_PARENTHESIS",
 	"LEFT_BRACE",
 	"RIGHT_BRACE",
+	"LEFT_BRACKET",
+	"RIGHT_BRACKET",
 	"COMMA",
 	"DOT",
 	"DASH",
 	"PLUS",
 	"SEMICOLON",
+	"COLON",
 	"SLASH",
 	"STAR",
 	"BANG",
@@ -114,24 +161,35 @@ var tokenRepresentation = []string{
 	"GREATER_EQUAL",
 	"LESS",
 	"LESS_EQUAL",
+	"ARROW",
 	"IDENTIFIER",
 	"STRING_LITERAL",
 	"NUMBER_LITERAL",
+	"INTERPOLATION",
 	"AND",
+	"BREAK",
+	"CATCH",
 	"CLASS",
+	"CONTINUE",
 	"ELSE",
 	"FALSE",
+	"FINALLY",
+	"FROM",
 	"FUN",
 	"FOR",
 	"IF",
+	"IMPORT",
 	"NIL",
 	"OR",
 	"PRINT",
 	"RETURN",
 	"SUPER",
 	"THIS",
+	"THROW",
 	"TRUE",
+	"TRY",
 	"VAR",
 	"WHILE",
+	"COMMENT",
 	"EOF",
 }
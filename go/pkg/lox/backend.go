@@ -0,0 +1,86 @@
+package lox
+
+import (
+	"context"
+
+	"github.com/fpotier/lox/go/pkg/ast"
+	"github.com/fpotier/lox/go/pkg/checker"
+	"github.com/fpotier/lox/go/pkg/loxerror"
+	"github.com/fpotier/lox/go/pkg/runtime"
+	"github.com/fpotier/lox/go/pkg/vm"
+)
+
+// Backend selects how the programs are evaluated
+type Backend uint8
+
+const (
+	// TreeWalker evaluates the AST directly
+	TreeWalker Backend = iota
+	// BytecodeVM compiles the AST to bytecode and runs it on a stack VM
+	BytecodeVM
+)
+
+type backend interface {
+	// prepare checks the statements and returns the function running them, or nil if they have static errors
+	prepare(statements []ast.Statement) func(ctx context.Context)
+	DefineNative(name string, arity int, code runtime.CallableCode)
+	SetGlobal(name string, value ast.LoxValue)
+	GetGlobal(name string) (ast.LoxValue, bool)
+	GlobalNames() []string
+}
+
+type treeWalker struct {
+	*runtime.Interpreter
+	// checker knows the global declarations of the previous evaluations
+	checker *checker.Checker
+}
+
+func (t treeWalker) prepare(statements []ast.Statement) func(ctx context.Context) {
+	runtime.NewResolver(t.ErrorFormatter, t.Interpreter).ResolveProgram(statements)
+	t.checker.CheckProgram(statements)
+	if t.ErrorFormatter.HasErrors() {
+		return nil
+	}
+
+	return func(ctx context.Context) { t.EvalContext(ctx, statements) }
+}
+
+type bytecodeVM struct {
+	*vm.VM
+	// checker knows the global declarations of the previous evaluations
+	checker *checker.Checker
+}
+
+func (b bytecodeVM) prepare(statements []ast.Statement) func(ctx context.Context) {
+	compiler := vm.NewCompiler(b.ErrorFormatter)
+	runtime.NewResolver(b.ErrorFormatter, compiler).ResolveProgram(statements)
+	b.checker.CheckProgram(statements)
+	if b.ErrorFormatter.HasErrors() {
+		return nil
+	}
+
+	function := compiler.Compile(statements)
+	if function == nil {
+		return nil
+	}
+
+	return func(ctx context.Context) { b.EvalContext(ctx, function) }
+}
+
+// newBackend creates the backend selected by the settings
+func newBackend(s settings, errorFormatter loxerror.ErrorFormatter) backend {
+	if s.backend == BytecodeVM {
+		machine := vm.NewVM(s.stdout, errorFormatter)
+		machine.InputStream = s.stdin
+		machine.MaxCallDepth = s.maxCallDepth
+		machine.StepBudget = s.stepBudget
+		return bytecodeVM{VM: machine, checker: checker.NewChecker(errorFormatter)}
+	}
+
+	interpreter := runtime.NewInterpreter(s.stdout, errorFormatter)
+	interpreter.InputStream = s.stdin
+	interpreter.MaxCallDepth = s.maxCallDepth
+	interpreter.StepBudget = s.stepBudget
+
+	return treeWalker{Interpreter: interpreter, checker: checker.NewChecker(errorFormatter)}
+}
@@ -0,0 +1,211 @@
+// Package lox runs Lox scripts from Go programs
+package lox
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"time"
+
+	"github.com/fpotier/lox/go/pkg/ast"
+	"github.com/fpotier/lox/go/pkg/lexer"
+	"github.com/fpotier/lox/go/pkg/loxerror"
+	"github.com/fpotier/lox/go/pkg/parser"
+	"github.com/fpotier/lox/go/pkg/runtime"
+)
+
+// Engine evaluates Lox source code, the global state is kept from one evaluation to the next
+type Engine struct {
+	errorFormatter loxerror.ErrorFormatter
+	backend        backend
+	timeout        time.Duration
+	settings       settings
+}
+
+// settings are set by the options and used to create the backend
+type settings struct {
+	backend      Backend
+	stdout       io.Writer
+	stdin        io.Reader
+	maxCallDepth int
+	stepBudget   int
+}
+
+func NewEngine(options ...Option) *Engine {
+	engine := Engine{
+		errorFormatter: loxerror.NewTextErrorFormatter(false),
+		backend:        nil,
+		timeout:        0,
+		settings: settings{
+			backend:      TreeWalker,
+			stdout:       os.Stdout,
+			stdin:        os.Stdin,
+			maxCallDepth: ast.Limits.MaxCallDepth,
+			stepBudget:   0,
+		},
+	}
+
+	for _, option := range options {
+		option(&engine)
+	}
+	engine.backend = newBackend(engine.settings, engine.errorFormatter)
+
+	return &engine
+}
+
+func (e *Engine) Eval(sourceCode string) error {
+	return e.EvalContext(context.Background(), sourceCode)
+}
+
+// EvalContext evaluates the source code until it completes or the context is done.
+// The returned error is an *Error if the script is invalid or failed at runtime.
+func (e *Engine) EvalContext(ctx context.Context, sourceCode string) error {
+	return e.eval(ctx, "", sourceCode)
+}
+
+func (e *Engine) EvalFile(path string) error {
+	return e.EvalFileContext(context.Background(), path)
+}
+
+// EvalFileContext evaluates a script file, the errors are reported in this file and relative imports are resolved from
+// its directory.
+func (e *Engine) EvalFileContext(ctx context.Context, path string) error {
+	sourceCode, err := os.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("failed to read the script: %w", err)
+	}
+
+	return e.eval(ctx, path, string(sourceCode))
+}
+
+// ResultVariable is the global variable holding the value of the last input of an interactive session
+const ResultVariable = "_"
+
+// EvalInput evaluates an input of an interactive session, the name identifies its source code in the errors.
+// The value of a final expression statement is returned and assigned to ResultVariable, the returned value is nil if
+// the input doesn't end with an expression statement.
+func (e *Engine) EvalInput(ctx context.Context, name string, sourceCode string) (ast.LoxValue, error) {
+	statements, err := e.parse(name, sourceCode)
+	if err != nil {
+		return nil, err
+	}
+
+	last := len(statements) - 1
+	if last < 0 {
+		return nil, e.run(ctx, statements)
+	}
+	expressionStatement, ok := statements[last].(*ast.ExpressionStatement)
+	if !ok {
+		return nil, e.run(ctx, statements)
+	}
+	span := loxerror.Span{}
+	if position := ast.ExpressionPosition(expressionStatement.Expression); position != nil {
+		span = position.Span
+	}
+	result := lexer.NewToken(lexer.Identifier, ResultVariable, nil, span)
+	statements[last] = ast.NewVariableStatement(*result, nil, expressionStatement.Expression)
+	if err := e.run(ctx, statements); err != nil {
+		return nil, err
+	}
+	value, _ := e.backend.GetGlobal(ResultVariable)
+
+	return value, nil
+}
+
+// eval evaluates the source code of a file, the file is empty if the source code wasn't read from a file
+func (e *Engine) eval(ctx context.Context, file string, sourceCode string) error {
+	statements, err := e.parse(file, sourceCode)
+	if err != nil {
+		return err
+	}
+
+	return e.run(ctx, statements)
+}
+
+// parse returns the statements of the source code of a file, or an *Error reporting its syntax errors
+func (e *Engine) parse(file string, sourceCode string) ([]ast.Statement, error) {
+	e.errorFormatter.Reset()
+	if sourceFormatter, ok := e.errorFormatter.(loxerror.SourceFormatter); ok {
+		sourceFormatter.SetSource(file, sourceCode)
+	}
+
+	tokens := lexer.NewFileLexer(e.errorFormatter, file, sourceCode).Tokens()
+	statements := parser.NewParser(e.errorFormatter, tokens).Parse()
+	if e.errorFormatter.HasErrors() {
+		return nil, newError(e.errorFormatter, false)
+	}
+
+	return statements, nil
+}
+
+// run checks and runs the statements until they complete or the context is done
+func (e *Engine) run(ctx context.Context, statements []ast.Statement) error {
+	run := e.backend.prepare(statements)
+	if run == nil {
+		return newError(e.errorFormatter, false)
+	}
+
+	if e.timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, e.timeout)
+		defer cancel()
+	}
+
+	run(ctx)
+	if e.errorFormatter.HasErrors() {
+		return newError(e.errorFormatter, true)
+	}
+
+	return nil
+}
+
+// DefineNative registers a Go function callable from the scripts.
+// An error returned by the function is raised as a runtime error at the call site.
+func (e *Engine) DefineNative(name string, arity int, code runtime.CallableCode) {
+	e.backend.DefineNative(name, arity, code)
+}
+
+func (e *Engine) SetGlobal(name string, value ast.LoxValue) {
+	e.backend.SetGlobal(name, value)
+}
+
+// Expose makes a Go value available to the scripts as a global variable, see runtime.ToLoxValue
+func (e *Engine) Expose(name string, value any) error {
+	loxValue, err := runtime.ToNamedLoxValue(name, value)
+	if err != nil {
+		return err
+	}
+	e.backend.SetGlobal(name, loxValue)
+
+	return nil
+}
+
+func (e *Engine) GetGlobal(name string) (ast.LoxValue, bool) {
+	return e.backend.GetGlobal(name)
+}
+
+// GlobalNames returns the sorted names of the global variables, the native functions included
+func (e *Engine) GlobalNames() []string {
+	return e.backend.GlobalNames()
+}
+
+// Reset discards the global state, the next evaluations start with a new backend having only the native functions.
+// The globals defined by DefineNative, SetGlobal and Expose are discarded too.
+func (e *Engine) Reset() {
+	e.errorFormatter.Reset()
+	e.backend = newBackend(e.settings, e.errorFormatter)
+}
+
+func (e *Engine) ErrorFormatter() loxerror.ErrorFormatter {
+	return e.errorFormatter
+}
+
+// Interpreter gives access to the underlying tree-walking interpreter, it's nil if the engine runs on the bytecode VM
+func (e *Engine) Interpreter() *runtime.Interpreter {
+	if treeWalker, ok := e.backend.(treeWalker); ok {
+		return treeWalker.Interpreter
+	}
+
+	return nil
+}
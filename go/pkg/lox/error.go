@@ -0,0 +1,35 @@
+package lox
+
+import (
+	"strings"
+
+	"github.com/fpotier/lox/go/pkg/loxerror"
+)
+
+// Error gathers the errors reported by a failed evaluation
+type Error struct {
+	Errors []loxerror.LoxError
+	// Runtime is false when the errors were found before running the script (lexing, parsing and resolution)
+	Runtime bool
+	message string
+}
+
+func (e *Error) Error() string {
+	return e.message
+}
+
+func newError(errorFormatter loxerror.ErrorFormatter, runtime bool) *Error {
+	var message strings.Builder
+	errors := make([]loxerror.LoxError, 0)
+	for errorFormatter.HasErrors() {
+		loxError, _ := errorFormatter.PopError()
+		errors = append(errors, loxError)
+		message.WriteString(errorFormatter.Format(loxError))
+	}
+
+	return &Error{
+		Errors:  errors,
+		Runtime: runtime,
+		message: strings.TrimSuffix(message.String(), "\n"),
+	}
+}
@@ -0,0 +1,275 @@
+package lox_test
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/fpotier/lox/go/pkg/ast"
+	"github.com/fpotier/lox/go/pkg/lox"
+	"github.com/fpotier/lox/go/pkg/runtime"
+)
+
+func ExampleEngine_Eval() {
+	engine := lox.NewEngine(lox.WithStdout(os.Stdout))
+	if err := engine.Eval(`var greeting = "Hello";`); err != nil {
+		panic(err)
+	}
+
+	// The globals are kept from one evaluation to the next
+	if err := engine.Eval(`print greeting + " world";`); err != nil {
+		panic(err)
+	}
+
+	var loxError *lox.Error
+	if err := engine.Eval(`print greeting + 1;`); errors.As(err, &loxError) {
+		fmt.Println(loxError.Runtime, loxError.Errors[0].Message())
+	}
+	// Output:
+	// Hello world
+	// true Operator '+': incompatible types 'string' and 'number'
+}
+
+func ExampleEngine_DefineNative() {
+	engine := lox.NewEngine(lox.WithStdout(os.Stdout))
+	engine.SetGlobal("limit", ast.NewNumberValue(10))
+	engine.DefineNative("double", 1, func(_ runtime.Evaluator, args []ast.LoxValue) (ast.LoxValue, error) {
+		number, ok := args[0].(*ast.NumberValue)
+		if !ok {
+			return nil, errors.New("expected a number")
+		}
+
+		return ast.NewNumberValue(2 * number.Value), nil
+	})
+
+	if err := engine.Eval(`var doubled = double(limit); print doubled;`); err != nil {
+		panic(err)
+	}
+	doubled, _ := engine.GetGlobal("doubled")
+	fmt.Println(doubled.Kind() == ast.Number, doubled)
+
+	var loxError *lox.Error
+	if err := engine.Eval(`double("ten");`); errors.As(err, &loxError) {
+		fmt.Println(loxError.Errors[0].Line(), loxError.Errors[0].Message())
+	}
+	// Output:
+	// 20
+	// true 20
+	// 1 Native function 'double' failed: expected a number
+}
+
+type Account struct {
+	Owner   string
+	Balance float64
+	History []float64
+}
+
+func (a *Account) Deposit(amount float64) error {
+	if amount <= 0 {
+		return errors.New("the amount must be positive")
+	}
+	a.Balance += amount
+	a.History = append(a.History, amount)
+
+	return nil
+}
+
+func ExampleEngine_Expose() {
+	account := &Account{Owner: "Ada", Balance: 0, History: nil}
+	engine := lox.NewEngine(lox.WithStdout(os.Stdout))
+	if err := engine.Expose("account", account); err != nil {
+		panic(err)
+	}
+	if err := engine.Expose("upper", strings.ToUpper); err != nil {
+		panic(err)
+	}
+
+	err := engine.Eval(`
+		account.Deposit(20);
+		account.Deposit(22);
+		account.Owner = upper(account.Owner);
+		print account.Owner;
+		print account.History.len();
+	`)
+	fmt.Println(err)
+	err = engine.Eval(`
+		print account.History[1];
+		account.Deposit(-1);
+	`)
+	fmt.Println(err.(*lox.Error).Errors[0].Message())
+	fmt.Println(account.Owner, account.Balance)
+	// Output:
+	// ADA
+	// 2
+	// <nil>
+	// 22
+	// Native function 'Deposit' failed: the amount must be positive
+	// ADA 42
+}
+
+type Address struct {
+	City string
+}
+
+func (a *Address) Move(city string) {
+	a.City = city
+}
+
+type Person struct {
+	Name   string
+	Home   Address
+	Tags   []string
+	Scores map[string]int
+}
+
+func ExampleEngine_Expose_nestedStruct() {
+	person := &Person{Name: "Ada", Home: Address{City: "London"}, Tags: nil, Scores: nil}
+	engine := lox.NewEngine(lox.WithStdout(os.Stdout))
+	if err := engine.Expose("person", person); err != nil {
+		panic(err)
+	}
+
+	// The struct fields of an exposed pointer are shared with the Go value
+	err := engine.Eval(`
+		var home = person.Home;
+		home.City = "Paris";
+		print person.Home.City;
+	`)
+	fmt.Println(err, person.Home.City)
+	err = engine.Eval(`person.Home.Move("Rome");`)
+	fmt.Println(err, person.Home.City)
+	// Output:
+	// Paris
+	// <nil> Paris
+	// <nil> Rome
+}
+
+func ExampleEngine_Expose_collections() {
+	person := &Person{
+		Name:   "Ada",
+		Home:   Address{City: "London"},
+		Tags:   []string{"math"},
+		Scores: map[string]int{"chess": 3, "bridge": 5},
+	}
+	engine := lox.NewEngine(lox.WithStdout(os.Stdout))
+	if err := engine.Expose("person", person); err != nil {
+		panic(err)
+	}
+
+	// The slices and the maps are copied in lists and maps, assigning them writes them back
+	err := engine.Eval(`
+		print person.Tags;
+		print person.Scores;
+		var tags = person.Tags;
+		tags.push("poetry");
+		for (tag in tags) print tag;
+		person.Tags = tags;
+		var scores = person.Scores;
+		scores["go"] = scores["chess"] + 1;
+		person.Scores = scores;
+	`)
+	fmt.Println(err, person.Tags, person.Scores)
+	// Output:
+	// [math]
+	// {bridge: 5, chess: 3}
+	// math
+	// poetry
+	// <nil> [math poetry] map[bridge:5 chess:3 go:4]
+}
+
+type Counter struct {
+	owner  *string
+	counts map[string]int
+}
+
+func (c *Counter) Add(name string) {
+	c.counts[name]++
+}
+
+func (c *Counter) String() string {
+	return *c.owner + "'s counter"
+}
+
+func ExampleEngine_Expose_panics() {
+	engine := lox.NewEngine(lox.WithStdout(os.Stdout))
+	if err := engine.Expose("counter", &Counter{owner: nil, counts: nil}); err != nil {
+		panic(err)
+	}
+
+	// The panics of the Go methods are runtime errors
+	var loxError *lox.Error
+	if err := engine.Eval(`counter.Add("Ada");`); errors.As(err, &loxError) {
+		fmt.Println(loxError.Errors[0].Message())
+	}
+	if err := engine.Eval(`print counter;`); errors.As(err, &loxError) {
+		fmt.Println(loxError.Errors[0].Message())
+	}
+
+	// A function returning several values can't be exposed
+	err := engine.Expose("divide", func(a int, b int) (int, int) { return a / b, a % b })
+	fmt.Println(err)
+	// Output:
+	// Native function 'Add' failed: panic: assignment to entry in nil map
+	// Native function 'String' failed: panic: runtime error: invalid memory address or nil pointer dereference
+	// unsupported type: func(int, int) (int, int) returns several values
+}
+
+func ExampleWithBackend() {
+	engine := lox.NewEngine(lox.WithStdout(os.Stdout), lox.WithBackend(lox.BytecodeVM))
+	if err := engine.Expose("account", &Account{Owner: "Ada", Balance: 0, History: nil}); err != nil {
+		panic(err)
+	}
+
+	err := engine.Eval(`
+		fun counter() {
+			var count = 0;
+			fun increment() {
+				count = count + 1;
+				return count;
+			}
+			return increment;
+		}
+
+		var next = counter();
+		next();
+		account.Deposit(next());
+		print account.Balance;
+	`)
+	fmt.Println(err)
+	// Output:
+	// 2
+	// <nil>
+}
+
+func ExampleEngine_Reset() {
+	engine := lox.NewEngine(lox.WithStdout(os.Stdout))
+	if err := engine.Eval(`var count = 1;`); err != nil {
+		panic(err)
+	}
+
+	engine.Reset()
+	_, defined := engine.GetGlobal("count")
+	fmt.Println(defined)
+	// Output:
+	// false
+}
+
+func ExampleEngine_Eval_typeAnnotations() {
+	engine := lox.NewEngine(lox.WithStdout(os.Stdout))
+	if err := engine.Eval(`class Point {} fun double(x: number): number { return x * 2; }`); err != nil {
+		panic(err)
+	}
+
+	// The declarations of the previous evaluations are known to the type checker
+	if err := engine.Eval(`var origin: Point = Point(); print double(2);`); err != nil {
+		panic(err)
+	}
+	var loxError *lox.Error
+	if err := engine.Eval(`double("two");`); errors.As(err, &loxError) {
+		fmt.Println(loxError.Runtime, loxError.Errors[0].Kind(), loxError.Errors[0].Message())
+	}
+	// Output:
+	// 4
+	// false TypeError Type mismatch for parameter 'x' of 'double': expected 'number' but got 'string'
+}
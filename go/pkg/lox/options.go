@@ -0,0 +1,46 @@
+package lox
+
+import (
+	"io"
+	"time"
+
+	"github.com/fpotier/lox/go/pkg/loxerror"
+)
+
+type Option func(*Engine)
+
+// WithStdout sets where the print statements write, os.Stdout by default
+func WithStdout(stdout io.Writer) Option {
+	return func(e *Engine) { e.settings.stdout = stdout }
+}
+
+// WithStdin sets where the scripts read their input, os.Stdin by default
+func WithStdin(stdin io.Reader) Option {
+	return func(e *Engine) { e.settings.stdin = stdin }
+}
+
+// WithErrorFormatter sets how the errors are rendered by Error.Error, a text formatter by default
+func WithErrorFormatter(errorFormatter loxerror.ErrorFormatter) Option {
+	return func(e *Engine) { e.errorFormatter = errorFormatter }
+}
+
+// WithMaxCallDepth sets the number of nested calls after which a stack overflow is raised, 0 means no limit
+func WithMaxCallDepth(maxCallDepth int) Option {
+	return func(e *Engine) { e.settings.maxCallDepth = maxCallDepth }
+}
+
+// WithStepBudget sets the number of steps each evaluation may run, 0 means no limit.
+// A step is an evaluated statement or expression for the tree walker and an executed instruction for the VM.
+func WithStepBudget(stepBudget int) Option {
+	return func(e *Engine) { e.settings.stepBudget = stepBudget }
+}
+
+// WithTimeout interrupts each evaluation running longer than the timeout, 0 means no limit
+func WithTimeout(timeout time.Duration) Option {
+	return func(e *Engine) { e.timeout = timeout }
+}
+
+// WithBackend selects how the programs are evaluated, TreeWalker by default
+func WithBackend(backend Backend) Option {
+	return func(e *Engine) { e.settings.backend = backend }
+}
@@ -0,0 +1,36 @@
+package loxerror
+
+import "errors"
+
+var ErrEmptyErrorStack = errors.New("empty error stack")
+
+// errorQueue holds the errors waiting to be formatted, in the order they were pushed
+type errorQueue struct {
+	errors []LoxError
+}
+
+func (q *errorQueue) PushError(e LoxError) {
+	q.errors = append(q.errors, e)
+}
+
+func (q *errorQueue) PopError() (LoxError, error) {
+	if !q.HasErrors() {
+		return nil, ErrEmptyErrorStack
+	}
+
+	err := q.errors[0]
+	q.errors = q.errors[1:]
+	return err, nil
+}
+
+func (q *errorQueue) HasErrors() bool {
+	return len(q.errors) > 0
+}
+
+func (q *errorQueue) Errors() []LoxError {
+	return q.errors
+}
+
+func (q *errorQueue) Reset() {
+	q.errors = q.errors[:0]
+}
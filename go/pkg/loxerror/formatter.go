@@ -7,3 +7,10 @@ type ErrorFormatter interface {
 	HasErrors() bool
 	Reset()
 }
+
+// SourceFormatter is implemented by the formatters quoting the faulty source code
+type SourceFormatter interface {
+	ErrorFormatter
+	// SetSource gives the source code of a file, the file is empty for the source code not read from a file
+	SetSource(file string, sourceCode string)
+}
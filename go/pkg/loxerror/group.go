@@ -0,0 +1,35 @@
+package loxerror
+
+// errorGroup is several errors raised at once, like the static errors of an imported module.
+// It's seen as its first error until it's split to be reported.
+type errorGroup struct {
+	LoxError
+	errors []LoxError
+}
+
+// Group returns an error raising several errors at once, a single error is returned as is
+func Group(errors []LoxError) LoxError {
+	if len(errors) == 1 {
+		return errors[0]
+	}
+
+	return &errorGroup{LoxError: errors[0], errors: errors}
+}
+
+// Split returns the errors of a group, they get the stack trace attached to the group
+func Split(e LoxError) []LoxError {
+	group, ok := Unwrap(e).(*errorGroup)
+	if !ok {
+		return []LoxError{e}
+	}
+
+	errors := make([]LoxError, len(group.errors))
+	copy(errors, group.errors)
+	if stackTrace := StackTraceOf(e); stackTrace != nil {
+		for index, err := range errors {
+			errors[index] = WithStackTrace(err, stackTrace)
+		}
+	}
+
+	return errors
+}
@@ -3,40 +3,19 @@ package loxerror
 import (
 	"bytes"
 	"encoding/json"
-	"errors"
 	"fmt"
 )
 
-var ErrEmptyErrorStack = errors.New("empty error stack")
-
 type JSONErrorFormatter struct {
-	errors []LoxError
+	errorQueue
 }
 
 func NewJSONErrorFormatter() *JSONErrorFormatter {
 	return &JSONErrorFormatter{
-		errors: make([]LoxError, 0),
+		errorQueue: errorQueue{errors: make([]LoxError, 0)},
 	}
 }
 
-func (f *JSONErrorFormatter) PushError(e LoxError) {
-	f.errors = append(f.errors, e)
-}
-
-func (f *JSONErrorFormatter) PopError() (LoxError, error) {
-	if !f.HasErrors() {
-		return nil, ErrEmptyErrorStack
-	}
-
-	err := f.errors[0]
-	f.errors = f.errors[1:]
-	return err, nil
-}
-
-func (f *JSONErrorFormatter) HasErrors() bool {
-	return len(f.errors) > 0
-}
-
 func (f *JSONErrorFormatter) Format(e LoxError) string {
 	rawString, err := MarshalJSON(e)
 	if err != nil {
@@ -46,24 +25,25 @@ func (f *JSONErrorFormatter) Format(e LoxError) string {
 	return string(rawString)
 }
 
-func (f *JSONErrorFormatter) Errors() []LoxError {
-	return f.errors
-}
-
-func (f *JSONErrorFormatter) Reset() {
-	f.errors = f.errors[:0]
-}
-
 func MarshalJSON[T LoxError](e T) ([]byte, error) {
 	var buffer bytes.Buffer
 	encoder := json.NewEncoder(&buffer)
 	encoder.SetEscapeHTML(false)
 
-	if err := encoder.Encode(map[string]any{
+	fields := map[string]any{
 		"line":    e.Line(),
+		"span":    e.Span(),
 		"type":    e.Kind(),
 		"message": e.Message(),
-	}); err != nil {
+	}
+	if file := e.Span().File; file != "" {
+		fields["file"] = file
+	}
+	if stackTrace := StackTraceOf(e); len(stackTrace) > 0 {
+		fields["stack"] = stackTrace
+	}
+
+	if err := encoder.Encode(fields); err != nil {
 		return nil, fmt.Errorf("failed to encode the error message: %w", err)
 	}
 
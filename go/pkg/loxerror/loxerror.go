@@ -2,6 +2,7 @@ package loxerror
 
 type LoxError interface {
 	Line() int
+	Span() Span
 	Kind() string
 	Message() string
 }
@@ -0,0 +1,179 @@
+package loxerror
+
+import (
+	"testing"
+)
+
+type testError struct {
+	span Span
+}
+
+func (e testError) Line() int       { return e.span.Start.Line }
+func (e testError) Span() Span      { return e.span }
+func (e testError) Kind() string    { return "TestError" }
+func (e testError) Message() string { return "something is wrong" }
+
+func newTestError(file string, startLine int, startColumn int, endLine int, endColumn int) testError {
+	return testError{span: Span{
+		File:  file,
+		Start: Position{Line: startLine, Column: startColumn, Offset: 0},
+		End:   Position{Line: endLine, Column: endColumn, Offset: 0},
+	}}
+}
+
+func TestTextErrorFormatter(t *testing.T) {
+	tests := []struct {
+		name       string
+		sourceCode string
+		color      bool
+		err        LoxError
+		expected   string
+	}{
+		{
+			name:       "caret width",
+			sourceCode: "var answer = 42;",
+			color:      false,
+			err:        newTestError("", 1, 5, 1, 11),
+			expected: "error[TestError]: something is wrong\n" +
+				" --> line 1, column 5\n" +
+				"  |\n" +
+				"1 | var answer = 42;\n" +
+				"  |     ^^^^^^\n",
+		},
+		{
+			name:       "empty span",
+			sourceCode: "print x;",
+			color:      false,
+			err:        newTestError("", 1, 7, 1, 7),
+			expected: "error[TestError]: something is wrong\n" +
+				" --> line 1, column 7\n" +
+				"  |\n" +
+				"1 | print x;\n" +
+				"  |       ^\n",
+		},
+		{
+			name:       "file and wide gutter",
+			sourceCode: "\n\n\n\n\n\n\n\n\nprint é + 1;",
+			color:      false,
+			err:        newTestError("script.lox", 10, 7, 10, 9),
+			expected: "error[TestError]: something is wrong\n" +
+				"  --> script.lox, line 10, column 7\n" +
+				"   |\n" +
+				"10 | print é + 1;\n" +
+				"   |       ^\n",
+		},
+		{
+			name:       "tabs",
+			sourceCode: "\t\tx = 1;",
+			color:      false,
+			err:        newTestError("", 1, 3, 1, 4),
+			expected: "error[TestError]: something is wrong\n" +
+				" --> line 1, column 3\n" +
+				"  |\n" +
+				"1 | \t\tx = 1;\n" +
+				"  | \t\t^\n",
+		},
+		{
+			name:       "several lines",
+			sourceCode: "print \"abc\ndef\";",
+			color:      false,
+			err:        newTestError("", 1, 7, 2, 5),
+			expected: "error[TestError]: something is wrong\n" +
+				" --> line 1, column 7\n" +
+				"  |\n" +
+				"1 | print \"abc\n" +
+				"  |       ^^^^\n",
+		},
+		{
+			name:       "end of file",
+			sourceCode: "print 1",
+			color:      false,
+			err:        newTestError("", 1, 8, 1, 8),
+			expected: "error[TestError]: something is wrong\n" +
+				" --> line 1, column 8\n" +
+				"  |\n" +
+				"1 | print 1\n" +
+				"  |        ^\n",
+		},
+		{
+			name:       "line after the end of file",
+			sourceCode: "print 1",
+			color:      false,
+			err:        newTestError("", 2, 1, 2, 1),
+			expected: "error[TestError]: something is wrong\n" +
+				" --> line 2, column 1\n",
+		},
+		{
+			name:       "carriage return",
+			sourceCode: "print 1\r\nprint 2\r\n",
+			color:      false,
+			err:        newTestError("", 2, 7, 2, 8),
+			expected: "error[TestError]: something is wrong\n" +
+				" --> line 2, column 7\n" +
+				"  |\n" +
+				"2 | print 2\n" +
+				"  |       ^\n",
+		},
+		{
+			name:       "color",
+			sourceCode: "nil();",
+			color:      true,
+			err:        newTestError("", 1, 1, 1, 4),
+			expected: ansiBold + ansiRed + "error[TestError]" + ansiReset + ansiBold + ": something is wrong" + ansiReset + "\n" +
+				" " + ansiBlue + "-->" + ansiReset + " line 1, column 1\n" +
+				"  " + ansiBlue + "|" + ansiReset + "\n" +
+				ansiBlue + "1" + ansiReset + " " + ansiBlue + "|" + ansiReset + " nil();\n" +
+				"  " + ansiBlue + "|" + ansiReset + " " + ansiBold + ansiRed + "^^^" + ansiReset + "\n",
+		},
+		{
+			name:       "stack trace",
+			sourceCode: "fun f() { f(); }",
+			color:      false,
+			err: WithStackTrace(newTestError("", 1, 11, 1, 14), []StackFrame{
+				{Function: "f", Line: 1, Repeated: 2},
+				{Function: "script", Line: 3, Repeated: 0},
+			}),
+			expected: "error[TestError]: something is wrong\n" +
+				" --> line 1, column 11\n" +
+				"  |\n" +
+				"1 | fun f() { f(); }\n" +
+				"  |           ^^^\n" +
+				"  = stack trace (most recent call first):\n" +
+				"      at f, called from line 1 (repeated 2 more times)\n" +
+				"      at script, called from line 3\n",
+		},
+	}
+
+	for _, test := range tests {
+		test := test
+		t.Run(test.name, func(t *testing.T) {
+			formatter := NewTextErrorFormatter(test.color)
+			formatter.SetSource(test.err.Span().File, test.sourceCode)
+			if actual := formatter.Format(test.err); actual != test.expected {
+				t.Errorf("expected:\n%q\nactual:\n%q", test.expected, actual)
+			}
+		})
+	}
+}
+
+func TestSplit(t *testing.T) {
+	first, second := newTestError("", 1, 1, 1, 2), newTestError("", 2, 1, 2, 2)
+	if errors := Split(first); len(errors) != 1 || errors[0] != first {
+		t.Errorf("expected a single error, got %v", errors)
+	}
+
+	stackTrace := []StackFrame{{Function: "load", Line: 3, Repeated: 0}}
+	group := WithStackTrace(Group([]LoxError{first, second}), stackTrace)
+	if group.Line() != 1 {
+		t.Errorf("expected the group to be seen as its first error, got line %d", group.Line())
+	}
+	errors := Split(group)
+	if len(errors) != 2 || Unwrap(errors[0]) != first || Unwrap(errors[1]) != second {
+		t.Fatalf("unexpected errors: %v", errors)
+	}
+	for _, err := range errors {
+		if frames := StackTraceOf(err); len(frames) != 1 || frames[0] != stackTrace[0] {
+			t.Errorf("expected the stack trace of the group, got %v", frames)
+		}
+	}
+}
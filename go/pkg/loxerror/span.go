@@ -0,0 +1,21 @@
+package loxerror
+
+// Position locates a byte of the source code.
+// Line and Column are 1-based, Offset is the 0-based byte offset.
+type Position struct {
+	Line   int `json:"line"`
+	Column int `json:"column"`
+	Offset int `json:"offset"`
+}
+
+// Span covers the source code between Start (inclusive) and End (exclusive).
+// File is the path of the source file, it's empty for the source code not read from a file.
+type Span struct {
+	File  string   `json:"-"`
+	Start Position `json:"start"`
+	End   Position `json:"end"`
+}
+
+func NewSpan(start Position, end Position) Span {
+	return Span{File: "", Start: start, End: end}
+}
@@ -0,0 +1,58 @@
+package loxerror
+
+// StackFrame is a function call which was in progress when a runtime error occurred
+type StackFrame struct {
+	Function string `json:"function"`
+	// Line is the line of the call site
+	Line int `json:"line"`
+	// Repeated counts the identical calls collapsed into this frame (e.g. by a recursion)
+	Repeated int `json:"repeated,omitempty"`
+}
+
+// PushFrame appends a frame to a stack trace, it's collapsed with the previous frame if they are identical
+func PushFrame(stackTrace []StackFrame, function string, line int) []StackFrame {
+	if last := len(stackTrace) - 1; last >= 0 && stackTrace[last].Function == function && stackTrace[last].Line == line {
+		stackTrace[last].Repeated++
+		return stackTrace
+	}
+
+	return append(stackTrace, StackFrame{Function: function, Line: line, Repeated: 0})
+}
+
+// TracedError is a LoxError which knows the call stack at the time it occurred
+type TracedError interface {
+	LoxError
+	// StackTrace returns the call stack, most recent call first
+	StackTrace() []StackFrame
+}
+
+type tracedError struct {
+	LoxError
+	stackTrace []StackFrame
+}
+
+func WithStackTrace(e LoxError, stackTrace []StackFrame) TracedError {
+	return &tracedError{LoxError: e, stackTrace: stackTrace}
+}
+
+func (e *tracedError) StackTrace() []StackFrame {
+	return e.stackTrace
+}
+
+// StackTraceOf returns the call stack attached to an error, if any
+func StackTraceOf(e LoxError) []StackFrame {
+	if tracedError, ok := e.(TracedError); ok {
+		return tracedError.StackTrace()
+	}
+
+	return nil
+}
+
+// Unwrap returns the error to which a stack trace was attached, or the error itself
+func Unwrap(e LoxError) LoxError {
+	if tracedError, ok := e.(*tracedError); ok {
+		return tracedError.LoxError
+	}
+
+	return e
+}
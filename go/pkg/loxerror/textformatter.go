@@ -0,0 +1,122 @@
+package loxerror
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+const (
+	ansiReset = "\x1b[0m"
+	ansiBold  = "\x1b[1m"
+	ansiRed   = "\x1b[31m"
+	ansiBlue  = "\x1b[34m"
+)
+
+// TextErrorFormatter renders errors for humans: the faulty source line is quoted
+// and the span of the error is underlined with carets
+type TextErrorFormatter struct {
+	errorQueue
+	// lines holds the lines of the source code of each file
+	lines map[string][]string
+	color bool
+}
+
+func NewTextErrorFormatter(color bool) *TextErrorFormatter {
+	return &TextErrorFormatter{
+		errorQueue: errorQueue{errors: make([]LoxError, 0)},
+		lines:      make(map[string][]string),
+		color:      color,
+	}
+}
+
+func (f *TextErrorFormatter) SetSource(file string, sourceCode string) {
+	f.lines[file] = strings.Split(sourceCode, "\n")
+}
+
+func (f *TextErrorFormatter) Format(e LoxError) string {
+	var builder strings.Builder
+	span := e.Span()
+
+	builder.WriteString(f.paint(ansiBold+ansiRed, "error["+e.Kind()+"]"))
+	builder.WriteString(f.paint(ansiBold, ": "+e.Message()))
+	builder.WriteByte('\n')
+
+	gutter := strings.Repeat(" ", len(strconv.Itoa(span.Start.Line)))
+	location := fmt.Sprintf("line %d, column %d", span.Start.Line, span.Start.Column)
+	if span.File != "" {
+		location = span.File + ", " + location
+	}
+	fmt.Fprintf(&builder, "%s%s %s\n", gutter, f.paint(ansiBlue, "-->"), location)
+
+	if line, ok := f.sourceLine(span.File, span.Start.Line); ok {
+		fmt.Fprintf(&builder, "%s %s\n", gutter, f.paint(ansiBlue, "|"))
+		fmt.Fprintf(&builder, "%s %s %s\n", f.paint(ansiBlue, strconv.Itoa(span.Start.Line)), f.paint(ansiBlue, "|"), line)
+		fmt.Fprintf(&builder, "%s %s %s%s\n",
+			gutter,
+			f.paint(ansiBlue, "|"),
+			caretPadding(line, span.Start.Column),
+			f.paint(ansiBold+ansiRed, strings.Repeat("^", caretWidth(line, span))),
+		)
+	}
+
+	if stackTrace := StackTraceOf(e); len(stackTrace) > 0 {
+		fmt.Fprintf(&builder, "%s %s stack trace (most recent call first):\n", gutter, f.paint(ansiBlue, "="))
+		for _, frame := range stackTrace {
+			fmt.Fprintf(&builder, "%s     at %s, called from line %d", gutter, f.paint(ansiBold, frame.Function), frame.Line)
+			if frame.Repeated > 0 {
+				fmt.Fprintf(&builder, " (repeated %d more times)", frame.Repeated)
+			}
+			builder.WriteByte('\n')
+		}
+	}
+
+	return builder.String()
+}
+
+func (f *TextErrorFormatter) sourceLine(file string, line int) (string, bool) {
+	lines := f.lines[file]
+	if line < 1 || line > len(lines) {
+		return "", false
+	}
+
+	return strings.TrimSuffix(lines[line-1], "\r"), true
+}
+
+func (f *TextErrorFormatter) paint(color string, text string) string {
+	if !f.color {
+		return text
+	}
+
+	return color + text + ansiReset
+}
+
+// caretPadding returns the blank prefix aligning the carets with the given column,
+// tabs are kept so that the alignment doesn't depend on the tab width of the terminal
+func caretPadding(line string, column int) string {
+	end := min(max(column-1, 0), len(line))
+
+	var padding strings.Builder
+	for _, r := range line[:end] {
+		if r == '\t' {
+			padding.WriteByte('\t')
+		} else {
+			padding.WriteByte(' ')
+		}
+	}
+
+	return padding.String()
+}
+
+// caretWidth returns the number of characters of the line covered by the span,
+// a span going past the end of the line is underlined up to the end of the line
+func caretWidth(line string, span Span) int {
+	start := min(max(span.Start.Column-1, 0), len(line))
+	end := len(line)
+	if span.End.Line == span.Start.Line {
+		end = min(max(span.End.Column-1, start), len(line))
+	}
+
+	return max(utf8.RuneCountInString(line[start:end]), 1)
+}
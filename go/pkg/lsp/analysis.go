@@ -0,0 +1,212 @@
+package lsp
+
+import (
+	"sort"
+
+	"github.com/fpotier/lox/go/pkg/ast"
+	"github.com/fpotier/lox/go/pkg/checker"
+	"github.com/fpotier/lox/go/pkg/lexer"
+	"github.com/fpotier/lox/go/pkg/loxerror"
+	"github.com/fpotier/lox/go/pkg/parser"
+	"github.com/fpotier/lox/go/pkg/runtime"
+)
+
+// symbol is a declaration and the uses of its name
+type symbol struct {
+	name       lexer.Token
+	kind       runtime.SymbolKind
+	global     bool
+	references []lexer.Token
+}
+
+// occurrence is a name in the source code and the symbols it may refer to,
+// a property refers to all the methods and fields having its name
+type occurrence struct {
+	name    lexer.Token
+	symbols []*symbol
+}
+
+// analysis is the result of the static passes on a version of a document
+type analysis struct {
+	// source is the text of the symbols, it's the one of a previous version when its symbols are kept
+	source      source
+	diagnostics []Diagnostic
+	// statements are the ones which parsed, they're nil when there are no symbols
+	statements  []ast.Statement
+	occurrences []occurrence
+}
+
+// analyze runs the static passes on a version of a document, previous is the analysis of the version before it (nil
+// for the first one)
+func analyze(text string, previous *analysis) *analysis {
+	errorFormatter := loxerror.NewJSONErrorFormatter()
+	source := newSource(text)
+	a := &analysis{
+		source:      source,
+		diagnostics: make([]Diagnostic, 0),
+		statements:  nil,
+		occurrences: make([]occurrence, 0),
+	}
+
+	tokens := lexer.NewLexer(errorFormatter, text).Tokens()
+	statements := parser.NewParser(errorFormatter, tokens).Parse()
+	if errorFormatter.HasErrors() {
+		// The statements failing to parse are left out, the others still give the symbols. Only the syntax errors are
+		// reported since the missing statements may cause the other errors.
+		resolveErrors := loxerror.NewJSONErrorFormatter()
+		occurrences := resolveSymbols(statements, resolveErrors)
+		switch {
+		case !resolveErrors.HasErrors():
+			a.statements, a.occurrences = statements, occurrences
+		case previous != nil:
+			a.source, a.statements, a.occurrences = previous.source, previous.statements, previous.occurrences
+		}
+	} else {
+		a.statements, a.occurrences = statements, resolveSymbols(statements, errorFormatter)
+		checker.NewChecker(errorFormatter).CheckProgram(statements)
+	}
+
+	for _, e := range errorFormatter.Errors() {
+		a.diagnostics = append(a.diagnostics, Diagnostic{
+			Range:    source.span(e.Span()),
+			Severity: ErrorSeverity,
+			Code:     e.Kind(),
+			Source:   "glox",
+			Message:  e.Message(),
+		})
+	}
+
+	return a
+}
+
+// resolveSymbols runs the resolver on the statements and returns the occurrences of the names it found
+func resolveSymbols(statements []ast.Statement, errorFormatter loxerror.ErrorFormatter) []occurrence {
+	recorder := newSymbolRecorder()
+	resolver := runtime.NewResolver(errorFormatter, recorder)
+	resolver.SymbolRecorder = recorder
+	resolver.ResolveProgram(statements)
+
+	return recorder.link()
+}
+
+// occurrenceAt finds the name under a position, nil if there is none
+func (a *analysis) occurrenceAt(position Position) *occurrence {
+	offset := a.source.offset(position)
+	for i := range a.occurrences {
+		span := a.occurrences[i].name.Span
+		if span.Start.Offset <= offset && offset <= span.End.Offset {
+			return &a.occurrences[i]
+		}
+	}
+
+	return nil
+}
+
+// names are the declarations and the uses of the symbols of an occurrence, in the order of the source code
+func (o *occurrence) names(includeDeclaration bool) []lexer.Token {
+	names := make([]lexer.Token, 0)
+	seen := make(map[int]bool)
+	add := func(name lexer.Token) {
+		if !seen[name.Span.Start.Offset] {
+			seen[name.Span.Start.Offset] = true
+			names = append(names, name)
+		}
+	}
+	for _, s := range o.symbols {
+		if includeDeclaration {
+			add(s.name)
+		}
+		for _, reference := range s.references {
+			add(reference)
+		}
+	}
+	sort.Slice(names, func(i, j int) bool { return names[i].Span.Start.Offset < names[j].Span.Start.Offset })
+
+	return names
+}
+
+// symbolRecorder builds the symbols from what the resolver reports
+type symbolRecorder struct {
+	symbols []*symbol
+	// declarations maps the offset of a declared name to its symbol
+	declarations map[int]*symbol
+	// globalUses and propertyUses are linked by name once the whole program is resolved
+	globalUses   []lexer.Token
+	propertyUses []lexer.Token
+	localUses    []occurrence
+}
+
+func newSymbolRecorder() *symbolRecorder {
+	return &symbolRecorder{
+		symbols:      make([]*symbol, 0),
+		declarations: make(map[int]*symbol),
+		globalUses:   make([]lexer.Token, 0),
+		propertyUses: make([]lexer.Token, 0),
+		localUses:    make([]occurrence, 0),
+	}
+}
+
+// Resolve is part of runtime.ScopeRecorder, the scope distances aren't needed
+func (r *symbolRecorder) Resolve(ast.Expression, int) {}
+
+func (r *symbolRecorder) Declare(name lexer.Token, kind runtime.SymbolKind, global bool) {
+	s := &symbol{name: name, kind: kind, global: global, references: make([]lexer.Token, 0)}
+	r.symbols = append(r.symbols, s)
+	r.declarations[name.Span.Start.Offset] = s
+}
+
+func (r *symbolRecorder) Refer(name lexer.Token, declaration *lexer.Token) {
+	if declaration == nil {
+		r.globalUses = append(r.globalUses, name)
+		return
+	}
+
+	if s, ok := r.declarations[declaration.Span.Start.Offset]; ok {
+		s.references = append(s.references, name)
+		r.localUses = append(r.localUses, occurrence{name: name, symbols: []*symbol{s}})
+	}
+}
+
+func (r *symbolRecorder) ReferProperty(name lexer.Token) {
+	r.propertyUses = append(r.propertyUses, name)
+}
+
+// link resolves the global variables and the properties by name and lists the occurrences of the names
+func (r *symbolRecorder) link() []occurrence {
+	globals := make(map[string][]*symbol)
+	members := make(map[string][]*symbol)
+	for _, s := range r.symbols {
+		switch {
+		case s.kind == runtime.MethodSymbol || s.kind == runtime.FieldSymbol:
+			members[s.name.Lexeme] = append(members[s.name.Lexeme], s)
+		case s.global:
+			globals[s.name.Lexeme] = append(globals[s.name.Lexeme], s)
+		}
+	}
+
+	occurrences := r.localUses
+	for _, s := range r.symbols {
+		symbols := []*symbol{s}
+		if s.kind == runtime.MethodSymbol || s.kind == runtime.FieldSymbol {
+			symbols = members[s.name.Lexeme]
+		}
+		occurrences = append(occurrences, occurrence{name: s.name, symbols: symbols})
+	}
+	for _, uses := range []struct {
+		names   []lexer.Token
+		symbols map[string][]*symbol
+	}{{r.globalUses, globals}, {r.propertyUses, members}} {
+		for _, name := range uses.names {
+			symbols, ok := uses.symbols[name.Lexeme]
+			if !ok {
+				continue
+			}
+			for _, s := range symbols {
+				s.references = append(s.references, name)
+			}
+			occurrences = append(occurrences, occurrence{name: name, symbols: symbols})
+		}
+	}
+
+	return occurrences
+}
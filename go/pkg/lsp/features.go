@@ -0,0 +1,153 @@
+package lsp
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/fpotier/lox/go/pkg/ast"
+	"github.com/fpotier/lox/go/pkg/lexer"
+	"github.com/fpotier/lox/go/pkg/loxerror"
+	"github.com/fpotier/lox/go/pkg/runtime"
+)
+
+var symbolDescriptions = map[runtime.SymbolKind]string{
+	runtime.VariableSymbol:  "variable",
+	runtime.FunctionSymbol:  "function",
+	runtime.ClassSymbol:     "class",
+	runtime.ParameterSymbol: "parameter",
+	runtime.MethodSymbol:    "method",
+	runtime.FieldSymbol:     "field",
+}
+
+// hover describes the declarations of the name under a position with their source line
+func (a *analysis) hover(position Position) *Hover {
+	o := a.occurrenceAt(position)
+	if o == nil {
+		return nil
+	}
+
+	var contents strings.Builder
+	for i, s := range o.symbols {
+		if i > 0 {
+			contents.WriteString("\n---\n\n")
+		}
+		description := symbolDescriptions[s.kind]
+		if s.global {
+			description = "global " + description
+		} else if s.kind == runtime.VariableSymbol || s.kind == runtime.FunctionSymbol || s.kind == runtime.ClassSymbol {
+			description = "local " + description
+		}
+		fmt.Fprintf(&contents, "(%s) %s\n\n```lox\n%s\n```\n", description, s.name.Lexeme,
+			strings.TrimSpace(a.source.line(s.name.Span.Start.Offset)))
+	}
+
+	return &Hover{
+		Contents: MarkupContent{Kind: "markdown", Value: contents.String()},
+		Range:    a.source.span(o.name.Span),
+	}
+}
+
+func (a *analysis) definition(uri string, position Position) []Location {
+	locations := make([]Location, 0)
+	if o := a.occurrenceAt(position); o != nil {
+		for _, s := range o.symbols {
+			locations = append(locations, Location{URI: uri, Range: a.source.span(s.name.Span)})
+		}
+	}
+
+	return locations
+}
+
+func (a *analysis) references(uri string, position Position, includeDeclaration bool) []Location {
+	locations := make([]Location, 0)
+	if o := a.occurrenceAt(position); o != nil {
+		for _, name := range o.names(includeDeclaration) {
+			locations = append(locations, Location{URI: uri, Range: a.source.span(name.Span)})
+		}
+	}
+
+	return locations
+}
+
+// rename replaces the declarations and the uses of the name under a position,
+// the properties are renamed in all the classes declaring them
+func (a *analysis) rename(uri string, position Position, newName string) (*WorkspaceEdit, error) {
+	o := a.occurrenceAt(position)
+	if o == nil {
+		return nil, nil
+	}
+	if !isIdentifier(newName) {
+		return nil, &ResponseError{Code: InvalidParamsCode, Message: fmt.Sprintf("'%s' is not a valid name", newName)}
+	}
+
+	edits := make([]TextEdit, 0)
+	for _, name := range o.names(true) {
+		edits = append(edits, TextEdit{Range: a.source.span(name.Span), NewText: newName})
+	}
+
+	return &WorkspaceEdit{Changes: map[string][]TextEdit{uri: edits}}, nil
+}
+
+// isIdentifier tells if a name is scanned as a single identifier, keywords excluded
+func isIdentifier(name string) bool {
+	errorFormatter := loxerror.NewJSONErrorFormatter()
+	tokens := lexer.NewLexer(errorFormatter, name).Tokens()
+
+	return !errorFormatter.HasErrors() && len(tokens) == 2 && tokens[0].Type == lexer.Identifier &&
+		tokens[0].Lexeme == name
+}
+
+// documentSymbols outlines the global declarations and the members of the classes
+func (a *analysis) documentSymbols() []DocumentSymbol {
+	symbols := make([]DocumentSymbol, 0)
+	for _, statement := range a.statements {
+		switch s := statement.(type) {
+		case *ast.VariableStatement:
+			symbols = append(symbols, a.documentSymbol(s.Name, VariableSymbol))
+		case *ast.FunctionStatement:
+			symbols = append(symbols, a.documentSymbol(s.Name, FunctionSymbol))
+		case *ast.ClassStatement:
+			symbols = append(symbols, a.classSymbol(s))
+		case *ast.ImportStatement:
+			if s.Alias != nil {
+				symbols = append(symbols, a.documentSymbol(*s.Alias, ModuleSymbol))
+			}
+			for _, name := range s.Names {
+				symbols = append(symbols, a.documentSymbol(name, VariableSymbol))
+			}
+		}
+	}
+
+	return symbols
+}
+
+func (a *analysis) classSymbol(s *ast.ClassStatement) DocumentSymbol {
+	class := a.documentSymbol(s.Name, ClassSymbol)
+	class.Children = make([]DocumentSymbol, 0)
+	for _, fields := range [][]*ast.VariableStatement{s.Fields, s.ClassFields} {
+		for _, field := range fields {
+			class.Children = append(class.Children, a.documentSymbol(field.Name, FieldSymbol))
+		}
+	}
+	for _, methods := range [][]*ast.FunctionStatement{s.Methods, s.ClassMethods} {
+		for _, method := range methods {
+			kind := MethodSymbol
+			switch {
+			case method.IsGetter:
+				kind = PropertySymbol
+			case method.Name.Lexeme == "init":
+				kind = ConstructorSymbol
+			}
+			class.Children = append(class.Children, a.documentSymbol(method.Name, kind))
+		}
+	}
+
+	return class
+}
+
+// documentSymbol is a symbol whose range is its name, the AST doesn't keep the extent of the declarations
+func (a *analysis) documentSymbol(name lexer.Token, kind SymbolKind) DocumentSymbol {
+	nameRange := a.source.span(name.Span)
+
+	return DocumentSymbol{Name: name.Lexeme, Kind: kind, Range: nameRange, SelectionRange: nameRange, Children: nil}
+}
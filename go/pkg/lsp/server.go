@@ -0,0 +1,328 @@
+// Package lsp implements a language server for Lox speaking the Language Server Protocol over a stream
+package lsp
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+)
+
+// ErrExitWithoutShutdown is returned by Run when the client exits without shutting down the server first
+var ErrExitWithoutShutdown = errors.New("exit notification received before the shutdown request")
+
+// Server answers the requests of a single client, the documents are analyzed again on each change
+type Server struct {
+	reader      *bufio.Reader
+	writer      io.Writer
+	initialized bool
+	shutdown    bool
+	// documents maps the URI of the open documents to the analysis of their last version
+	documents map[string]*analysis
+}
+
+func NewServer(reader io.Reader, writer io.Writer) *Server {
+	return &Server{
+		reader:      bufio.NewReader(reader),
+		writer:      writer,
+		initialized: false,
+		shutdown:    false,
+		documents:   make(map[string]*analysis),
+	}
+}
+
+type handler func(s *Server, params json.RawMessage) (any, error)
+
+var handlers = map[string]handler{
+	"initialize":                  (*Server).initialize,
+	"initialized":                 ignore,
+	"shutdown":                    (*Server).shutdownServer,
+	"textDocument/didOpen":        (*Server).didOpen,
+	"textDocument/didChange":      (*Server).didChange,
+	"textDocument/didClose":       (*Server).didClose,
+	"textDocument/hover":          (*Server).hover,
+	"textDocument/definition":     (*Server).definition,
+	"textDocument/references":     (*Server).references,
+	"textDocument/documentSymbol": (*Server).documentSymbol,
+	"textDocument/rename":         (*Server).rename,
+}
+
+// Run handles the messages until the exit notification, it returns nil if the server was shut down before
+func (s *Server) Run() error {
+	for {
+		request, err := s.read()
+		var responseError *ResponseError
+		switch {
+		case errors.As(err, &responseError):
+			if err := s.respond(nil, nil, responseError); err != nil {
+				return err
+			}
+			continue
+		case err != nil:
+			return err
+		}
+
+		if request.Method == "exit" {
+			if !s.shutdown {
+				return ErrExitWithoutShutdown
+			}
+			return nil
+		}
+		if err := s.handle(request); err != nil {
+			return err
+		}
+	}
+}
+
+func (s *Server) handle(request *message) error {
+	var result any
+	var err error
+	handle, ok := handlers[request.Method]
+	switch {
+	case !ok:
+		err = &ResponseError{Code: MethodNotFoundCode, Message: fmt.Sprintf("Unsupported method '%s'", request.Method)}
+	case s.shutdown:
+		err = &ResponseError{Code: InvalidRequestCode, Message: "The server is shut down"}
+	case !s.initialized && request.Method != "initialize":
+		err = &ResponseError{Code: ServerNotInitializedCode, Message: "The server isn't initialized"}
+	default:
+		result, err = handle(s, request.Params)
+	}
+
+	// The notifications have no response, even when they fail
+	if request.ID == nil {
+		return nil
+	}
+	var responseError *ResponseError
+	if err != nil && !errors.As(err, &responseError) {
+		responseError = &ResponseError{Code: RequestFailedCode, Message: err.Error()}
+	}
+
+	return s.respond(request.ID, result, responseError)
+}
+
+// read reads a message preceded by its Content-Length header
+func (s *Server) read() (*message, error) {
+	length := -1
+	for {
+		line, err := s.reader.ReadString('\n')
+		if err != nil {
+			return nil, err
+		}
+		line = strings.TrimRight(line, "\r\n")
+		if line == "" {
+			break
+		}
+		name, value, ok := strings.Cut(line, ":")
+		if ok && strings.EqualFold(strings.TrimSpace(name), "Content-Length") {
+			if length, err = strconv.Atoi(strings.TrimSpace(value)); err != nil {
+				return nil, fmt.Errorf("invalid Content-Length header: %w", err)
+			}
+		}
+	}
+	if length < 0 {
+		return nil, errors.New("missing Content-Length header")
+	}
+
+	content := make([]byte, length)
+	if _, err := io.ReadFull(s.reader, content); err != nil {
+		return nil, err
+	}
+	var request message
+	if err := json.Unmarshal(content, &request); err != nil {
+		return nil, &ResponseError{Code: ParseErrorCode, Message: err.Error()}
+	}
+
+	return &request, nil
+}
+
+func (s *Server) respond(id *json.RawMessage, result any, responseError *ResponseError) error {
+	response := message{JSONRPC: "2.0", ID: id, Method: "", Params: nil, Result: nil, Error: responseError}
+	if id == nil {
+		// The ID of a request that can't be read is null
+		null := json.RawMessage("null")
+		response.ID = &null
+	}
+	if responseError == nil {
+		rawResult, err := json.Marshal(result)
+		if err != nil {
+			return err
+		}
+		response.Result = rawResult
+	}
+
+	return s.write(response)
+}
+
+func (s *Server) notify(method string, params any) error {
+	rawParams, err := json.Marshal(params)
+	if err != nil {
+		return err
+	}
+
+	return s.write(message{JSONRPC: "2.0", ID: nil, Method: method, Params: rawParams, Result: nil, Error: nil})
+}
+
+func (s *Server) write(m message) error {
+	content, err := json.Marshal(m)
+	if err != nil {
+		return err
+	}
+	_, err = fmt.Fprintf(s.writer, "Content-Length: %d\r\n\r\n%s", len(content), content)
+
+	return err
+}
+
+// decode reads the parameters of a request
+func decode[T any](params json.RawMessage) (T, error) {
+	var decoded T
+	if err := json.Unmarshal(params, &decoded); err != nil {
+		return decoded, &ResponseError{Code: InvalidParamsCode, Message: err.Error()}
+	}
+
+	return decoded, nil
+}
+
+func ignore(*Server, json.RawMessage) (any, error) { return nil, nil }
+
+func (s *Server) initialize(json.RawMessage) (any, error) {
+	s.initialized = true
+
+	return InitializeResult{
+		Capabilities: ServerCapabilities{
+			TextDocumentSync:       FullSync,
+			HoverProvider:          true,
+			DefinitionProvider:     true,
+			ReferencesProvider:     true,
+			DocumentSymbolProvider: true,
+			RenameProvider:         true,
+		},
+		ServerInfo: ServerInfo{Name: "glox"},
+	}, nil
+}
+
+func (s *Server) shutdownServer(json.RawMessage) (any, error) {
+	s.shutdown = true
+
+	return nil, nil
+}
+
+func (s *Server) didOpen(rawParams json.RawMessage) (any, error) {
+	params, err := decode[DidOpenTextDocumentParams](rawParams)
+	if err != nil {
+		return nil, err
+	}
+
+	return nil, s.update(params.TextDocument.URI, params.TextDocument.Text)
+}
+
+func (s *Server) didChange(rawParams json.RawMessage) (any, error) {
+	params, err := decode[DidChangeTextDocumentParams](rawParams)
+	if err != nil || len(params.ContentChanges) == 0 {
+		return nil, err
+	}
+
+	return nil, s.update(params.TextDocument.URI, params.ContentChanges[len(params.ContentChanges)-1].Text)
+}
+
+func (s *Server) didClose(rawParams json.RawMessage) (any, error) {
+	params, err := decode[DidCloseTextDocumentParams](rawParams)
+	if err != nil {
+		return nil, err
+	}
+	delete(s.documents, params.TextDocument.URI)
+
+	return nil, s.notify("textDocument/publishDiagnostics", PublishDiagnosticsParams{
+		URI:         params.TextDocument.URI,
+		Diagnostics: make([]Diagnostic, 0),
+	})
+}
+
+// update analyzes a new version of a document and publishes its diagnostics
+func (s *Server) update(uri string, text string) error {
+	document := analyze(text, s.documents[uri])
+	s.documents[uri] = document
+
+	return s.notify("textDocument/publishDiagnostics", PublishDiagnosticsParams{
+		URI:         uri,
+		Diagnostics: document.diagnostics,
+	})
+}
+
+// document finds the analysis of an open document
+func (s *Server) document(uri string) (*analysis, error) {
+	document, ok := s.documents[uri]
+	if !ok {
+		return nil, &ResponseError{Code: InvalidParamsCode, Message: fmt.Sprintf("Unknown document '%s'", uri)}
+	}
+
+	return document, nil
+}
+
+func (s *Server) hover(rawParams json.RawMessage) (any, error) {
+	params, err := decode[TextDocumentPositionParams](rawParams)
+	if err != nil {
+		return nil, err
+	}
+	document, err := s.document(params.TextDocument.URI)
+	if err != nil {
+		return nil, err
+	}
+
+	return document.hover(params.Position), nil
+}
+
+func (s *Server) definition(rawParams json.RawMessage) (any, error) {
+	params, err := decode[TextDocumentPositionParams](rawParams)
+	if err != nil {
+		return nil, err
+	}
+	document, err := s.document(params.TextDocument.URI)
+	if err != nil {
+		return nil, err
+	}
+
+	return document.definition(params.TextDocument.URI, params.Position), nil
+}
+
+func (s *Server) references(rawParams json.RawMessage) (any, error) {
+	params, err := decode[ReferenceParams](rawParams)
+	if err != nil {
+		return nil, err
+	}
+	document, err := s.document(params.TextDocument.URI)
+	if err != nil {
+		return nil, err
+	}
+
+	return document.references(params.TextDocument.URI, params.Position, params.Context.IncludeDeclaration), nil
+}
+
+func (s *Server) documentSymbol(rawParams json.RawMessage) (any, error) {
+	params, err := decode[DocumentSymbolParams](rawParams)
+	if err != nil {
+		return nil, err
+	}
+	document, err := s.document(params.TextDocument.URI)
+	if err != nil {
+		return nil, err
+	}
+
+	return document.documentSymbols(), nil
+}
+
+func (s *Server) rename(rawParams json.RawMessage) (any, error) {
+	params, err := decode[RenameParams](rawParams)
+	if err != nil {
+		return nil, err
+	}
+	document, err := s.document(params.TextDocument.URI)
+	if err != nil {
+		return nil, err
+	}
+
+	return document.rename(params.TextDocument.URI, params.Position, params.NewName)
+}
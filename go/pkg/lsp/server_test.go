@@ -0,0 +1,248 @@
+package lsp
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"testing"
+)
+
+const uri = "file:///counter.lox"
+
+const program = `var total = 0;
+fun add(amount) {
+  total = total + amount;
+  return total;
+}
+class Counter {
+  init() { this.count = 0; }
+  increment() { this.count = add(1); }
+}
+var counter = Counter();
+counter.increment();
+`
+
+// call is a request, or a notification when it has no response
+type call struct {
+	method       string
+	params       any
+	notification bool
+}
+
+// session sends the calls to an initialized server and returns its responses and notifications, in order
+func session(t *testing.T, calls ...call) []message {
+	t.Helper()
+
+	calls = append([]call{{method: "initialize", params: map[string]any{}, notification: false}}, calls...)
+	calls = append(calls, call{method: "shutdown", params: nil, notification: false})
+	calls = append(calls, call{method: "exit", params: nil, notification: true})
+	var input bytes.Buffer
+	for id, c := range calls {
+		m := map[string]any{"jsonrpc": "2.0", "method": c.method, "params": c.params}
+		if !c.notification {
+			m["id"] = id
+		}
+		content, err := json.Marshal(m)
+		if err != nil {
+			t.Fatal(err)
+		}
+		fmt.Fprintf(&input, "Content-Length: %d\r\n\r\n%s", len(content), content)
+	}
+
+	var output bytes.Buffer
+	if err := NewServer(&input, &output).Run(); err != nil {
+		t.Fatal(err)
+	}
+
+	// The messages of the server are read like the ones of a client
+	reader := NewServer(&output, nil)
+	messages := make([]message, 0)
+	for {
+		m, err := reader.read()
+		if err != nil {
+			break
+		}
+		messages = append(messages, *m)
+	}
+
+	// The responses to initialize and shutdown aren't checked
+	return messages[1 : len(messages)-1]
+}
+
+func open(text string) call {
+	return call{method: "textDocument/didOpen", params: DidOpenTextDocumentParams{
+		TextDocument: TextDocumentItem{URI: uri, LanguageID: "lox", Version: 1, Text: text},
+	}, notification: true}
+}
+
+func change(text string) call {
+	return call{method: "textDocument/didChange", params: DidChangeTextDocumentParams{
+		TextDocument:   TextDocumentIdentifier{URI: uri},
+		ContentChanges: []TextDocumentContentChangeEvent{{Text: text}},
+	}, notification: true}
+}
+
+// at is a request about a position of the document
+func at(method string, line int, character int, extra map[string]any) call {
+	params := map[string]any{
+		"textDocument": TextDocumentIdentifier{URI: uri},
+		"position":     Position{Line: line, Character: character},
+	}
+	for key, value := range extra {
+		params[key] = value
+	}
+
+	return call{method: method, params: params, notification: false}
+}
+
+func decodeResult[T any](t *testing.T, m message) T {
+	t.Helper()
+
+	if m.Error != nil {
+		t.Fatalf("unexpected error: %s", m.Error.Message)
+	}
+	var result T
+	if err := json.Unmarshal(m.Result, &result); err != nil {
+		t.Fatal(err)
+	}
+
+	return result
+}
+
+// lines lists the start line of the ranges of the locations
+func lines(locations []Location) []int {
+	result := make([]int, 0)
+	for _, location := range locations {
+		result = append(result, location.Range.Start.Line)
+	}
+
+	return result
+}
+
+func TestDiagnostics(t *testing.T) {
+	messages := session(t,
+		open("var x = ;\nprint x;"),
+		change("fun f() {}\nreturn f;"),
+		change(program),
+	)
+
+	expected := []struct {
+		code  string
+		start Position
+	}{
+		{"ParseError", Position{Line: 0, Character: 8}},
+		{"RuntimeError", Position{Line: 1, Character: 0}},
+	}
+	for i, m := range messages {
+		params := decodeResult[PublishDiagnosticsParams](t, message{Result: m.Params})
+		if i == len(expected) {
+			if len(params.Diagnostics) > 0 {
+				t.Errorf("unexpected diagnostics for a valid program: %v", params.Diagnostics)
+			}
+			continue
+		}
+		if len(params.Diagnostics) != 1 || params.Diagnostics[0].Code != expected[i].code ||
+			params.Diagnostics[0].Range.Start != expected[i].start {
+			t.Errorf("expected a %s at %v, got %v", expected[i].code, expected[i].start, params.Diagnostics)
+		}
+	}
+}
+
+func TestNavigation(t *testing.T) {
+	messages := session(t,
+		open(program),
+		// add in the increment method
+		at("textDocument/definition", 7, 32, nil),
+		// total in its declaration
+		at("textDocument/references", 0, 5, map[string]any{"context": ReferenceContext{IncludeDeclaration: true}}),
+		// count in the increment method
+		at("textDocument/references", 7, 22, map[string]any{"context": ReferenceContext{IncludeDeclaration: false}}),
+		at("textDocument/hover", 2, 19, nil),
+	)[1:]
+
+	if definition := decodeResult[[]Location](t, messages[0]); fmt.Sprint(lines(definition)) != "[1]" ||
+		definition[0].Range.Start.Character != 4 {
+		t.Errorf("unexpected definition of add: %v", definition)
+	}
+	if references := decodeResult[[]Location](t, messages[1]); fmt.Sprint(lines(references)) != "[0 2 2 3]" {
+		t.Errorf("unexpected references of total: %v", references)
+	}
+	// The fields assigned in the methods aren't declarations, they have no symbol
+	if references := decodeResult[[]Location](t, messages[2]); len(references) != 0 {
+		t.Errorf("unexpected references of count: %v", references)
+	}
+	if hover := decodeResult[Hover](t, messages[3]); !strings.HasPrefix(hover.Contents.Value, "(parameter) amount") ||
+		!strings.Contains(hover.Contents.Value, "fun add(amount) {") {
+		t.Errorf("unexpected hover of amount: %q", hover.Contents.Value)
+	}
+
+	// The statements around a syntax error keep their symbols, the ones of the previous version are kept when the
+	// statements which parsed can't be resolved
+	messages = session(t,
+		open(program+"var broken = ;\n"),
+		at("textDocument/references", 0, 5, map[string]any{"context": ReferenceContext{IncludeDeclaration: true}}),
+		change(program+"return total;\nvar broken = ;\n"),
+		at("textDocument/references", 0, 5, map[string]any{"context": ReferenceContext{IncludeDeclaration: true}}),
+	)
+	for _, index := range []int{0, 2} {
+		params := decodeResult[PublishDiagnosticsParams](t, message{Result: messages[index].Params})
+		if len(params.Diagnostics) != 1 || params.Diagnostics[0].Code != "ParseError" {
+			t.Errorf("expected only the syntax error, got %v", params.Diagnostics)
+		}
+	}
+	for _, index := range []int{1, 3} {
+		if references := decodeResult[[]Location](t, messages[index]); fmt.Sprint(lines(references)) != "[0 2 2 3]" {
+			t.Errorf("unexpected references of total with a syntax error: %v", references)
+		}
+	}
+}
+
+func TestSymbols(t *testing.T) {
+	messages := session(t,
+		open(program),
+		call{
+			method:       "textDocument/documentSymbol",
+			params:       DocumentSymbolParams{TextDocument: TextDocumentIdentifier{URI: uri}},
+			notification: false,
+		},
+		at("textDocument/rename", 10, 10, map[string]any{"newName": "next"}),
+		at("textDocument/rename", 0, 5, map[string]any{"newName": "class"}),
+	)[1:]
+
+	names := make([]string, 0)
+	for _, s := range decodeResult[[]DocumentSymbol](t, messages[0]) {
+		names = append(names, s.Name)
+		for _, child := range s.Children {
+			names = append(names, s.Name+"."+child.Name)
+		}
+	}
+	if fmt.Sprint(names) != "[total add Counter Counter.init Counter.increment counter]" {
+		t.Errorf("unexpected document symbols: %v", names)
+	}
+
+	edit := decodeResult[WorkspaceEdit](t, messages[1])
+	edits := make([]string, 0)
+	for _, e := range edit.Changes[uri] {
+		edits = append(edits, fmt.Sprintf("%d:%d %s", e.Range.Start.Line, e.Range.Start.Character, e.NewText))
+	}
+	if fmt.Sprint(edits) != "[7:2 next 10:8 next]" {
+		t.Errorf("unexpected rename edits: %v", edits)
+	}
+
+	if messages[2].Error == nil || messages[2].Error.Code != InvalidParamsCode {
+		t.Errorf("expected a rename to a keyword to fail, got %s", messages[2].Result)
+	}
+}
+
+func TestSourcePositions(t *testing.T) {
+	// é is one UTF-16 code unit and two bytes, the emoji is two UTF-16 code units and four bytes
+	s := newSource("var a = \"é\U0001F600\";\nprint a;")
+	end := s.position(strings.IndexByte(s.text, '\n'))
+	if end != (Position{Line: 0, Character: 14}) {
+		t.Errorf("unexpected position of the line break: %v", end)
+	}
+	if offset := s.offset(Position{Line: 1, Character: 6}); s.text[offset:] != "a;" {
+		t.Errorf("unexpected offset of the position 1:6: %d", offset)
+	}
+}
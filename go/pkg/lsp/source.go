@@ -0,0 +1,85 @@
+package lsp
+
+import (
+	"unicode/utf8"
+
+	"github.com/fpotier/lox/go/pkg/loxerror"
+)
+
+// source converts the byte offsets of the spans to the LSP positions, whose characters are UTF-16 code units
+type source struct {
+	text string
+	// lineStarts holds the offset of the first byte of each line
+	lineStarts []int
+}
+
+func newSource(text string) source {
+	lineStarts := []int{0}
+	for offset := 0; offset < len(text); offset++ {
+		if text[offset] == '\n' {
+			lineStarts = append(lineStarts, offset+1)
+		}
+	}
+
+	return source{text: text, lineStarts: lineStarts}
+}
+
+func (s source) position(offset int) Position {
+	offset = min(max(offset, 0), len(s.text))
+	line := len(s.lineStarts) - 1
+	for s.lineStarts[line] > offset {
+		line--
+	}
+
+	character := 0
+	for _, r := range s.text[s.lineStarts[line]:offset] {
+		character += utf16Length(r)
+	}
+
+	return Position{Line: line, Character: character}
+}
+
+// offset is the byte offset of a position, a position after the end of its line is the end of the line
+func (s source) offset(position Position) int {
+	if position.Line < 0 {
+		return 0
+	}
+	if position.Line >= len(s.lineStarts) {
+		return len(s.text)
+	}
+
+	offset := s.lineStarts[position.Line]
+	for character := 0; character < position.Character && offset < len(s.text); {
+		r, size := utf8.DecodeRuneInString(s.text[offset:])
+		if r == '\n' {
+			break
+		}
+		character += utf16Length(r)
+		offset += size
+	}
+
+	return offset
+}
+
+func (s source) span(span loxerror.Span) Range {
+	return Range{Start: s.position(span.Start.Offset), End: s.position(span.End.Offset)}
+}
+
+// line is the text of the line holding the offset, without its line break
+func (s source) line(offset int) string {
+	start := s.lineStarts[s.position(offset).Line]
+	end := start
+	for end < len(s.text) && s.text[end] != '\n' {
+		end++
+	}
+
+	return s.text[start:end]
+}
+
+func utf16Length(r rune) int {
+	if r >= 0x10000 {
+		return 2
+	}
+
+	return 1
+}
@@ -1,19 +1,25 @@
 package parser
 
+import "github.com/fpotier/lox/go/pkg/loxerror"
+
 type ParseError struct {
-	line    int
+	span    loxerror.Span
 	message string
 }
 
-func NewParseError(line int, message string) *ParseError {
+func NewParseError(span loxerror.Span, message string) *ParseError {
 	return &ParseError{
-		line:    line,
+		span:    span,
 		message: message,
 	}
 }
 
 func (e *ParseError) Line() int {
-	return e.line
+	return e.span.Start.Line
+}
+
+func (e *ParseError) Span() loxerror.Span {
+	return e.span
 }
 
 func (e *ParseError) Kind() string {
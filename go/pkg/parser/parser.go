@@ -2,6 +2,7 @@ package parser
 
 import (
 	"fmt"
+	"strings"
 
 	"github.com/fpotier/lox/go/pkg/ast"
 	"github.com/fpotier/lox/go/pkg/lexer"
@@ -17,11 +18,17 @@ import (
 //                | classDeclaration
 //                | statement
 //
-// varDeclaration -> IDENTIFIER ( "=" expression )? ";"
+// varDeclaration -> IDENTIFIER typeAnnotation? ( "=" expression )? ";"
+//
+// typeAnnotation -> ":" ( IDENTIFIER | "nil" )
 //
 // funDeclaration -> "fun" function
 //
-// classDeclaration -> "class" IDENTIFIER ( "<"  IDENTIFIER )? "{" function* "}"
+// classDeclaration -> "class" IDENTIFIER ( "<"  IDENTIFIER )? "{" ( "class"? method | "class" "var" varDeclaration | field )* "}"
+//
+// method -> IDENTIFIER ( "(" parameters? ")" )? typeAnnotation? block
+//
+// field -> IDENTIFIER typeAnnotation ";"
 //
 // statement -> expressionStatement
 //              | forStatement
@@ -34,6 +41,7 @@ import (
 // expressionStatement -> expression ";"
 //
 // forStatement -> "for" "(" (varDecl | expression Statement | ";") expression? ";" expression? ")" statement
+//                 | "for" "(" IDENTIFIER "in" expression ")" statement
 //
 // ifStatement -> "if" "(" expression ")" ( "else" statement )?
 //
@@ -76,9 +84,9 @@ import (
 //            | IDENTIFIER
 //            | "super" "." IDENTIFIER
 //
-// function -> IDENTIFIER "(" parameters? ")" block
+// function -> IDENTIFIER "(" parameters? ")" typeAnnotation? block
 //
-// parameters -> expression ( "," IDENTIFIER )*
+// parameters -> IDENTIFIER typeAnnotation? ( "," IDENTIFIER typeAnnotation? )*
 //
 // arguments -> expression ( "," expression )*
 //
@@ -94,8 +102,10 @@ func NewParser(errorFormatter loxerror.ErrorFormatter, tokens []lexer.Token) *Pa
 func (p *Parser) Parse() []ast.Statement {
 	statements := make([]ast.Statement, 0)
 	for !p.isAtEnd() {
-		s := p.declaration()
-		statements = append(statements, s)
+		// A declaration failing to parse is left out, the statements around it are kept
+		if s := p.declaration(); s != nil {
+			statements = append(statements, s)
+		}
 	}
 
 	return statements
@@ -116,7 +126,9 @@ func (p *Parser) declaration() ast.Statement {
 	switch {
 	case p.match(lexer.Var):
 		statement = p.varDeclaration()
-	case p.match(lexer.Fun):
+	case p.check(lexer.Fun) && p.peekNext().Type != lexer.LeftParenthesis:
+		// 'fun' followed by a parenthesis starts an expression statement with a function expression
+		p.advance()
 		statement = p.function("function")
 	case p.match(lexer.Class):
 		statement = p.classDeclaration()
@@ -131,6 +143,7 @@ func (p *Parser) declaration() ast.Statement {
 
 func (p *Parser) varDeclaration() ast.Statement {
 	name := p.consume(lexer.Identifier, "Expect a variable name")
+	variableType := p.typeAnnotation()
 	var initializer ast.Expression
 	if p.match(lexer.Equal) {
 		initializer = p.expression()
@@ -138,29 +151,111 @@ func (p *Parser) varDeclaration() ast.Statement {
 
 	p.consume(lexer.Semicolon, "Expect ';' after variable declaration")
 
-	return ast.NewVariableStatement(name, initializer)
+	return ast.NewVariableStatement(name, variableType, initializer)
+}
+
+// typeAnnotation parses the optional type following a declared name: "x: number", it returns nil if there is none
+func (p *Parser) typeAnnotation() *lexer.Token {
+	if !p.match(lexer.Colon) {
+		return nil
+	}
+	if !p.match(lexer.Identifier, lexer.Nil) {
+		err := NewParseError(p.peek().Span, "Expect type name after ':'")
+		p.errorFormatter.PushError(err)
+		panic(err)
+	}
+
+	typeName := p.previous()
+	return &typeName
 }
 
 func (p *Parser) function(kind string) ast.Statement {
 	name := p.consume(lexer.Identifier, fmt.Sprintf("Expect %s name.", kind))
 
 	p.consume(lexer.LeftParenthesis, fmt.Sprintf("Expect '(' after %s name.", kind))
+	parameters, parameterTypes := p.parameters()
+	returnType := p.typeAnnotation()
+	p.consume(lexer.LeftBrace, "Function body must start with '{'")
+	body := p.block()
+
+	return ast.NewFunctionStatement(name, parameters, parameterTypes, returnType, body)
+}
+
+// parameters parses the parameters of a function and their type annotations up to the closing parenthesis
+func (p *Parser) parameters() ([]lexer.Token, []*lexer.Token) {
 	parameters := make([]lexer.Token, 0)
+	parameterTypes := make([]*lexer.Token, 0)
 	if !p.check(lexer.RightParenthesis) {
 		for next := true; next; next = p.match(lexer.Comma) {
 			if len(parameters) >= ast.Limits.MaxArgs {
-				p.errorFormatter.PushError(NewParseError(p.peek().Line, "Can't have more than 255 parameters"))
+				p.errorFormatter.PushError(NewParseError(p.peek().Span, "Can't have more than 255 parameters"))
 			}
 
 			parameters = append(parameters, p.consume(lexer.Identifier, "Expect parameter name"))
+			parameterTypes = append(parameterTypes, p.typeAnnotation())
 		}
 	}
-
 	p.consume(lexer.RightParenthesis, "Expect ')' after parameters")
+
+	return parameters, parameterTypes
+}
+
+// functionExpression parses an anonymous function: "fun (a, b) { ... }"
+func (p *Parser) functionExpression() ast.Expression {
+	name := anonymousName(p.previous())
+	p.consume(lexer.LeftParenthesis, "Expect '(' after 'fun'")
+	parameters, parameterTypes := p.parameters()
+	returnType := p.typeAnnotation()
 	p.consume(lexer.LeftBrace, "Function body must start with '{'")
 	body := p.block()
 
-	return ast.NewFunctionStatement(name, parameters, body)
+	return ast.NewFunctionExpression(ast.NewFunctionStatement(name, parameters, parameterTypes, returnType, body))
+}
+
+// arrowFunction parses the short form of an anonymous function returning an expression: "(a, b) => a + b"
+func (p *Parser) arrowFunction() ast.Expression {
+	p.consume(lexer.LeftParenthesis, "Expect '(' before parameters")
+	parameters, parameterTypes := p.parameters()
+	returnType := p.typeAnnotation()
+	arrow := p.consume(lexer.Arrow, "Expect '=>' after parameters")
+	value := p.expression()
+	body := []ast.Statement{ast.NewReturnStatement(arrow, value)}
+
+	return ast.NewFunctionExpression(
+		ast.NewFunctionStatement(anonymousName(arrow), parameters, parameterTypes, returnType, body))
+}
+
+// isArrowFunction looks ahead for a parameter list followed by '=>', the parenthesis is a grouping otherwise
+func (p *Parser) isArrowFunction() bool {
+	index := p.current + 1
+	if p.tokens[index].Type != lexer.RightParenthesis {
+		for p.tokens[index].Type == lexer.Identifier {
+			index = p.skipTypeAnnotation(index + 1)
+			if p.tokens[index].Type != lexer.Comma {
+				break
+			}
+			index++
+		}
+		if p.tokens[index].Type != lexer.RightParenthesis {
+			return false
+		}
+	}
+
+	return p.tokens[p.skipTypeAnnotation(index+1)].Type == lexer.Arrow
+}
+
+// skipTypeAnnotation returns the index of the token following the type annotation starting at index, if there is one
+func (p *Parser) skipTypeAnnotation(index int) int {
+	if p.tokens[index].Type == lexer.Colon && (p.tokens[index+1].Type == lexer.Identifier || p.tokens[index+1].Type == lexer.Nil) {
+		return index + 2
+	}
+
+	return index
+}
+
+// anonymousName returns the empty name of an anonymous function, located at the given token
+func anonymousName(position lexer.Token) lexer.Token {
+	return *lexer.NewToken(lexer.Identifier, "", nil, position.Span)
 }
 
 func (p *Parser) classDeclaration() ast.Statement {
@@ -175,27 +270,84 @@ func (p *Parser) classDeclaration() ast.Statement {
 	p.consume(lexer.LeftBrace, "Expect '{' before class body")
 
 	methods := make([]*ast.FunctionStatement, 0)
+	classMethods := make([]*ast.FunctionStatement, 0)
+	classFields := make([]*ast.VariableStatement, 0)
+	fields := make([]*ast.VariableStatement, 0)
 	for !p.check(lexer.RightBrace) && !p.isAtEnd() {
-		methods = append(methods, p.function("method").(*ast.FunctionStatement))
+		switch {
+		case p.isFieldDeclaration():
+			fields = append(fields, p.fieldDeclaration())
+		case !p.match(lexer.Class):
+			methods = append(methods, p.method())
+		case p.match(lexer.Var):
+			classFields = append(classFields, p.varDeclaration().(*ast.VariableStatement))
+		default:
+			classMethods = append(classMethods, p.method())
+		}
 	}
 
 	p.consume(lexer.RightBrace, "Expect '}' after class body")
 
-	return ast.NewClassStatement(name, superclass, methods)
+	return ast.NewClassStatement(name, superclass, methods, classMethods, classFields, fields)
+}
+
+// method parses a method, a getter is declared without parameter list: "area { return this.w * this.h; }"
+func (p *Parser) method() *ast.FunctionStatement {
+	isGetter := p.peekNext().Type == lexer.LeftBrace || p.peekNext().Type == lexer.Colon
+	if p.check(lexer.Identifier) && isGetter && p.peek().Lexeme != "init" {
+		name := p.advance()
+		returnType := p.typeAnnotation()
+		p.consume(lexer.LeftBrace, "Getter body must start with '{'")
+		return ast.NewGetterStatement(name, returnType, p.block())
+	}
+
+	return p.function("method").(*ast.FunctionStatement)
+}
+
+// isFieldDeclaration tells if the class body continues with the declaration of an instance field: "x: number;"
+func (p *Parser) isFieldDeclaration() bool {
+	index := p.skipTypeAnnotation(p.current + 1)
+	return p.check(lexer.Identifier) && index > p.current+1 && p.tokens[index].Type == lexer.Semicolon
+}
+
+func (p *Parser) fieldDeclaration() *ast.VariableStatement {
+	name := p.advance()
+	fieldType := p.typeAnnotation()
+	p.consume(lexer.Semicolon, "Expect ';' after field declaration")
+
+	return ast.NewVariableStatement(name, fieldType, nil)
 }
 
 func (p *Parser) statement() ast.Statement {
 	switch {
+	case p.check(lexer.Identifier) && p.peekNext().Type == lexer.Colon:
+		return p.labelledStatement()
+	case p.match(lexer.Break):
+		keyword := p.previous()
+		label := p.loopLabel("break")
+		return ast.NewBreakStatement(keyword, label)
+	case p.match(lexer.Continue):
+		keyword := p.previous()
+		label := p.loopLabel("continue")
+		return ast.NewContinueStatement(keyword, label)
 	case p.match(lexer.For):
-		return p.forStatement()
+		return p.forStatement(nil)
 	case p.match(lexer.Print):
 		return p.printStatement()
 	case p.match(lexer.If):
 		return p.ifStatement()
+	case p.match(lexer.Import):
+		return p.importStatement()
+	case p.match(lexer.From):
+		return p.fromStatement()
 	case p.match(lexer.Return):
 		return p.returnStatement()
+	case p.match(lexer.Throw):
+		return p.throwStatement()
+	case p.match(lexer.Try):
+		return p.tryStatement()
 	case p.match(lexer.While):
-		return p.whileStatment()
+		return p.whileStatment(nil)
 	case p.match(lexer.LeftBrace):
 		return ast.NewBlockStatement(p.block())
 	default:
@@ -203,6 +355,35 @@ func (p *Parser) statement() ast.Statement {
 	}
 }
 
+// labelledStatement parses a loop preceded by a label: "outer: while (...) ..."
+func (p *Parser) labelledStatement() ast.Statement {
+	label := p.advance()
+	p.advance()
+
+	switch {
+	case p.match(lexer.For):
+		return p.forStatement(&label)
+	case p.match(lexer.While):
+		return p.whileStatment(&label)
+	default:
+		err := NewParseError(p.peek().Span, "Expect a loop after a label")
+		p.errorFormatter.PushError(err)
+		panic(err)
+	}
+}
+
+// loopLabel parses the optional label and the semicolon ending a break or a continue statement
+func (p *Parser) loopLabel(keyword string) *lexer.Token {
+	var label *lexer.Token
+	if p.match(lexer.Identifier) {
+		name := p.previous()
+		label = &name
+	}
+	p.consume(lexer.Semicolon, "Expect ';' after '"+keyword+"'")
+
+	return label
+}
+
 func (p *Parser) expressionStatement() ast.Statement {
 	expression := p.expression()
 	p.consume(lexer.Semicolon, "Expect ';' after value")
@@ -211,8 +392,13 @@ func (p *Parser) expressionStatement() ast.Statement {
 }
 
 // TODO: check where we create an additional block
-func (p *Parser) forStatement() ast.Statement {
+func (p *Parser) forStatement(label *lexer.Token) ast.Statement {
+	keyword := p.previous()
 	p.consume(lexer.LeftParenthesis, "Expect '(' after 'for'")
+	if p.check(lexer.Identifier) && p.peekNext().Type == lexer.Identifier && p.peekNext().Lexeme == "in" {
+		return p.forInStatement(keyword, label)
+	}
+
 	var initializer ast.Statement
 	switch {
 	case p.match(lexer.Semicolon):
@@ -221,32 +407,31 @@ func (p *Parser) forStatement() ast.Statement {
 	case p.match(lexer.Var):
 		initializer = p.varDeclaration()
 	default:
-		initializer = p.expressionStatement()
+		initializer = ast.NewExpressionStatement(p.clause())
+		p.consume(lexer.Semicolon, "Expect ';' after value")
 	}
 
 	var condition ast.Expression
 	if !p.check(lexer.Semicolon) {
-		condition = p.expression()
+		condition = p.clause()
 	}
 	p.consume(lexer.Semicolon, "Expect ';' after loop condition")
 
 	var increment ast.Expression
 	if !p.check(lexer.RightParenthesis) {
-		increment = p.expression()
+		increment = p.clause()
 	}
 	p.consume(lexer.RightParenthesis, "Expect ')' after for clauses")
 
 	body := p.statement()
-
-	if increment != nil {
-		body = ast.NewBlockStatement([]ast.Statement{body, ast.NewExpressionStatement(increment)})
-	}
+	end := p.previous()
 
 	if condition == nil {
-		condition = ast.NewLiteralExpression(ast.NewBooleanValue(true))
+		condition = ast.NewLiteralExpression(keyword, ast.NewBooleanValue(true))
 	}
 
-	var forLoop ast.Statement = ast.NewWhileStatement(condition, body)
+	// The increment is kept apart from the body so that continue doesn't skip it
+	var forLoop ast.Statement = ast.NewWhileStatement(keyword, label, condition, increment, body, end)
 	if initializer != nil {
 		forLoop = ast.NewBlockStatement([]ast.Statement{initializer, forLoop})
 	}
@@ -254,6 +439,31 @@ func (p *Parser) forStatement() ast.Statement {
 	return forLoop
 }
 
+// clause parses the expression of a for loop clause. Like a statement, a clause starting with a brace is a block in
+// the original grammar, it's an error instead of a map literal.
+func (p *Parser) clause() ast.Expression {
+	if p.check(lexer.LeftBrace) {
+		err := NewParseError(p.peek().Span, "expect expression")
+		p.errorFormatter.PushError(err)
+		panic(err)
+	}
+
+	return p.expression()
+}
+
+// forInStatement parses the rest of a for-in loop, after the opening parenthesis: "x in xs) body"
+func (p *Parser) forInStatement(keyword lexer.Token, label *lexer.Token) ast.Statement {
+	name := p.advance()
+	p.advance() // in
+	iterable := p.expression()
+	p.consume(lexer.RightParenthesis, "Expect ')' after for-in clause")
+
+	body := p.statement()
+	end := p.previous()
+
+	return ast.NewForInStatement(keyword, label, name, iterable, body, end)
+}
+
 func (p *Parser) ifStatement() ast.Statement {
 	p.consume(lexer.LeftParenthesis, "Expect '(' after 'if' statement")
 	expr := p.expression()
@@ -268,10 +478,78 @@ func (p *Parser) ifStatement() ast.Statement {
 }
 
 func (p *Parser) printStatement() ast.Statement {
+	keyword := p.previous()
 	value := p.expression()
 	p.consume(lexer.Semicolon, "Expect ';' after value")
 
-	return ast.NewPrintStatement(value)
+	return ast.NewPrintStatement(keyword, value)
+}
+
+// importStatement parses: import "path" as alias;
+func (p *Parser) importStatement() ast.Statement {
+	keyword := p.previous()
+	path := p.consume(lexer.String, "Expect module path after 'import'")
+	if !p.check(lexer.Identifier) || p.peek().Lexeme != "as" {
+		err := NewParseError(p.peek().Span, "Expect 'as' after module path")
+		p.errorFormatter.PushError(err)
+		panic(err)
+	}
+	p.advance()
+	alias := p.consume(lexer.Identifier, "Expect module name after 'as'")
+	p.consume(lexer.Semicolon, "Expect ';' after import")
+
+	return ast.NewImportStatement(keyword, path, &alias, nil)
+}
+
+// fromStatement parses: from "path" import name, other;
+func (p *Parser) fromStatement() ast.Statement {
+	keyword := p.previous()
+	path := p.consume(lexer.String, "Expect module path after 'from'")
+	p.consume(lexer.Import, "Expect 'import' after module path")
+	names := []lexer.Token{p.consume(lexer.Identifier, "Expect imported name")}
+	for p.match(lexer.Comma) {
+		names = append(names, p.consume(lexer.Identifier, "Expect imported name"))
+	}
+	p.consume(lexer.Semicolon, "Expect ';' after import")
+
+	return ast.NewImportStatement(keyword, path, nil, names)
+}
+
+func (p *Parser) throwStatement() ast.Statement {
+	keyword := p.previous()
+	value := p.expression()
+	p.consume(lexer.Semicolon, "Expect ';' after thrown value")
+
+	return ast.NewThrowStatement(keyword, value)
+}
+
+func (p *Parser) tryStatement() ast.Statement {
+	keyword := p.previous()
+	p.consume(lexer.LeftBrace, "Expect '{' after 'try'")
+	body := p.block()
+
+	var catch *ast.CatchClause
+	if p.match(lexer.Catch) {
+		p.consume(lexer.LeftParenthesis, "Expect '(' after 'catch'")
+		name := p.consume(lexer.Identifier, "Expect error variable name")
+		p.consume(lexer.RightParenthesis, "Expect ')' after error variable name")
+		p.consume(lexer.LeftBrace, "Expect '{' before catch body")
+		catch = ast.NewCatchClause(name, p.block())
+	}
+
+	var finally *ast.BlockStatement
+	if p.match(lexer.Finally) {
+		p.consume(lexer.LeftBrace, "Expect '{' after 'finally'")
+		finally = ast.NewBlockStatement(p.block())
+	}
+
+	if catch == nil && finally == nil {
+		err := NewParseError(p.peek().Span, "Expect 'catch' or 'finally' after try block")
+		p.errorFormatter.PushError(err)
+		panic(err)
+	}
+
+	return ast.NewTryStatement(keyword, body, catch, finally)
 }
 
 func (p *Parser) returnStatement() ast.Statement {
@@ -287,20 +565,22 @@ func (p *Parser) returnStatement() ast.Statement {
 	return ast.NewReturnStatement(keyword, value)
 }
 
-func (p *Parser) whileStatment() ast.Statement {
+func (p *Parser) whileStatment(label *lexer.Token) ast.Statement {
+	keyword := p.previous()
 	p.consume(lexer.LeftParenthesis, "Expect '(' after 'while'")
 	condition := p.expression()
 	p.consume(lexer.RightParenthesis, "Expect ')' after 'while' condition")
 	body := p.statement()
 
-	return ast.NewWhileStatement(condition, body)
+	return ast.NewWhileStatement(keyword, label, condition, nil, body, p.previous())
 }
 
 func (p *Parser) block() []ast.Statement {
 	statements := make([]ast.Statement, 0)
 	for !p.check(lexer.RightBrace) && !p.isAtEnd() {
-		s := p.declaration()
-		statements = append(statements, s)
+		if s := p.declaration(); s != nil {
+			statements = append(statements, s)
+		}
 	}
 	p.consume(lexer.RightBrace, "Expect '}' after block")
 
@@ -323,11 +603,13 @@ func (p *Parser) assignment() ast.Expression {
 			return ast.NewAssignmentExpression(expression.Name, value)
 		case *ast.GetExpression:
 			return ast.NewSetExpression(expression.Object, expression.Name, value)
+		case *ast.IndexGetExpression:
+			return ast.NewIndexSetExpression(expression.Object, expression.Bracket, expression.Index, value)
 		}
 
 		// No need to go to put the parser in recovery mode
 		// TODO: better error message
-		p.errorFormatter.PushError(NewParseError(equalsToken.Line, "Invalid assignment target"))
+		p.errorFormatter.PushError(NewParseError(equalsToken.Span, "Invalid assignment target"))
 
 		return nil
 	}
@@ -430,6 +712,11 @@ loop:
 		case p.match(lexer.Dot):
 			name := p.consume(lexer.Identifier, "Expect property name after '.'")
 			expr = ast.NewGetExpression(expr, name)
+		case p.match(lexer.LeftBracket):
+			bracket := p.previous()
+			index := p.expression()
+			p.consume(lexer.RightBracket, "Expect ']' after index")
+			expr = ast.NewIndexGetExpression(expr, bracket, index)
 		default:
 			break loop
 		}
@@ -443,7 +730,7 @@ func (p *Parser) finishCall(callee ast.Expression) ast.Expression {
 	if !p.check(lexer.RightParenthesis) {
 		for next := true; next; next = p.match(lexer.Comma) {
 			if len(args) >= ast.Limits.MaxArgs {
-				p.errorFormatter.PushError(NewParseError(p.peek().Line, "Can't have more than 255 arguments"))
+				p.errorFormatter.PushError(NewParseError(p.peek().Span, "Can't have more than 255 arguments"))
 			}
 			args = append(args, p.expression())
 		}
@@ -454,14 +741,56 @@ func (p *Parser) finishCall(callee ast.Expression) ast.Expression {
 	return ast.NewCallExpression(callee, position, args)
 }
 
+// interpolation parses a string literal with interpolated expressions, it becomes the concatenation of its parts:
+// "a${x}b" is parsed as "a" + (x converted to a string) + "b"
+func (p *Parser) interpolation() ast.Expression {
+	part := p.previous()
+	var expression ast.Expression
+	for {
+		if prefix := part.Literal.(*lexer.StringLiteral).Value; prefix != "" || expression == nil {
+			expression = p.concatenate(expression, part, ast.NewLiteralExpression(part, ast.NewStringValue(prefix)))
+		}
+		value := p.expression()
+		expression = p.concatenate(expression, part, ast.NewStringifyExpression(part, value))
+
+		if !p.match(lexer.Interpolation) {
+			break
+		}
+		part = p.previous()
+	}
+
+	part = p.consume(lexer.String, "Expect '}' after interpolated expression")
+	if suffix := part.Literal.(*lexer.StringLiteral).Value; suffix != "" {
+		expression = p.concatenate(expression, part, ast.NewLiteralExpression(part, ast.NewStringValue(suffix)))
+	}
+
+	return expression
+}
+
+// isStringContinuation tells if a part of a string literal follows an interpolated expression,
+// it ends the expression instead of starting a new literal
+func isStringContinuation(token lexer.Token) bool {
+	return strings.HasPrefix(token.Lexeme, "}")
+}
+
+// concatenate adds a part to an interpolated string, the part of the literal is the position of the operator
+func (p *Parser) concatenate(lhs ast.Expression, position lexer.Token, rhs ast.Expression) ast.Expression {
+	if lhs == nil {
+		return rhs
+	}
+
+	operator := *lexer.NewToken(lexer.Plus, "+", nil, position.Span)
+	return ast.NewBinaryExpression(lhs, operator, rhs)
+}
+
 func (p *Parser) primary() ast.Expression {
 	switch {
 	case p.match(lexer.False):
-		return ast.NewLiteralExpression(ast.NewBooleanValue(false))
+		return ast.NewLiteralExpression(p.previous(), ast.NewBooleanValue(false))
 	case p.match(lexer.True):
-		return ast.NewLiteralExpression(ast.NewBooleanValue(true))
+		return ast.NewLiteralExpression(p.previous(), ast.NewBooleanValue(true))
 	case p.match(lexer.Nil):
-		return ast.NewLiteralExpression(ast.NewNilValue())
+		return ast.NewLiteralExpression(p.previous(), ast.NewNilValue())
 	case p.match(lexer.This):
 		return ast.NewThisExpression(p.previous())
 	case p.match(lexer.Super):
@@ -470,17 +799,50 @@ func (p *Parser) primary() ast.Expression {
 		method := p.consume(lexer.Identifier, "Expect superclass method name")
 		return ast.NewSuperExpression(keyword, method)
 	case p.match(lexer.Number):
-		return ast.NewLiteralExpression(ast.NewNumberValue(p.previous().Literal.(*lexer.NumberLiteral).Value))
-	case p.match(lexer.String):
-		return ast.NewLiteralExpression(ast.NewStringValue(p.previous().Literal.(*lexer.StringLiteral).Value))
+		return ast.NewLiteralExpression(p.previous(), ast.NewNumberValue(p.previous().Literal.(*lexer.NumberLiteral).Value))
+	case p.check(lexer.String) && !isStringContinuation(p.peek()):
+		p.advance()
+		return ast.NewLiteralExpression(p.previous(), ast.NewStringValue(p.previous().Literal.(*lexer.StringLiteral).Value))
+	case p.check(lexer.Interpolation) && !isStringContinuation(p.peek()):
+		p.advance()
+		return p.interpolation()
 	case p.match(lexer.Identifier):
 		return ast.NewVariableExpression(p.previous())
+	case p.check(lexer.Fun) && p.peekNext().Type == lexer.LeftParenthesis:
+		p.advance()
+		return p.functionExpression()
+	case p.check(lexer.LeftParenthesis) && p.isArrowFunction():
+		return p.arrowFunction()
 	case p.match(lexer.LeftParenthesis):
 		expr := p.expression()
 		p.consume(lexer.RightParenthesis, "Expect ')' after expression")
 		return ast.NewGroupingExpression(expr)
+	case p.match(lexer.LeftBracket):
+		bracket := p.previous()
+		elements := make([]ast.Expression, 0)
+		if !p.check(lexer.RightBracket) {
+			for next := true; next; next = p.match(lexer.Comma) {
+				elements = append(elements, p.expression())
+			}
+		}
+		p.consume(lexer.RightBracket, "Expect ']' after list elements")
+		return ast.NewListExpression(bracket, elements)
+	case p.match(lexer.LeftBrace):
+		// A brace starting a statement is a block, a map literal can only appear in an expression
+		brace := p.previous()
+		keys := make([]ast.Expression, 0)
+		values := make([]ast.Expression, 0)
+		if !p.check(lexer.RightBrace) {
+			for next := true; next; next = p.match(lexer.Comma) {
+				keys = append(keys, p.expression())
+				p.consume(lexer.Colon, "Expect ':' after map key")
+				values = append(values, p.expression())
+			}
+		}
+		p.consume(lexer.RightBrace, "Expect '}' after map entries")
+		return ast.NewMapExpression(brace, keys, values)
 	default:
-		err := NewParseError(p.peek().Line, "expect expression")
+		err := NewParseError(p.peek().Span, "expect expression")
 		p.errorFormatter.PushError(err)
 		panic(err)
 	}
@@ -520,6 +882,14 @@ func (p *Parser) peek() lexer.Token {
 	return p.tokens[p.current]
 }
 
+func (p *Parser) peekNext() lexer.Token {
+	if p.isAtEnd() {
+		return p.peek()
+	}
+
+	return p.tokens[p.current+1]
+}
+
 func (p *Parser) previous() lexer.Token {
 	return p.tokens[p.current-1]
 }
@@ -530,7 +900,7 @@ func (p *Parser) consume(tokenType lexer.TokenType, message string) lexer.Token
 		return p.advance()
 	}
 
-	err := NewParseError(p.peek().Line, message)
+	err := NewParseError(p.peek().Span, message)
 	p.errorFormatter.PushError(err)
 	panic(err)
 }
@@ -544,8 +914,16 @@ func (p *Parser) synchronize() {
 		}
 
 		switch p.peek().Type {
+		case lexer.Break:
+			fallthrough
 		case lexer.Class:
 			fallthrough
+		case lexer.Continue:
+			fallthrough
+		case lexer.From:
+			fallthrough
+		case lexer.Import:
+			fallthrough
 		case lexer.Var:
 			fallthrough
 		case lexer.For:
@@ -557,6 +935,10 @@ func (p *Parser) synchronize() {
 		case lexer.Print:
 			fallthrough
 		case lexer.Return:
+			fallthrough
+		case lexer.Throw:
+			fallthrough
+		case lexer.Try:
 			return
 		default:
 			p.advance()
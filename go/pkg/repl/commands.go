@@ -0,0 +1,140 @@
+package repl
+
+import (
+	"fmt"
+	"strings"
+	"time"
+	"unicode"
+
+	"github.com/fpotier/lox/go/pkg/ast"
+	"github.com/fpotier/lox/go/pkg/lexer"
+	"github.com/fpotier/lox/go/pkg/loxerror"
+	"github.com/fpotier/lox/go/pkg/parser"
+)
+
+// commandPrefix starts the inputs which are meta-commands instead of Lox code
+const commandPrefix = ":"
+
+type command struct {
+	name string
+	// argument names the argument of the command, it's empty if the command has no argument
+	argument    string
+	description string
+}
+
+// commands are the meta-commands, they're listed by :help in this order
+var commands = []command{
+	{name: ":tokens", argument: "code", description: "print the tokens of the code"},
+	{name: ":ast", argument: "code", description: "print the syntax tree of the code"},
+	{name: ":env", argument: "", description: "list the global variables and the kind of their value"},
+	{name: ":load", argument: "file", description: "evaluate a script, its global variables are kept"},
+	{name: ":reset", argument: "", description: "discard the global variables"},
+	{name: ":time", argument: "code", description: "evaluate the code and print its duration"},
+	{name: ":help", argument: "", description: "list the commands"},
+}
+
+// runCommand runs a meta-command, the input is the command name followed by its argument
+func (r *REPL) runCommand(input string) {
+	name, argument := input, ""
+	if index := strings.IndexFunc(input, unicode.IsSpace); index >= 0 {
+		name, argument = input[:index], strings.TrimSpace(input[index:])
+	}
+	for _, command := range commands {
+		if command.name == name && command.argument != "" && argument == "" {
+			fmt.Fprintf(r.errorOutput, "Expect %s after '%s'\n", command.argument, name)
+			return
+		}
+	}
+
+	switch name {
+	case ":tokens":
+		r.printTokens(argument)
+	case ":ast":
+		r.printTree(argument)
+	case ":env":
+		r.printGlobals()
+	case ":load":
+		r.printError(r.engine.EvalFile(argument))
+	case ":reset":
+		r.engine.Reset()
+	case ":time":
+		start := time.Now()
+		r.evaluate(argument)
+		fmt.Fprintf(r.output, "Elapsed: %v\n", time.Since(start))
+	case ":help":
+		fmt.Fprintln(r.output, "Commands:")
+		for _, command := range commands {
+			fmt.Fprintf(r.output, "  %-13s %s\n", strings.TrimSpace(command.name+" "+command.argument), command.description)
+		}
+		fmt.Fprintf(r.output, "  %-13s %s\n", "exit", "end the session")
+	default:
+		fmt.Fprintf(r.errorOutput, "Unknown command '%s', ':help' lists the commands\n", name)
+	}
+}
+
+// printTokens prints the position, the type and the lexeme of the tokens of some code
+func (r *REPL) printTokens(sourceCode string) {
+	name := r.nextName()
+	errorFormatter := r.prepareErrors(name, sourceCode)
+	for _, token := range lexer.NewFileLexer(errorFormatter, name, sourceCode).Tokens() {
+		position := fmt.Sprintf("%d:%d", token.Span.Start.Line, token.Span.Start.Column)
+		line := fmt.Sprintf("%-7s %s %s", position, token.String(), token.Lexeme)
+		fmt.Fprintln(r.output, strings.TrimRightFunc(line, unicode.IsSpace))
+	}
+	r.printErrors(errorFormatter)
+}
+
+// printTree prints the syntax tree of some code, its final semicolon can be omitted like in the inputs
+func (r *REPL) printTree(sourceCode string) {
+	name := r.nextName()
+	sourceCode = withSemicolon(sourceCode)
+	errorFormatter := r.prepareErrors(name, sourceCode)
+	tokens := lexer.NewFileLexer(errorFormatter, name, sourceCode).Tokens()
+	statements := parser.NewParser(errorFormatter, tokens).Parse()
+	if errorFormatter.HasErrors() {
+		r.printErrors(errorFormatter)
+		return
+	}
+
+	printer := ast.NewAstPrinter(r.output, ast.DefaultTabSize)
+	printer.Dump(statements)
+}
+
+// printGlobals prints the global variables with the kind of their value, the native functions included
+func (r *REPL) printGlobals() {
+	for _, name := range r.engine.GlobalNames() {
+		if value, ok := r.engine.GetGlobal(name); ok {
+			fmt.Fprintf(r.output, "%s: %s\n", name, ast.KindString[value.Kind()])
+		}
+	}
+}
+
+// prepareErrors readies the error formatter of the engine to report the errors found in the code of a command
+func (r *REPL) prepareErrors(name string, sourceCode string) loxerror.ErrorFormatter {
+	errorFormatter := r.engine.ErrorFormatter()
+	errorFormatter.Reset()
+	if sourceFormatter, ok := errorFormatter.(loxerror.SourceFormatter); ok {
+		sourceFormatter.SetSource(name, sourceCode)
+	}
+
+	return errorFormatter
+}
+
+func (r *REPL) printErrors(errorFormatter loxerror.ErrorFormatter) {
+	for errorFormatter.HasErrors() {
+		loxError, _ := errorFormatter.PopError()
+		fmt.Fprint(r.errorOutput, errorFormatter.Format(loxError))
+	}
+}
+
+// completeCommand returns the command names starting with a prefix
+func completeCommand(prefix string) []string {
+	candidates := make([]string, 0)
+	for _, command := range commands {
+		if strings.HasPrefix(command.name, prefix) {
+			candidates = append(candidates, command.name)
+		}
+	}
+
+	return candidates
+}
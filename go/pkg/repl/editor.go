@@ -0,0 +1,364 @@
+package repl
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"unicode"
+)
+
+// errInterrupted is returned when the user cancels the input being typed with Ctrl-C
+var errInterrupted = errors.New("input interrupted")
+
+// maxHistory is the number of lines kept in the history
+const maxHistory = 1000
+
+// lineReader reads the lines of the inputs
+type lineReader interface {
+	// readLine prints a prompt and reads a line, io.EOF ends the session and errInterrupted cancels the input
+	readLine(prompt string) (string, error)
+}
+
+// scannerReader reads the lines of an input which isn't a terminal, like a pipe
+type scannerReader struct {
+	scanner *bufio.Scanner
+	output  io.Writer
+}
+
+func (s *scannerReader) readLine(prompt string) (string, error) {
+	fmt.Fprint(s.output, prompt)
+	if !s.scanner.Scan() {
+		if err := s.scanner.Err(); err != nil {
+			return "", err
+		}
+		return "", io.EOF
+	}
+
+	return s.scanner.Text(), nil
+}
+
+// The keys read by the editor, the escape sequences of the special keys are mapped to negative values
+const (
+	keyCtrlA     = 1
+	keyCtrlB     = 2
+	keyCtrlC     = 3
+	keyCtrlD     = 4
+	keyCtrlE     = 5
+	keyCtrlF     = 6
+	keyTab       = 9
+	keyLineFeed  = 10
+	keyCtrlK     = 11
+	keyCtrlL     = 12
+	keyEnter     = 13
+	keyCtrlN     = 14
+	keyCtrlP     = 16
+	keyCtrlU     = 21
+	keyCtrlW     = 23
+	keyEscape    = 27
+	keyBackspace = 127
+)
+
+const (
+	keyUnknown rune = -(iota + 1)
+	keyUp
+	keyDown
+	keyLeft
+	keyRight
+	keyHome
+	keyEnd
+	keyDelete
+)
+
+// escapeSequences are the keys sent by the terminals as an escape sequence, without the escape character
+var escapeSequences = map[string]rune{
+	"[A":  keyUp,
+	"[B":  keyDown,
+	"[C":  keyRight,
+	"[D":  keyLeft,
+	"[H":  keyHome,
+	"[F":  keyEnd,
+	"OH":  keyHome,
+	"OF":  keyEnd,
+	"[1~": keyHome,
+	"[7~": keyHome,
+	"[4~": keyEnd,
+	"[8~": keyEnd,
+	"[3~": keyDelete,
+}
+
+// editor reads the lines typed in a terminal with the usual editing keys, a history and the completion of the words
+type editor struct {
+	input  *bufio.Reader
+	output io.Writer
+	// rawMode lets the editor read the keys as they're typed, it returns the function restoring the terminal
+	rawMode func() (func(), error)
+	// complete returns the words completing a prefix
+	complete func(prefix string) []string
+	history  []string
+	// historyFile is where the lines are saved, the history isn't saved if it's empty
+	historyFile string
+	prompt      string
+	line        []rune
+	// cursor is the index of the rune before which the keys are inserted
+	cursor int
+}
+
+// newEditor creates an editor for a terminal, the history is loaded from the history file
+func newEditor(terminal *os.File, output io.Writer, complete func(prefix string) []string, historyFile string) *editor {
+	return &editor{
+		input:       bufio.NewReader(terminal),
+		output:      output,
+		rawMode:     func() (func(), error) { return makeRaw(terminal.Fd()) },
+		complete:    complete,
+		history:     loadHistory(historyFile),
+		historyFile: historyFile,
+		prompt:      "",
+		line:        make([]rune, 0),
+		cursor:      0,
+	}
+}
+
+func (e *editor) readLine(prompt string) (string, error) {
+	restore, err := e.rawMode()
+	if err != nil {
+		return "", err
+	}
+	defer restore()
+
+	e.prompt = prompt
+	e.line = make([]rune, 0)
+	e.cursor = 0
+	// browsed is the index of the history line shown, draft is the line typed before browsing the history
+	browsed := len(e.history)
+	draft := ""
+	e.refresh()
+
+	for {
+		key, err := e.readKey()
+		if err != nil {
+			return "", err
+		}
+
+		switch key {
+		case keyEnter, keyLineFeed:
+			fmt.Fprint(e.output, "\r\n")
+			line := string(e.line)
+			e.addHistory(line)
+			return line, nil
+		case keyCtrlC:
+			fmt.Fprint(e.output, "^C\r\n")
+			return "", errInterrupted
+		case keyCtrlD:
+			if len(e.line) == 0 {
+				fmt.Fprint(e.output, "\r\n")
+				return "", io.EOF
+			}
+			e.erase(e.cursor, e.cursor+1)
+		case keyDelete:
+			e.erase(e.cursor, e.cursor+1)
+		case keyBackspace, '\b':
+			e.erase(e.cursor-1, e.cursor)
+		case keyLeft, keyCtrlB:
+			e.cursor = max(e.cursor-1, 0)
+		case keyRight, keyCtrlF:
+			e.cursor = min(e.cursor+1, len(e.line))
+		case keyHome, keyCtrlA:
+			e.cursor = 0
+		case keyEnd, keyCtrlE:
+			e.cursor = len(e.line)
+		case keyCtrlK:
+			e.erase(e.cursor, len(e.line))
+		case keyCtrlU:
+			e.erase(0, e.cursor)
+		case keyCtrlW:
+			start := e.cursor
+			for start > 0 && unicode.IsSpace(e.line[start-1]) {
+				start--
+			}
+			for start > 0 && !unicode.IsSpace(e.line[start-1]) {
+				start--
+			}
+			e.erase(start, e.cursor)
+		case keyCtrlL:
+			fmt.Fprint(e.output, "\x1b[H\x1b[2J")
+		case keyUp, keyCtrlP:
+			if browsed > 0 {
+				if browsed == len(e.history) {
+					draft = string(e.line)
+				}
+				browsed--
+				e.setLine(e.history[browsed])
+			}
+		case keyDown, keyCtrlN:
+			if browsed < len(e.history) {
+				browsed++
+				if browsed == len(e.history) {
+					e.setLine(draft)
+				} else {
+					e.setLine(e.history[browsed])
+				}
+			}
+		case keyTab:
+			e.completeWord()
+		default:
+			if unicode.IsPrint(key) {
+				e.insert([]rune{key})
+			}
+		}
+		e.refresh()
+	}
+}
+
+// readKey reads a key, a special key is read from its escape sequence
+func (e *editor) readKey() (rune, error) {
+	key, _, err := e.input.ReadRune()
+	if err != nil || key != keyEscape {
+		return key, err
+	}
+
+	first, _, err := e.input.ReadRune()
+	if err != nil {
+		return 0, err
+	}
+	sequence := string(first)
+	// A control sequence ends with a letter or a tilde, the parameters before are digits and semicolons
+	for first == '[' || first == 'O' {
+		char, _, err := e.input.ReadRune()
+		if err != nil {
+			return 0, err
+		}
+		sequence += string(char)
+		if unicode.IsLetter(char) || char == '~' {
+			break
+		}
+	}
+	if key, ok := escapeSequences[sequence]; ok {
+		return key, nil
+	}
+
+	return keyUnknown, nil
+}
+
+// refresh redraws the line being edited and places the cursor
+func (e *editor) refresh() {
+	fmt.Fprintf(e.output, "\r%s%s\x1b[K", e.prompt, string(e.line))
+	if back := len(e.line) - e.cursor; back > 0 {
+		fmt.Fprintf(e.output, "\x1b[%dD", back)
+	}
+}
+
+func (e *editor) insert(text []rune) {
+	line := make([]rune, 0, len(e.line)+len(text))
+	line = append(line, e.line[:e.cursor]...)
+	line = append(line, text...)
+	e.line = append(line, e.line[e.cursor:]...)
+	e.cursor += len(text)
+}
+
+// erase removes the runes from start to end, the indexes out of the line are ignored
+func (e *editor) erase(start int, end int) {
+	start, end = max(start, 0), min(end, len(e.line))
+	if start >= end {
+		return
+	}
+	e.line = append(e.line[:start], e.line[end:]...)
+	e.cursor = start
+}
+
+func (e *editor) setLine(line string) {
+	e.line = []rune(line)
+	e.cursor = len(e.line)
+}
+
+// completeWord completes the word before the cursor with the prefix common to its completions, they're listed if
+// there is nothing to add
+func (e *editor) completeWord() {
+	start := e.cursor
+	for start > 0 && isWordRune(e.line[start-1]) {
+		start--
+	}
+	// The name of a meta-command is completed with its colon
+	if start == 1 && string(e.line[0]) == commandPrefix {
+		start = 0
+	}
+	prefix := string(e.line[start:e.cursor])
+	if prefix == "" {
+		return
+	}
+
+	candidates := e.complete(prefix)
+	if len(candidates) == 0 {
+		return
+	}
+	if common := commonPrefix(candidates); len(common) > len(prefix) {
+		e.insert([]rune(common[len(prefix):]))
+		return
+	}
+	if len(candidates) > 1 {
+		fmt.Fprintf(e.output, "\r\n%s\r\n", strings.Join(candidates, "  "))
+	}
+}
+
+func isWordRune(r rune) bool {
+	return r == '_' || unicode.IsLetter(r) || unicode.IsDigit(r)
+}
+
+func commonPrefix(words []string) string {
+	prefix := words[0]
+	for _, word := range words[1:] {
+		for !strings.HasPrefix(word, prefix) {
+			prefix = prefix[:len(prefix)-1]
+		}
+	}
+
+	return prefix
+}
+
+// addHistory appends a line to the history and to the history file, the blank lines and the repeated lines are skipped
+func (e *editor) addHistory(line string) {
+	if strings.TrimSpace(line) == "" || (len(e.history) > 0 && e.history[len(e.history)-1] == line) {
+		return
+	}
+	e.history = append(e.history, line)
+	if len(e.history) > maxHistory {
+		e.history = e.history[len(e.history)-maxHistory:]
+	}
+
+	if e.historyFile == "" {
+		return
+	}
+	// The session goes on without history file if it can't be written
+	file, err := os.OpenFile(e.historyFile, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0o600)
+	if err != nil {
+		return
+	}
+	defer file.Close()
+	fmt.Fprintln(file, line)
+}
+
+// loadHistory reads the last lines of a history file, the file is trimmed to them
+func loadHistory(historyFile string) []string {
+	history := make([]string, 0)
+	if historyFile == "" {
+		return history
+	}
+	content, err := os.ReadFile(historyFile)
+	if err != nil {
+		return history
+	}
+
+	for _, line := range strings.Split(string(content), "\n") {
+		if line != "" {
+			history = append(history, line)
+		}
+	}
+	if len(history) > maxHistory {
+		history = history[len(history)-maxHistory:]
+		_ = os.WriteFile(historyFile, []byte(strings.Join(history, "\n")+"\n"), 0o600)
+	}
+
+	return history
+}
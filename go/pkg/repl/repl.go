@@ -0,0 +1,213 @@
+// Package repl is the interactive prompt of glox: an input is read until its brackets are balanced, the value of a
+// final expression is printed and the globals are kept from an input to the next. The lines typed in a terminal can be
+// edited, they're saved in a history and the names are completed with the tab key. The inputs starting with a colon
+// are meta-commands inspecting the code and the session, like ":tokens" or ":env".
+package repl
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+	"sort"
+	"strings"
+
+	"github.com/fpotier/lox/go/pkg/ast"
+	"github.com/fpotier/lox/go/pkg/debug"
+	"github.com/fpotier/lox/go/pkg/lexer"
+	"github.com/fpotier/lox/go/pkg/lox"
+	"github.com/fpotier/lox/go/pkg/loxerror"
+	"github.com/fpotier/lox/go/pkg/parser"
+)
+
+const (
+	prompt = "lox> "
+	// continuationPrompt is shown while the input has open brackets
+	continuationPrompt = "...> "
+)
+
+type REPL struct {
+	// HistoryFile is where the lines typed in a terminal are saved, the history isn't saved if it's empty
+	HistoryFile string
+	engine      *lox.Engine
+	input       io.Reader
+	output      io.Writer
+	errorOutput io.Writer
+	// inputs is the number of inputs evaluated, it names their source code in the errors
+	inputs int
+}
+
+func New(engine *lox.Engine, input io.Reader, output io.Writer, errorOutput io.Writer) *REPL {
+	return &REPL{
+		HistoryFile: "",
+		engine:      engine,
+		input:       input,
+		output:      output,
+		errorOutput: errorOutput,
+		inputs:      0,
+	}
+}
+
+// Run evaluates the inputs until the end of the input or "exit", the lines are edited if the input is a terminal
+func (r *REPL) Run() error {
+	var reader lineReader = &scannerReader{scanner: bufio.NewScanner(r.input), output: r.output}
+	if file, ok := r.input.(*os.File); ok && isTerminal(file.Fd()) {
+		reader = newEditor(file, r.output, r.complete, r.HistoryFile)
+	}
+
+	for {
+		sourceCode, err := r.read(reader)
+		switch {
+		case errors.Is(err, io.EOF):
+			return nil
+		case err != nil:
+			return err
+		case strings.TrimSpace(sourceCode) == "exit":
+			return nil
+		case strings.HasPrefix(strings.TrimSpace(sourceCode), commandPrefix):
+			r.runCommand(strings.TrimSpace(sourceCode))
+		default:
+			r.evaluate(sourceCode)
+		}
+	}
+}
+
+// read reads the lines of an input until its brackets and its strings are closed
+func (r *REPL) read(reader lineReader) (string, error) {
+	lines := make([]string, 0)
+	for {
+		currentPrompt := prompt
+		if len(lines) > 0 {
+			currentPrompt = continuationPrompt
+		}
+		line, err := reader.readLine(currentPrompt)
+		if errors.Is(err, errInterrupted) {
+			lines = lines[:0]
+			continue
+		}
+		if err != nil {
+			return "", err
+		}
+
+		lines = append(lines, line)
+		sourceCode := strings.Join(lines, "\n")
+		if strings.TrimSpace(sourceCode) == "" {
+			lines = lines[:0]
+			continue
+		}
+		if !isIncomplete(sourceCode) {
+			return sourceCode, nil
+		}
+	}
+}
+
+// evaluate runs an input and prints the value of its final expression, unless it's nil.
+// Ctrl-C interrupts the evaluation instead of the session.
+func (r *REPL) evaluate(sourceCode string) {
+	name := r.nextName()
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+
+	value, err := r.engine.EvalInput(ctx, name, withSemicolon(sourceCode))
+	if err != nil {
+		r.printError(err)
+		return
+	}
+	if value != nil && value.Kind() != ast.Nil {
+		fmt.Fprintln(r.output, debug.Describe(value))
+	}
+}
+
+// printError prints the error of an evaluation, the errors of a *lox.Error are formatted by the engine
+func (r *REPL) printError(err error) {
+	var loxError *lox.Error
+	switch {
+	case errors.As(err, &loxError):
+		for _, e := range loxError.Errors {
+			fmt.Fprint(r.errorOutput, r.engine.ErrorFormatter().Format(e))
+		}
+	case err != nil:
+		fmt.Fprintln(r.errorOutput, err)
+	}
+}
+
+// nextName names the source code of a new input in the errors
+func (r *REPL) nextName() string {
+	r.inputs++
+
+	return fmt.Sprintf("<input %d>", r.inputs)
+}
+
+// complete returns the keywords and the global variables starting with a prefix, or the commands if it starts with
+// the command prefix
+func (r *REPL) complete(prefix string) []string {
+	if strings.HasPrefix(prefix, commandPrefix) {
+		return completeCommand(prefix)
+	}
+	words := make(map[string]bool)
+	for _, word := range append(lexer.Keywords(), r.engine.GlobalNames()...) {
+		if strings.HasPrefix(word, prefix) {
+			words[word] = true
+		}
+	}
+
+	candidates := make([]string, 0, len(words))
+	for word := range words {
+		candidates = append(candidates, word)
+	}
+	sort.Strings(candidates)
+
+	return candidates
+}
+
+// isIncomplete tells if an input goes on with the next line: a bracket or a string is still open
+func isIncomplete(sourceCode string) bool {
+	errorFormatter := loxerror.NewJSONErrorFormatter()
+	tokens := lexer.NewLexer(errorFormatter, sourceCode).Tokens()
+	for _, e := range errorFormatter.Errors() {
+		if _, ok := e.(*lexer.UnterminatedString); ok {
+			return true
+		}
+	}
+
+	depth := 0
+	for _, token := range tokens {
+		switch token.Type {
+		case lexer.LeftParenthesis, lexer.LeftBrace, lexer.LeftBracket:
+			depth++
+		case lexer.RightParenthesis, lexer.RightBrace, lexer.RightBracket:
+			depth--
+		case lexer.Interpolation:
+			// The parts of an interpolated string after the first one start with the brace closing an expression
+			if !strings.HasPrefix(token.Lexeme, "}") {
+				depth++
+			}
+		case lexer.String:
+			if strings.HasPrefix(token.Lexeme, "}") {
+				depth--
+			}
+		}
+	}
+
+	return depth > 0
+}
+
+// withSemicolon adds the semicolon missing at the end of an input, "1 + 2" is evaluated as "1 + 2;"
+func withSemicolon(sourceCode string) string {
+	if parses(sourceCode) || !parses(sourceCode+";") {
+		return sourceCode
+	}
+
+	return sourceCode + ";"
+}
+
+func parses(sourceCode string) bool {
+	errorFormatter := loxerror.NewJSONErrorFormatter()
+	tokens := lexer.NewLexer(errorFormatter, sourceCode).Tokens()
+	parser.NewParser(errorFormatter, tokens).Parse()
+
+	return !errorFormatter.HasErrors()
+}
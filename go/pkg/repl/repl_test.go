@@ -0,0 +1,143 @@
+package repl
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/fpotier/lox/go/pkg/lox"
+	"github.com/fpotier/lox/go/pkg/loxerror"
+)
+
+func TestREPL(t *testing.T) {
+	inputs := strings.Join([]string{
+		"var total = 1;",
+		"fun add(x) {",
+		"  return total + x;",
+		"}",
+		"add(2)",
+		`"a${`,
+		`total}"`,
+		"nil.field;",
+		"total = total * 10",
+		"print _;",
+		"exit",
+		"print 0;",
+	}, "\n")
+	var output, errorOutput bytes.Buffer
+	engine := lox.NewEngine(lox.WithStdout(&output), lox.WithErrorFormatter(loxerror.NewTextErrorFormatter(false)))
+	if err := New(engine, strings.NewReader(inputs), &output, &errorOutput).Run(); err != nil {
+		t.Fatal(err)
+	}
+
+	expected := "lox> lox> ...> ...> lox> 3\nlox> ...> \"a1\"\nlox> lox> 10\nlox> 10\nlox> "
+	if output.String() != expected {
+		t.Errorf("unexpected output:\n%q", output.String())
+	}
+	if !strings.Contains(errorOutput.String(), "<input 5>, line 1, column 5") {
+		t.Errorf("unexpected errors:\n%s", errorOutput.String())
+	}
+}
+
+func TestCommands(t *testing.T) {
+	script := filepath.Join(t.TempDir(), "lib.lox")
+	if err := os.WriteFile(script, []byte("fun twice(x) { return x * 2; }\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	inputs := strings.Join([]string{
+		":tokens x + 1",
+		":ast -x",
+		":load " + script,
+		":env",
+		":time twice(2)",
+		":reset",
+		":env",
+		":tokens",
+		":unknown",
+		":help",
+	}, "\n")
+	var output, errorOutput bytes.Buffer
+	engine := lox.NewEngine(lox.WithStdout(&output), lox.WithErrorFormatter(loxerror.NewTextErrorFormatter(false)))
+	if err := New(engine, strings.NewReader(inputs), &output, &errorOutput).Run(); err != nil {
+		t.Fatal(err)
+	}
+
+	expected := []string{
+		"lox> 1:1     IDENTIFIER x\n1:3     PLUS +\n1:5     NUMBER_LITERAL (value:1) 1\n1:6     EOF\n",
+		"lox> ExpressionStatement\n  expression:\n    UnaryExpression\n",
+		"lox> lox> ",
+		"twice: function\n",
+		"4\nElapsed: ",
+		"  :reset        discard the global variables\n",
+	}
+	for _, part := range expected {
+		if !strings.Contains(output.String(), part) {
+			t.Errorf("expected %q in the output:\n%s", part, output.String())
+		}
+	}
+	if strings.Count(output.String(), "twice: function") != 1 {
+		t.Errorf("expected :reset to discard the globals:\n%s", output.String())
+	}
+	expectedErrors := "Expect code after ':tokens'\nUnknown command ':unknown', ':help' lists the commands\n"
+	if errorOutput.String() != expectedErrors {
+		t.Errorf("unexpected errors:\n%s", errorOutput.String())
+	}
+}
+
+// newTestEditor creates an editor reading keys from a string, the terminal mode isn't changed
+func newTestEditor(keys string, output io.Writer, historyFile string) *editor {
+	e := newEditor(nil, output, func(prefix string) []string {
+		candidates := make([]string, 0)
+		for _, word := range []string{"print", "total", "totals", ":tokens"} {
+			if strings.HasPrefix(word, prefix) {
+				candidates = append(candidates, word)
+			}
+		}
+		return candidates
+	}, historyFile)
+	e.input = bufio.NewReader(strings.NewReader(keys))
+	e.rawMode = func() (func(), error) { return func() {}, nil }
+
+	return e
+}
+
+func TestEditor(t *testing.T) {
+	historyFile := filepath.Join(t.TempDir(), "history")
+	keys := strings.Join([]string{
+		"pri\t 1;\r",                        // completion of a single word
+		"to\t\ts;\r",                        // completion of the common prefix, then listing
+		"x = 2\x1b[D\x1b[D\x1b[D:\x1b[F;\r", // cursor moves
+		"abc\x01\x0b\x1b[A\x1b[A\r",         // Ctrl-A, Ctrl-K then history
+		":to\t 1\r",                         // completion of a command name
+		"lost\x03",
+		"\x04",
+	}, "")
+	e := newTestEditor(keys, io.Discard, historyFile)
+
+	expected := []string{"print 1;", "totals;", "x := 2;", "totals;", ":tokens 1"}
+	for _, line := range expected {
+		actual, err := e.readLine(prompt)
+		if err != nil || actual != line {
+			t.Fatalf("expected %q, got %q (%v)", line, actual, err)
+		}
+	}
+	if _, err := e.readLine(prompt); !errors.Is(err, errInterrupted) {
+		t.Errorf("expected an interruption, got %v", err)
+	}
+	if _, err := e.readLine(prompt); !errors.Is(err, io.EOF) {
+		t.Errorf("expected the end of the input, got %v", err)
+	}
+
+	history, err := os.ReadFile(historyFile)
+	if err != nil || string(history) != "print 1;\ntotals;\nx := 2;\ntotals;\n:tokens 1\n" {
+		t.Errorf("unexpected history file: %q (%v)", history, err)
+	}
+	if reloaded := newTestEditor("", io.Discard, historyFile); len(reloaded.history) != 5 {
+		t.Errorf("unexpected history: %v", reloaded.history)
+	}
+}
@@ -0,0 +1,14 @@
+//go:build !linux
+
+package repl
+
+import "errors"
+
+// isTerminal is false on the systems whose terminal modes aren't supported, the lines are read without editing
+func isTerminal(_ uintptr) bool {
+	return false
+}
+
+func makeRaw(_ uintptr) (func(), error) {
+	return nil, errors.New("line editing is not supported on this system")
+}
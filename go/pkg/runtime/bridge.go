@@ -0,0 +1,321 @@
+package runtime
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"reflect"
+	"sort"
+
+	"github.com/fpotier/lox/go/pkg/ast"
+)
+
+var (
+	ErrUnsupportedType = errors.New("unsupported type")
+	errorType          = reflect.TypeOf((*error)(nil)).Elem()
+	loxValueType       = reflect.TypeOf((*ast.LoxValue)(nil)).Elem()
+)
+
+// ToLoxValue converts a Go value to a Lox value.
+// Booleans, numbers, strings and nil are converted to their Lox counterpart, slices, arrays and maps are copied in
+// lists and maps, functions become native functions and the other values (structs, pointers, etc.) are wrapped in a
+// host object giving access to their exported fields and methods.
+func ToLoxValue(value any) (ast.LoxValue, error) {
+	return ToNamedLoxValue("", value)
+}
+
+// ToNamedLoxValue converts a Go value to a Lox value, name is given to the converted functions
+func ToNamedLoxValue(name string, value any) (ast.LoxValue, error) {
+	if loxValue, ok := value.(ast.LoxValue); ok {
+		return loxValue, nil
+	}
+
+	return toLoxValue(name, reflect.ValueOf(value))
+}
+
+// FromLoxValue converts a Lox value to a Go value of the given type
+func FromLoxValue(value ast.LoxValue, goType reflect.Type) (reflect.Value, error) {
+	if goType == loxValueType {
+		return reflect.ValueOf(&value).Elem(), nil
+	}
+
+	switch value := value.(type) {
+	case *ast.NilValue:
+		switch goType.Kind() {
+		case reflect.Interface, reflect.Pointer, reflect.Slice, reflect.Map, reflect.Func:
+			return reflect.Zero(goType), nil
+		}
+	case *ast.BooleanValue:
+		if goType.Kind() == reflect.Bool || goType.Kind() == reflect.Interface {
+			return convertTo(reflect.ValueOf(value.Value), goType)
+		}
+	case *ast.StringValue:
+		if goType.Kind() == reflect.String || goType.Kind() == reflect.Interface {
+			return convertTo(reflect.ValueOf(value.Value), goType)
+		}
+	case *ast.NumberValue:
+		return numberFromLoxValue(value.Value, goType)
+	case *LoxList:
+		if goType.Kind() == reflect.Slice || goType.Kind() == reflect.Array {
+			return sliceFromLoxList(value, goType)
+		}
+	case *LoxMap:
+		if goType.Kind() == reflect.Map {
+			return mapFromLoxMap(value, goType)
+		}
+	case *HostObject:
+		if converted, err := convertTo(value.value, goType); err == nil {
+			return converted, nil
+		}
+		if value.value.Kind() == reflect.Pointer {
+			return convertTo(value.value.Elem(), goType)
+		}
+	}
+
+	if reflect.TypeOf(value).AssignableTo(goType) {
+		return reflect.ValueOf(value), nil
+	}
+
+	return reflect.Value{}, fmt.Errorf("can't convert %s to %s", ast.KindString[value.Kind()], goType)
+}
+
+func toLoxValue(name string, value reflect.Value) (ast.LoxValue, error) {
+	if !value.IsValid() {
+		return ast.NewNilValue(), nil
+	}
+
+	switch value.Kind() {
+	case reflect.Bool:
+		return ast.NewBooleanValue(value.Bool()), nil
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return ast.NewNumberValue(float64(value.Int())), nil
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		return ast.NewNumberValue(float64(value.Uint())), nil
+	case reflect.Float32, reflect.Float64:
+		return ast.NewNumberValue(value.Float()), nil
+	case reflect.String:
+		return ast.NewStringValue(value.String()), nil
+	case reflect.Func:
+		if value.IsNil() {
+			return ast.NewNilValue(), nil
+		}
+		return newHostFunction(name, value)
+	case reflect.Interface, reflect.Pointer:
+		if value.IsNil() {
+			return ast.NewNilValue(), nil
+		}
+		if loxValue, ok := value.Interface().(ast.LoxValue); ok {
+			return loxValue, nil
+		}
+		if value.Kind() == reflect.Interface {
+			return toLoxValue(name, value.Elem())
+		}
+		switch value.Elem().Kind() {
+		case reflect.Slice, reflect.Array, reflect.Map:
+			return toLoxValue(name, value.Elem())
+		}
+		return NewHostObject(value), nil
+	case reflect.Struct:
+		// A field of a struct behind a pointer or an element of a slice is shared, so that assigning its fields changes
+		// the host value. The other structs are copied so that the fields can be assigned and the pointer receiver
+		// methods called.
+		if value.CanAddr() {
+			return NewHostObject(value.Addr()), nil
+		}
+		pointer := reflect.New(value.Type())
+		pointer.Elem().Set(value)
+		return NewHostObject(pointer), nil
+	case reflect.Slice, reflect.Array:
+		return loxListFromSlice(value)
+	case reflect.Map:
+		return loxMapFromMap(value)
+	default:
+		return nil, fmt.Errorf("%w: %s", ErrUnsupportedType, value.Type())
+	}
+}
+
+// loxListFromSlice copies the elements of a Go slice or array in a new list
+func loxListFromSlice(slice reflect.Value) (*LoxList, error) {
+	elements := make([]ast.LoxValue, slice.Len())
+	for index := range elements {
+		element, err := toLoxValue("", slice.Index(index))
+		if err != nil {
+			return nil, fmt.Errorf("element %d: %w", index, err)
+		}
+		elements[index] = element
+	}
+
+	return NewLoxList(elements), nil
+}
+
+// loxMapFromMap copies the entries of a Go map in a new map, sorted by key since a Go map has no order
+func loxMapFromMap(goMap reflect.Value) (*LoxMap, error) {
+	loxMap := NewLoxMap()
+	iterator := goMap.MapRange()
+	for iterator.Next() {
+		key, err := toLoxValue("", iterator.Key())
+		if err != nil {
+			return nil, fmt.Errorf("key %v: %w", iterator.Key(), err)
+		}
+		hashable, ok := key.(ast.Hashable)
+		if !ok {
+			return nil, fmt.Errorf("%w: map key %s", ErrUnsupportedType, goMap.Type().Key())
+		}
+		value, err := toLoxValue("", iterator.Value())
+		if err != nil {
+			return nil, fmt.Errorf("value of %v: %w", iterator.Key(), err)
+		}
+		loxMap.keys = append(loxMap.keys, key)
+		loxMap.entries[hashable.HashKey()] = value
+	}
+	sort.Slice(loxMap.keys, func(i int, j int) bool { return lessKey(loxMap.keys[i], loxMap.keys[j]) })
+
+	return loxMap, nil
+}
+
+// lessKey orders the numbers and the strings by value, the other keys by kind and representation
+func lessKey(a ast.LoxValue, b ast.LoxValue) bool {
+	switch a := a.(type) {
+	case *ast.NumberValue:
+		if b, ok := b.(*ast.NumberValue); ok {
+			return a.Value < b.Value
+		}
+	case *ast.StringValue:
+		if b, ok := b.(*ast.StringValue); ok {
+			return a.Value < b.Value
+		}
+	}
+	if a.Kind() != b.Kind() {
+		return a.Kind() < b.Kind()
+	}
+
+	return a.String() < b.String()
+}
+
+// sliceFromLoxList copies the elements of a list in a new Go slice, or in a Go array of the same length
+func sliceFromLoxList(list *LoxList, goType reflect.Type) (reflect.Value, error) {
+	var slice reflect.Value
+	if goType.Kind() == reflect.Array {
+		if goType.Len() != len(list.elements) {
+			return reflect.Value{}, fmt.Errorf("can't convert a list of %d elements to %s", len(list.elements), goType)
+		}
+		slice = reflect.New(goType).Elem()
+	} else {
+		slice = reflect.MakeSlice(goType, len(list.elements), len(list.elements))
+	}
+	for index, element := range list.elements {
+		goElement, err := FromLoxValue(element, goType.Elem())
+		if err != nil {
+			return reflect.Value{}, fmt.Errorf("element %d: %w", index, err)
+		}
+		slice.Index(index).Set(goElement)
+	}
+
+	return slice, nil
+}
+
+// mapFromLoxMap copies the entries of a map in a new Go map
+func mapFromLoxMap(loxMap *LoxMap, goType reflect.Type) (reflect.Value, error) {
+	goMap := reflect.MakeMapWithSize(goType, len(loxMap.keys))
+	for _, key := range loxMap.keys {
+		goKey, err := FromLoxValue(key, goType.Key())
+		if err != nil {
+			return reflect.Value{}, fmt.Errorf("key %s: %w", key, err)
+		}
+		goValue, err := FromLoxValue(loxMap.entries[key.(ast.Hashable).HashKey()], goType.Elem())
+		if err != nil {
+			return reflect.Value{}, fmt.Errorf("value of %s: %w", key, err)
+		}
+		goMap.SetMapIndex(goKey, goValue)
+	}
+
+	return goMap, nil
+}
+
+func numberFromLoxValue(number float64, goType reflect.Type) (reflect.Value, error) {
+	converted := reflect.New(goType).Elem()
+	switch goType.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		if number != math.Trunc(number) || converted.OverflowInt(int64(number)) {
+			return reflect.Value{}, fmt.Errorf("%v doesn't fit in %s", number, goType)
+		}
+		converted.SetInt(int64(number))
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		if number < 0 || number != math.Trunc(number) || converted.OverflowUint(uint64(number)) {
+			return reflect.Value{}, fmt.Errorf("%v doesn't fit in %s", number, goType)
+		}
+		converted.SetUint(uint64(number))
+	case reflect.Float32, reflect.Float64:
+		converted.SetFloat(number)
+	case reflect.Interface:
+		return convertTo(reflect.ValueOf(number), goType)
+	default:
+		return reflect.Value{}, fmt.Errorf("can't convert number to %s", goType)
+	}
+
+	return converted, nil
+}
+
+func convertTo(value reflect.Value, goType reflect.Type) (reflect.Value, error) {
+	switch {
+	case value.Type().AssignableTo(goType):
+		converted := reflect.New(goType).Elem()
+		converted.Set(value)
+		return converted, nil
+	case value.Type().ConvertibleTo(goType) && value.Kind() == goType.Kind():
+		return value.Convert(goType), nil
+	default:
+		return reflect.Value{}, fmt.Errorf("can't convert %s to %s", value.Type(), goType)
+	}
+}
+
+// newHostFunction wraps a Go function (or a bound method) in a native function.
+// The function may return nothing, a value, an error or a value and an error.
+func newHostFunction(name string, function reflect.Value) (*NativeFunction, error) {
+	functionType := function.Type()
+	if functionType.IsVariadic() {
+		return nil, fmt.Errorf("%w: variadic function %s", ErrUnsupportedType, functionType)
+	}
+	results := functionType.NumOut()
+	if results > 0 && functionType.Out(results-1) == errorType {
+		results--
+	}
+	if results > 1 {
+		return nil, fmt.Errorf("%w: %s returns several values", ErrUnsupportedType, functionType)
+	}
+	if name == "" {
+		name = functionType.String()
+	}
+
+	return NewNativeFunction(name, functionType.NumIn(), func(_ Evaluator, args []ast.LoxValue) (value ast.LoxValue, err error) {
+		// A panic of the Go function is raised as a runtime error instead of crashing the host
+		defer func() {
+			if r := recover(); r != nil {
+				value, err = nil, fmt.Errorf("panic: %v", r)
+			}
+		}()
+
+		goArgs := make([]reflect.Value, len(args))
+		for index, arg := range args {
+			goArg, err := FromLoxValue(arg, functionType.In(index))
+			if err != nil {
+				return nil, fmt.Errorf("argument %d: %w", index+1, err)
+			}
+			goArgs[index] = goArg
+		}
+
+		results := function.Call(goArgs)
+		if len(results) > 0 && functionType.Out(len(results)-1) == errorType {
+			if err, _ := results[len(results)-1].Interface().(error); err != nil {
+				return nil, err
+			}
+			results = results[:len(results)-1]
+		}
+
+		if len(results) == 0 {
+			return ast.NewNilValue(), nil
+		}
+
+		return toLoxValue("", results[0])
+	}), nil
+}
@@ -0,0 +1,80 @@
+package runtime
+
+import (
+	"sort"
+
+	"github.com/fpotier/lox/go/pkg/ast"
+	"github.com/fpotier/lox/go/pkg/lexer"
+)
+
+// DebugHook is called by the interpreter before each statement having a position, it may block to pause the program
+// and inspect it with Frames and Scopes
+type DebugHook interface {
+	BeforeStatement(interpreter *Interpreter, position lexer.Token)
+}
+
+// ScriptFrameName is the name of the frame of the top-level code
+const ScriptFrameName = "<script>"
+
+// Frame is a call in progress seen by a debugger
+type Frame struct {
+	Function string
+	// Position is the statement being executed in the innermost frame and the call site in its callers
+	Position    lexer.Token
+	environment *Environment
+}
+
+// Scope is a set of variables visible from a frame
+type Scope struct {
+	Name      string
+	Variables []Variable
+}
+
+type Variable struct {
+	Name  string
+	Value ast.LoxValue
+}
+
+// CallDepth is the number of calls in progress
+func (i *Interpreter) CallDepth() int {
+	return len(i.callStack)
+}
+
+// Frames returns the calls in progress, innermost first, position is the statement being executed.
+// The last frame is the top-level code.
+func (i *Interpreter) Frames(position lexer.Token) []Frame {
+	frames := make([]Frame, 0, len(i.callStack)+1)
+	environment := i.environment
+	for index := len(i.callStack) - 1; index >= 0; index-- {
+		call := i.callStack[index]
+		frames = append(frames, Frame{Function: call.function, Position: position, environment: environment})
+		position, environment = call.callSite, call.environment
+	}
+
+	return append(frames, Frame{Function: ScriptFrameName, Position: position, environment: environment})
+}
+
+// Scopes returns the local variables of a frame then the globals, the builtins and the shadowed variables are left out
+func (i *Interpreter) Scopes(frame Frame) []Scope {
+	locals := make([]Variable, 0)
+	globals := make([]Variable, 0)
+	seen := make(map[string]bool)
+	for environment := frame.environment; environment != nil; environment = environment.enclosing {
+		for name, value := range environment.symbols {
+			if seen[name] || name == "super" {
+				continue
+			}
+			seen[name] = true
+			if environment.enclosing != nil {
+				locals = append(locals, Variable{Name: name, Value: value})
+			} else if _, ok := i.builtins[name]; !ok {
+				globals = append(globals, Variable{Name: name, Value: value})
+			}
+		}
+	}
+
+	for _, variables := range [][]Variable{locals, globals} {
+		sort.Slice(variables, func(a, b int) bool { return variables[a].Name < variables[b].Name })
+	}
+	return []Scope{{Name: "Locals", Variables: locals}, {Name: "Globals", Variables: globals}}
+}
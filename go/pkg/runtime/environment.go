@@ -38,7 +38,7 @@ func (e *Environment) Get(name lexer.Token) ast.LoxValue {
 		return e.enclosing.Get(name)
 	}
 
-	panic(NewUndefinedVariable(name.Line, name.Lexeme))
+	panic(NewUndefinedVariable(name.Span, name.Lexeme))
 }
 
 func (e *Environment) GetAt(distance int, name string) ast.LoxValue {
@@ -56,13 +56,23 @@ func (e *Environment) Assign(name lexer.Token, value ast.LoxValue) {
 		return
 	}
 
-	panic(NewUndefinedVariable(name.Line, name.Lexeme))
+	panic(NewUndefinedVariable(name.Span, name.Lexeme))
 }
 
 func (e *Environment) AssignAt(distance int, name lexer.Token, value ast.LoxValue) {
 	e.ancestor(distance).symbols[name.Lexeme] = value
 }
 
+// root returns the outermost environment, the globals of the script or module where e was created
+func (e *Environment) root() *Environment {
+	env := e
+	for env.enclosing != nil {
+		env = env.enclosing
+	}
+
+	return env
+}
+
 func (e *Environment) ancestor(distance int) *Environment {
 	env := e
 	for i := 0; i < distance; i++ {
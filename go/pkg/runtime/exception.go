@@ -0,0 +1,99 @@
+package runtime
+
+import (
+	"reflect"
+
+	"github.com/fpotier/lox/go/pkg/ast"
+	"github.com/fpotier/lox/go/pkg/lexer"
+	"github.com/fpotier/lox/go/pkg/loxerror"
+	"github.com/fpotier/lox/go/pkg/parser"
+)
+
+// Prelude is run by the backends before the scripts. The built-in runtime errors are caught as instances of the
+// RuntimeError subclass named after them (RuntimeError itself for the errors without subclass), kind is the name of the
+// error class and line is where it was raised.
+const Prelude = `
+class Error {
+  init(message) {
+    this.message = message;
+    this.kind = nil;
+    this.line = nil;
+  }
+}
+
+class RuntimeError < Error {}
+class UndefinedVariable < RuntimeError {}
+class InvalidStringConversion < RuntimeError {}
+class UndefinedProperty < RuntimeError {}
+class InvalidInheritance < RuntimeError {}
+class UnsupportedBinaryOperation < RuntimeError {}
+class UnsupportedUnaryOperation < RuntimeError {}
+class InvalidSetGet < RuntimeError {}
+class NotCallable < RuntimeError {}
+class BadArity < RuntimeError {}
+class StackOverflow < RuntimeError {}
+class NativeFunctionError < RuntimeError {}
+class InvalidHostProperty < RuntimeError {}
+class NotIndexable < RuntimeError {}
+class NotIterable < RuntimeError {}
+class InvalidIterator < RuntimeError {}
+class InvalidIndex < RuntimeError {}
+class IndexOutOfRange < RuntimeError {}
+class InvalidSliceBounds < RuntimeError {}
+class EmptyList < RuntimeError {}
+class UnhashableKey < RuntimeError {}
+class UndefinedKey < RuntimeError {}
+class ModuleNotFound < RuntimeError {}
+class CircularImport < RuntimeError {}
+class UndefinedExport < RuntimeError {}
+class ImmutableModule < RuntimeError {}
+`
+
+// PreludeStatements parses the prelude
+func PreludeStatements() []ast.Statement {
+	errorFormatter := loxerror.NewJSONErrorFormatter()
+	statements := parser.NewParser(errorFormatter, lexer.NewLexer(errorFormatter, Prelude).Tokens()).Parse()
+	if errorFormatter.HasErrors() {
+		panic("invalid prelude")
+	}
+
+	return statements
+}
+
+// Throw is raised by a throw statement, it carries the thrown value to the catch clause
+type Throw struct {
+	span  loxerror.Span
+	value ast.LoxValue
+	// description is the thrown error reported when it's not caught
+	description string
+}
+
+func NewThrow(span loxerror.Span, value ast.LoxValue, description string) *Throw {
+	return &Throw{span: span, value: value, description: description}
+}
+
+func (e Throw) Line() int           { return e.span.Start.Line }
+func (e Throw) Span() loxerror.Span { return e.span }
+func (e Throw) Kind() string        { return "RuntimeError" }
+func (e Throw) Message() string     { return "Uncaught " + e.description }
+func (e Throw) Value() ast.LoxValue { return e.value }
+
+// DescribeError returns how an uncaught instance of Error is reported
+func DescribeError(kind ast.LoxValue, message ast.LoxValue) string {
+	return kind.String() + ": " + message.String()
+}
+
+// IsCatchable tells if an error can be caught by a try statement, the interruptions always stop the script
+func IsCatchable(err loxerror.LoxError) bool {
+	switch loxerror.Unwrap(err).(type) {
+	case *ExecutionInterrupted, *StepBudgetExceeded:
+		return false
+	default:
+		return true
+	}
+}
+
+// ErrorKind returns the kind of a built-in error caught by a script, it's the name of its type
+func ErrorKind(err loxerror.LoxError) string {
+	return reflect.Indirect(reflect.ValueOf(loxerror.Unwrap(err))).Type().Name()
+}
@@ -0,0 +1,112 @@
+package runtime
+
+import (
+	"fmt"
+	"reflect"
+
+	"github.com/fpotier/lox/go/pkg/ast"
+	"github.com/fpotier/lox/go/pkg/lexer"
+	"github.com/fpotier/lox/go/pkg/loxerror"
+)
+
+// HostObject gives the scripts access to a Go value: the exported fields and methods of a struct
+// behave like the fields and methods of an instance
+type HostObject struct {
+	value reflect.Value
+}
+
+func NewHostObject(value reflect.Value) *HostObject {
+	return &HostObject{value: value}
+}
+
+func (h *HostObject) Kind() ast.Kind { return ast.HostObject }
+func (h *HostObject) IsTruthy() bool { return true }
+
+// String calls the String method of a fmt.Stringer, its panic is raised as a runtime error
+func (h *HostObject) String() string {
+	if stringer, ok := h.value.Interface().(fmt.Stringer); ok {
+		defer func() {
+			if r := recover(); r != nil {
+				panic(NewNativeFunctionError(loxerror.Span{}, "String", fmt.Sprintf("panic: %v", r)))
+			}
+		}()
+		return stringer.String()
+	}
+
+	return "<" + h.value.Type().String() + ">"
+}
+
+func (h *HostObject) Equals(v ast.LoxValue) bool {
+	other, ok := v.(*HostObject)
+	if !ok || h.value.Type() != other.value.Type() || !h.value.Type().Comparable() {
+		return false
+	}
+
+	return h.value.Equal(other.value)
+}
+
+// Value returns the wrapped Go value
+func (h *HostObject) Value() any { return h.value.Interface() }
+
+func (h *HostObject) Get(name lexer.Token) ast.LoxValue {
+	if field, ok := h.field(name.Lexeme); ok {
+		value, err := toLoxValue(name.Lexeme, field)
+		if err != nil {
+			panic(NewInvalidHostProperty(name.Span, name.Lexeme, err.Error()))
+		}
+		return value
+	}
+
+	if method := h.value.MethodByName(name.Lexeme); method.IsValid() {
+		function, err := newHostFunction(name.Lexeme, method)
+		if err != nil {
+			panic(NewInvalidHostProperty(name.Span, name.Lexeme, err.Error()))
+		}
+		return function
+	}
+
+	panic(NewUndefinedProperty(name.Span, name.Lexeme, h.typeName()))
+}
+
+func (h *HostObject) Set(name lexer.Token, value ast.LoxValue) {
+	field, ok := h.field(name.Lexeme)
+	if !ok {
+		panic(NewUndefinedProperty(name.Span, name.Lexeme, h.typeName()))
+	}
+	if !field.CanSet() {
+		panic(NewInvalidHostProperty(name.Span, name.Lexeme, "the field is read-only"))
+	}
+
+	converted, err := FromLoxValue(value, field.Type())
+	if err != nil {
+		panic(NewInvalidHostProperty(name.Span, name.Lexeme, err.Error()))
+	}
+	field.Set(converted)
+}
+
+func (h *HostObject) typeName() string {
+	return reflect.Indirect(h.value).Type().String()
+}
+
+// field returns the exported struct field with the given name
+func (h *HostObject) field(name string) (reflect.Value, bool) {
+	object := h.value
+	for object.Kind() == reflect.Pointer || object.Kind() == reflect.Interface {
+		object = object.Elem()
+	}
+	if object.Kind() != reflect.Struct {
+		return reflect.Value{}, false
+	}
+
+	structField, ok := object.Type().FieldByName(name)
+	if !ok || !structField.IsExported() {
+		return reflect.Value{}, false
+	}
+
+	field, err := object.FieldByIndexErr(structField.Index)
+	if err != nil {
+		return reflect.Value{}, false
+	}
+
+	return field, true
+}
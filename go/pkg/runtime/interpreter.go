@@ -3,10 +3,14 @@
 package runtime
 
 import (
+	"context"
 	"fmt"
 	"io"
+	"os"
+	"sort"
 
 	"github.com/fpotier/lox/go/pkg/ast"
+	"github.com/fpotier/lox/go/pkg/checker"
 	"github.com/fpotier/lox/go/pkg/lexer"
 	"github.com/fpotier/lox/go/pkg/loxerror"
 )
@@ -17,43 +21,120 @@ type Interpreter struct {
 	HadRuntimeError bool
 	ErrorFormatter  loxerror.ErrorFormatter
 	OutputStream    io.Writer
-	hasReturned     bool
-	frameNumber     int
-	globals         *Environment
-	environment     *Environment
-	locals          map[ast.Expression]int
+	InputStream     io.Reader
+	// MaxCallDepth is the number of nested calls after which a StackOverflow is raised, 0 means no limit
+	MaxCallDepth int
+	// StepBudget is the number of statements and expressions a run may evaluate, 0 means no limit
+	StepBudget int
+	// Debugger is called before each statement, it's nil when the program isn't debugged
+	Debugger    DebugHook
+	steps       int
+	context     context.Context
+	hasReturned bool
+	// loopJump is the break or continue statement being executed, the statements are skipped until its loop
+	loopJump  *loopJump
+	callStack []callFrame
+	// globals are the global variables of the script or module being executed
+	globals     *Environment
+	environment *Environment
+	locals      map[ast.Expression]int
+	// builtins are the native functions and prelude classes, they are defined in the globals of every module
+	builtins map[string]ast.LoxValue
+	modules  *Modules
+	// errorClass and runtimeErrorClass are the classes of the prelude, the scripts may shadow their names
+	errorClass        *LoxClass
+	runtimeErrorClass *LoxClass
+	// errorKinds are the subclasses of RuntimeError of the prelude by name, the built-in errors are their instances
+	errorKinds map[string]*LoxClass
+}
+
+// loopJump is a break or a continue statement leaving a loop body
+type loopJump struct {
+	breaking bool
+	// label is the label of the targeted loop, empty for the innermost loop
+	label string
 }
 
 func NewInterpreter(outputStream io.Writer, errorFormatter loxerror.ErrorFormatter) *Interpreter {
+	builtins := make(map[string]ast.LoxValue)
 	i := Interpreter{
-		Value:           ast.NewNilValue(),
-		HadRuntimeError: false,
-		ErrorFormatter:  errorFormatter,
-		OutputStream:    outputStream,
-		hasReturned:     false,
-		frameNumber:     0,
-		globals:         NewEnvironment(),
-		environment:     nil,
-		locals:          make(map[ast.Expression]int),
+		Value:             ast.NewNilValue(),
+		HadRuntimeError:   false,
+		ErrorFormatter:    errorFormatter,
+		OutputStream:      outputStream,
+		InputStream:       os.Stdin,
+		MaxCallDepth:      ast.Limits.MaxCallDepth,
+		StepBudget:        0,
+		Debugger:          nil,
+		steps:             0,
+		context:           context.Background(),
+		hasReturned:       false,
+		loopJump:          nil,
+		callStack:         make([]callFrame, 0),
+		globals:           NewEnvironment(),
+		environment:       nil,
+		locals:            make(map[ast.Expression]int),
+		builtins:          builtins,
+		modules:           NewModules(builtins),
+		errorClass:        nil,
+		runtimeErrorClass: nil,
+		errorKinds:        make(map[string]*LoxClass),
 	}
 	i.environment = i.globals
 
-	for _, nativeFunction := range builtinNativeFunctions {
+	for _, nativeFunction := range BuiltinNativeFunctions {
 		i.globals.Define(nativeFunction.name, nativeFunction)
 	}
+	i.loadPrelude()
+	for name, value := range i.globals.symbols {
+		i.builtins[name] = value
+	}
 
 	return &i
 }
 
+// loadPrelude runs the prelude and keeps its error classes
+func (i *Interpreter) loadPrelude() {
+	statements := PreludeStatements()
+	NewResolver(i.ErrorFormatter, i).ResolveProgram(statements)
+	i.Eval(statements)
+
+	errorClass, _ := i.GetGlobal("Error")
+	i.errorClass = errorClass.(*LoxClass)
+	runtimeErrorClass, _ := i.GetGlobal("RuntimeError")
+	i.runtimeErrorClass = runtimeErrorClass.(*LoxClass)
+	for name, value := range i.globals.symbols {
+		if class, ok := value.(*LoxClass); ok && class.superclass == i.runtimeErrorClass {
+			i.errorKinds[name] = class
+		}
+	}
+}
+
 func (i *Interpreter) Eval(statements []ast.Statement) {
+	i.EvalContext(context.Background(), statements)
+}
+
+// EvalContext evaluates the statements until they complete, the context is done or the step budget is exhausted
+func (i *Interpreter) EvalContext(ctx context.Context, statements []ast.Statement) {
+	i.context = ctx
+	i.steps = 0
+	defer func() { i.context = context.Background() }()
+
 	// This allows us to emulate a try/catch mechanism to exit the visitor as soon as possible
 	// without changing the Visit...() methods to return an error and propagate manually these errors
 	defer func() {
 		if r := recover(); r != nil {
 			if err, ok := r.(loxerror.LoxError); ok {
 				i.HadRuntimeError = true
-				// TODO: better runtime error messages
-				i.ErrorFormatter.PushError(err)
+				// The frames of the calls interrupted by the error were not popped,
+				// an error rethrown after a finally clause already has the stack trace of where it was raised
+				if _, ok := err.(loxerror.TracedError); !ok {
+					err = loxerror.WithStackTrace(err, i.stackTrace())
+				}
+				for _, err := range loxerror.Split(err) {
+					i.ErrorFormatter.PushError(err)
+				}
+				i.callStack = i.callStack[:0]
 				return
 			}
 			panic(r)
@@ -65,6 +146,46 @@ func (i *Interpreter) Eval(statements []ast.Statement) {
 	}
 }
 
+// DefineNative registers a Go function as a global Lox function
+func (i *Interpreter) DefineNative(name string, arity int, code CallableCode) {
+	nativeFunction := NewNativeFunction(name, arity, code)
+	i.builtins[name] = nativeFunction
+	i.globals.Define(name, nativeFunction)
+}
+
+// SetGlobal defines a global variable or overwrites its value
+func (i *Interpreter) SetGlobal(name string, value ast.LoxValue) {
+	i.globals.Define(name, value)
+}
+
+// Expose converts a Go value with ToLoxValue and defines it as a global variable
+func (i *Interpreter) Expose(name string, value any) error {
+	loxValue, err := ToNamedLoxValue(name, value)
+	if err != nil {
+		return err
+	}
+	i.globals.Define(name, loxValue)
+
+	return nil
+}
+
+// GetGlobal returns the value of a global variable
+func (i *Interpreter) GetGlobal(name string) (ast.LoxValue, bool) {
+	value, ok := i.globals.symbols[name]
+	return value, ok
+}
+
+// GlobalNames returns the sorted names of the global variables, the native functions included
+func (i *Interpreter) GlobalNames() []string {
+	names := make([]string, 0, len(i.globals.symbols))
+	for name := range i.globals.symbols {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	return names
+}
+
 func (i *Interpreter) VisitAssignmentExpression(assignmentExpression *ast.AssignmentExpression) {
 	value := i.evaluate(assignmentExpression.Value)
 	if distance, ok := i.locals[assignmentExpression]; ok {
@@ -79,6 +200,18 @@ func (i *Interpreter) VisitBinaryExpression(binaryExpression *ast.BinaryExpressi
 	lhs := i.evaluate(binaryExpression.LHS)
 	rhs := i.evaluate(binaryExpression.RHS)
 
+	// Only the instances overload the operators, the other operands don't look up a method
+	if _, ok := lhs.(*LoxInstance); ok {
+		operator := binaryExpression.Operator
+		if result, ok := i.callSpecialMethod(lhs, BinaryOperatorMethods[operator.Type], []ast.LoxValue{rhs}, operator); ok {
+			if operator.Type == lexer.BangEqual {
+				result = ast.NewBooleanValue(!result.IsTruthy())
+			}
+			i.Value = result
+			return
+		}
+	}
+
 	switch binaryExpression.Operator.Type {
 	case lexer.Plus:
 		switch {
@@ -87,7 +220,7 @@ func (i *Interpreter) VisitBinaryExpression(binaryExpression *ast.BinaryExpressi
 		case lhs.Kind() == ast.String && rhs.Kind() == ast.String:
 			i.Value = ast.NewStringValue(lhs.(*ast.StringValue).Value + rhs.(*ast.StringValue).Value)
 		default:
-			panic(NewUnsupportedBinaryOperation(binaryExpression.Operator.Line,
+			panic(NewUnsupportedBinaryOperation(binaryExpression.Operator.Span,
 				binaryExpression.Operator.Lexeme,
 				ast.KindString[lhs.Kind()],
 				ast.KindString[rhs.Kind()]))
@@ -129,31 +262,74 @@ func (i *Interpreter) VisitCallExpression(callExpression *ast.CallExpression) {
 	}
 
 	if function, ok := callee.(LoxCallable); ok {
-		if function.Arity() != len(arguments) {
-			panic(NewBadArity(callExpression.Position.Line, function.Name(), function.Arity(), len(arguments)))
-		}
-		i.frameNumber++
-		i.Value = function.Call(i, arguments)
-		i.frameNumber--
+		i.Value = i.call(function, arguments, callExpression.Position)
 	} else {
-		panic(NewNotCallable(callExpression.Position.Line))
+		panic(NewNotCallable(callExpression.Position.Span))
 	}
 }
 
+func (i *Interpreter) VisitFunctionExpression(functionExpression *ast.FunctionExpression) {
+	i.Value = NewLoxFunction(functionExpression.Function, i.environment, false)
+}
+
 func (i *Interpreter) VisitGetExpression(getExpression *ast.GetExpression) {
 	object := i.evaluate(getExpression.Object)
-	if object, ok := object.(*LoxInstance); ok {
-		i.Value = object.Get(getExpression.Name)
+	if object, ok := object.(ObjectValue); ok {
+		i.Value = i.callGetter(object.Get(getExpression.Name), getExpression.Name)
 		return
 	}
 
-	panic(NewInvalidSetGet(getExpression.Name.Line))
+	panic(NewInvalidSetGet(getExpression.Name.Span))
 }
 
 func (i *Interpreter) VisitGroupingExpression(groupingExpression *ast.GroupingExpression) {
 	i.Value = i.evaluate(groupingExpression.Expr)
 }
 
+func (i *Interpreter) VisitIndexGetExpression(indexGetExpression *ast.IndexGetExpression) {
+	object := i.evaluate(indexGetExpression.Object)
+	index := i.evaluate(indexGetExpression.Index)
+	if object, ok := object.(IndexableValue); ok {
+		i.Value = object.GetIndex(index, indexGetExpression.Bracket.Span)
+		return
+	}
+
+	panic(NewNotIndexable(indexGetExpression.Bracket.Span, ast.KindString[object.Kind()]))
+}
+
+func (i *Interpreter) VisitIndexSetExpression(indexSetExpression *ast.IndexSetExpression) {
+	object := i.evaluate(indexSetExpression.Object)
+	index := i.evaluate(indexSetExpression.Index)
+	if object, ok := object.(IndexableValue); ok {
+		value := i.evaluate(indexSetExpression.Value)
+		object.SetIndex(index, value, indexSetExpression.Bracket.Span)
+		i.Value = value
+
+		return
+	}
+
+	panic(NewNotIndexable(indexSetExpression.Bracket.Span, ast.KindString[object.Kind()]))
+}
+
+func (i *Interpreter) VisitListExpression(listExpression *ast.ListExpression) {
+	elements := make([]ast.LoxValue, 0, len(listExpression.Elements))
+	for _, element := range listExpression.Elements {
+		elements = append(elements, i.evaluate(element))
+	}
+
+	i.Value = NewLoxList(elements)
+}
+
+func (i *Interpreter) VisitMapExpression(mapExpression *ast.MapExpression) {
+	loxMap := NewLoxMap()
+	for index, keyExpression := range mapExpression.Keys {
+		key := i.evaluate(keyExpression)
+		loxMap.SetIndex(key, i.evaluate(mapExpression.Values[index]), mapExpression.Brace.Span)
+	}
+
+	i.Value = loxMap
+}
+
 func (i *Interpreter) VisitLiteralExpression(literalExpression *ast.LiteralExpression) {
 	i.Value = literalExpression.LoxValue()
 }
@@ -173,7 +349,7 @@ func (i *Interpreter) VisitLogicalExpression(logicalExpression *ast.LogicalExpre
 func (i *Interpreter) VisitSetExpression(setExpression *ast.SetExpression) {
 	object := i.evaluate(setExpression.Object)
 
-	if object, ok := object.(*LoxInstance); ok {
+	if object, ok := object.(ObjectValue); ok {
 		value := i.evaluate(setExpression.Value)
 		object.Set(setExpression.Name, value)
 		i.Value = value
@@ -181,7 +357,12 @@ func (i *Interpreter) VisitSetExpression(setExpression *ast.SetExpression) {
 		return
 	}
 
-	panic(NewInvalidSetGet(setExpression.Name.Line))
+	panic(NewInvalidSetGet(setExpression.Name.Span))
+}
+
+func (i *Interpreter) VisitStringifyExpression(stringifyExpression *ast.StringifyExpression) {
+	value := i.evaluate(stringifyExpression.Value)
+	i.Value = ast.NewStringValue(i.stringify(value, stringifyExpression.Position))
 }
 
 func (i *Interpreter) VisitSuperExpression(superExpression *ast.SuperExpression) {
@@ -190,10 +371,10 @@ func (i *Interpreter) VisitSuperExpression(superExpression *ast.SuperExpression)
 	this := i.environment.GetAt(distance-1, "this").(*LoxInstance)
 	method, ok := superclass.findMethod(superExpression.Method.Lexeme)
 	if !ok {
-		panic(NewUndefinedProperty(superExpression.Method.Line, superExpression.Keyword.Lexeme, superclass.String()))
+		panic(NewUndefinedProperty(superExpression.Method.Span, superExpression.Keyword.Lexeme, superclass.String()))
 	}
 
-	i.Value = method.Bind(this)
+	i.Value = i.callGetter(method.Bind(this), superExpression.Method)
 }
 
 func (i *Interpreter) VisitThisExpression(thisExpression *ast.ThisExpression) {
@@ -207,6 +388,10 @@ func (i *Interpreter) VisitUnaryExpression(unaryExpression *ast.UnaryExpression)
 	case lexer.Bang:
 		i.Value = ast.NewBooleanValue(!rhs.IsTruthy())
 	case lexer.Dash:
+		if result, ok := i.callSpecialMethod(rhs, NegateMethod, []ast.LoxValue{}, unaryExpression.Operator); ok {
+			i.Value = result
+			return
+		}
 		assertNumberOperand(unaryExpression.Operator, rhs)
 		i.Value = ast.NewNumberValue(-rhs.(*ast.NumberValue).Value)
 	}
@@ -220,6 +405,10 @@ func (i *Interpreter) VisitBlockStatement(blockStatement *ast.BlockStatement) {
 	i.executeBlock(blockStatement.Statements, NewSubEnvironment(i.environment))
 }
 
+func (i *Interpreter) VisitBreakStatement(breakStatement *ast.BreakStatement) {
+	i.loopJump = newLoopJump(true, breakStatement.Label)
+}
+
 func (i *Interpreter) VisitClassStatement(classStatement *ast.ClassStatement) {
 	var superclass *LoxClass
 	if classStatement.Superclass != nil {
@@ -227,7 +416,7 @@ func (i *Interpreter) VisitClassStatement(classStatement *ast.ClassStatement) {
 		var ok bool
 		superclass, ok = result.(*LoxClass)
 		if !ok {
-			panic(NewInvalidInheritance(classStatement.Superclass.Name.Line, "Superclass must be a class"))
+			panic(NewInvalidInheritance(classStatement.Superclass.Name.Span, "Superclass must be a class"))
 		}
 	}
 
@@ -244,20 +433,94 @@ func (i *Interpreter) VisitClassStatement(classStatement *ast.ClassStatement) {
 		function.setClassName(classStatement.Name.Lexeme)
 		methods[method.Name.Lexeme] = function
 	}
+	classMethods := make(map[string]*LoxFunction)
+	for _, method := range classStatement.ClassMethods {
+		function := NewLoxFunction(method, i.environment, false)
+		function.setClassName(classStatement.Name.Lexeme)
+		classMethods[method.Name.Lexeme] = function
+	}
 
-	class := NewLoxClass(classStatement.Name.Lexeme, superclass, methods)
+	class := NewLoxClass(classStatement.Name.Lexeme, superclass, methods, classMethods)
 
 	if superclass != nil {
 		i.environment = i.environment.enclosing
 	}
 
 	i.environment.Assign(classStatement.Name, class)
+
+	// The initializers of the class fields may use the class
+	for _, field := range classStatement.ClassFields {
+		var value ast.LoxValue = ast.NewNilValue()
+		if field.Initializer != nil {
+			value = i.evaluate(field.Initializer)
+		}
+		class.defineField(field.Name.Lexeme, value)
+	}
+}
+
+func (i *Interpreter) VisitContinueStatement(continueStatement *ast.ContinueStatement) {
+	i.loopJump = newLoopJump(false, continueStatement.Label)
 }
 
 func (i *Interpreter) VisitExpressionStatement(expressionStatement *ast.ExpressionStatement) {
 	i.evaluate(expressionStatement.Expression)
 }
 
+func (i *Interpreter) VisitForInStatement(forInStatement *ast.ForInStatement) {
+	iterator := i.iterator(i.evaluate(forInStatement.Iterable), forInStatement.Keyword)
+
+	// The loop variable lives in a single environment, each iteration assigns it
+	previousEnv := i.environment
+	i.environment = NewSubEnvironment(previousEnv)
+	defer func() { i.environment = previousEnv }()
+	i.environment.Define(forInStatement.Name.Lexeme, ast.NewNilValue())
+
+	for {
+		value, ok := iterator.Next()
+		if !ok {
+			return
+		}
+		i.environment.Define(forInStatement.Name.Lexeme, value)
+
+		i.checkLimits(forInStatement.Keyword)
+		i.execute(forInStatement.Body)
+		if i.leavesLoop(forInStatement.Label) {
+			return
+		}
+	}
+}
+
+// iterator returns the iterator of a for-in loop over a value, see IterMethod for the iteration protocol
+func (i *Interpreter) iterator(iterable ast.LoxValue, keyword lexer.Token) *LoxIterator {
+	if iterator, ok := BuiltinIterator(iterable); ok {
+		return iterator
+	}
+	result, ok := i.callSpecialMethod(iterable, IterMethod, []ast.LoxValue{}, keyword)
+	if !ok {
+		panic(NewNotIterable(keyword.Span, ast.KindString[iterable.Kind()]))
+	}
+	if iterator, ok := BuiltinIterator(result); ok {
+		return iterator
+	}
+	object, ok := result.(ObjectValue)
+	if !ok {
+		panic(NewInvalidIterator(keyword.Span, ast.KindString[result.Kind()]))
+	}
+
+	done := *lexer.NewToken(lexer.Identifier, DoneProperty, nil, keyword.Span)
+	next := *lexer.NewToken(lexer.Identifier, NextMethod, nil, keyword.Span)
+	return NewLoxIterator(func() (ast.LoxValue, bool) {
+		if i.callGetter(object.Get(done), done).IsTruthy() {
+			return nil, false
+		}
+		method, ok := object.Get(next).(LoxCallable)
+		if !ok {
+			panic(NewNotCallable(keyword.Span))
+		}
+		return i.call(method, []ast.LoxValue{}, keyword), true
+	})
+}
+
 func (i *Interpreter) VisitFunctionStatement(functionStatement *ast.FunctionStatement) {
 	function := NewLoxFunction(functionStatement, i.environment, false)
 	i.environment.Define(functionStatement.Name.Lexeme, function)
@@ -271,18 +534,69 @@ func (i *Interpreter) VisitIfStatement(ifStatment *ast.IfStatement) {
 	}
 }
 
+func (i *Interpreter) VisitImportStatement(importStatement *ast.ImportStatement) {
+	module := i.modules.Import(
+		importStatement.ModulePath(),
+		importStatement.Path.Span,
+		i.ErrorFormatter,
+		i.evaluateModule,
+	)
+	if importStatement.Alias != nil {
+		i.environment.Define(importStatement.Alias.Lexeme, module)
+		return
+	}
+
+	for _, name := range importStatement.Names {
+		i.environment.Define(name.Lexeme, module.Get(name))
+	}
+}
+
 func (i *Interpreter) VisitPrintStatement(printStatement *ast.PrintStatement) {
 	value := i.evaluate(printStatement.Expression)
-	fmt.Fprintf(i.OutputStream, "%s\n", value.String())
+	fmt.Fprintf(i.OutputStream, "%s\n", i.stringify(value, printStatement.Keyword))
 }
 
 func (i *Interpreter) VisitReturnStatement(returnStatement *ast.ReturnStatement) {
-	i.hasReturned = true
+	// The value is evaluated first, an error raised by it mustn't leave a pending return
 	if returnStatement.Value != nil {
 		i.Value = i.evaluate(returnStatement.Value)
 	} else {
 		i.Value = ast.NewNilValue()
 	}
+	i.hasReturned = true
+}
+
+func (i *Interpreter) VisitThrowStatement(throwStatement *ast.ThrowStatement) {
+	value := i.evaluate(throwStatement.Value)
+	description := value.String()
+	if instance, ok := value.(*LoxInstance); ok && instance.class.isSubclassOf(i.errorClass) {
+		if isNil(instance.fields["kind"]) {
+			instance.fields["kind"] = ast.NewStringValue(instance.class.name)
+		}
+		if isNil(instance.fields["line"]) {
+			instance.fields["line"] = ast.NewNumberValue(float64(throwStatement.Keyword.Line))
+		}
+		description = DescribeError(instance.fields["kind"], fieldOrNil(instance, "message"))
+	}
+
+	panic(NewThrow(throwStatement.Keyword.Span, value, description))
+}
+
+func (i *Interpreter) VisitTryStatement(tryStatement *ast.TryStatement) {
+	err := i.tryBlock(tryStatement.Body, NewSubEnvironment(i.environment))
+	if err != nil && tryStatement.Catch != nil {
+		environment := NewSubEnvironment(i.environment)
+		environment.Define(tryStatement.Catch.Name.Lexeme, i.caughtValue(err))
+		if tryStatement.Finally == nil {
+			i.executeBlock(tryStatement.Catch.Body, environment)
+			return
+		}
+		err = i.tryBlock(tryStatement.Catch.Body, environment)
+	}
+
+	if tryStatement.Finally != nil {
+		i.executeFinally(tryStatement.Finally.Statements, err)
+	}
 }
 
 func (i *Interpreter) VisitVariableStatement(variableStatement *ast.VariableStatement) {
@@ -295,25 +609,155 @@ func (i *Interpreter) VisitVariableStatement(variableStatement *ast.VariableStat
 }
 
 func (i *Interpreter) VisitWhileStatement(whileStatement *ast.WhileStatement) {
-	for i.evaluate(whileStatement.Condition).IsTruthy() && !(i.frameNumber > 0 && i.hasReturned) {
+	for i.evaluate(whileStatement.Condition).IsTruthy() {
+		i.checkLimits(whileStatement.Keyword)
 		i.execute(whileStatement.Body)
+		if i.leavesLoop(whileStatement.Label) {
+			return
+		}
+
+		if whileStatement.Increment != nil {
+			i.evaluate(whileStatement.Increment)
+		}
 	}
 }
 
+// leavesLoop tells if the loop stops after its body ran, it consumes the break or continue targeting it
+func (i *Interpreter) leavesLoop(label *lexer.Token) bool {
+	if i.isReturning() {
+		return true
+	}
+	if i.loopJump == nil {
+		return false
+	}
+
+	// A jump to an enclosing loop leaves this one
+	if i.loopJump.label != "" && (label == nil || label.Lexeme != i.loopJump.label) {
+		return true
+	}
+	breaking := i.loopJump.breaking
+	i.loopJump = nil
+
+	return breaking
+}
+
 func (i *Interpreter) executeBlock(statements []ast.Statement, subEnvironment *Environment) {
 	previousEnv := i.environment
 	i.environment = subEnvironment
 	defer func() { i.environment = previousEnv }()
-	for index := 0; index < len(statements) && !(i.frameNumber > 0 && i.hasReturned); index++ {
+	for index := 0; index < len(statements) && !i.isReturning() && i.loopJump == nil; index++ {
 		i.execute(statements[index])
 	}
 }
 
+// evaluateModule executes the statements of a module in its own globals and returns them
+func (i *Interpreter) evaluateModule(statements []ast.Statement, errorFormatter loxerror.ErrorFormatter) map[string]ast.LoxValue {
+	NewResolver(errorFormatter, i).ResolveProgram(statements)
+	checker.NewChecker(errorFormatter).CheckProgram(statements)
+	if errorFormatter.HasErrors() {
+		return nil
+	}
+
+	moduleGlobals := NewEnvironment()
+	for name, value := range i.builtins {
+		moduleGlobals.Define(name, value)
+	}
+
+	previousGlobals, previousEnv := i.globals, i.environment
+	i.globals, i.environment = moduleGlobals, moduleGlobals
+	defer func() { i.globals, i.environment = previousGlobals, previousEnv }()
+	for _, statement := range statements {
+		i.execute(statement)
+	}
+
+	return moduleGlobals.symbols
+}
+
+// tryBlock executes the statements and returns the catchable error they raised, with the stack trace where it was raised
+func (i *Interpreter) tryBlock(statements []ast.Statement, subEnvironment *Environment) (caught loxerror.LoxError) {
+	callDepth := len(i.callStack)
+	defer func() {
+		if r := recover(); r != nil {
+			err, ok := r.(loxerror.LoxError)
+			if !ok || !IsCatchable(err) {
+				panic(r)
+			}
+			if _, ok := err.(loxerror.TracedError); !ok {
+				err = loxerror.WithStackTrace(err, i.stackTrace())
+			}
+			i.callStack = i.callStack[:callDepth]
+			caught = err
+		}
+	}()
+
+	i.executeBlock(statements, subEnvironment)
+
+	return nil
+}
+
+// executeFinally executes a finally clause then resumes the pending return, jump or error.
+// A return, break or continue statement in the clause replaces them.
+func (i *Interpreter) executeFinally(statements []ast.Statement, err loxerror.LoxError) {
+	hasReturned, value, jump := i.hasReturned, i.Value, i.loopJump
+	i.hasReturned, i.loopJump = false, nil
+
+	i.executeBlock(statements, NewSubEnvironment(i.environment))
+	if i.isReturning() || i.loopJump != nil {
+		return
+	}
+
+	i.hasReturned, i.Value, i.loopJump = hasReturned, value, jump
+	if err != nil {
+		panic(err)
+	}
+}
+
+// caughtValue returns the value bound by a catch clause, a built-in error becomes an instance of its prelude class
+func (i *Interpreter) caughtValue(err loxerror.LoxError) ast.LoxValue {
+	err = loxerror.Unwrap(err)
+	if thrown, ok := err.(*Throw); ok {
+		return thrown.Value()
+	}
+
+	kind := ErrorKind(err)
+	class, ok := i.errorKinds[kind]
+	if !ok {
+		class = i.runtimeErrorClass
+	}
+	instance := NewLoxInstance(class)
+	instance.fields["message"] = ast.NewStringValue(err.Message())
+	instance.fields["kind"] = ast.NewStringValue(kind)
+	instance.fields["line"] = ast.NewNumberValue(float64(err.Line()))
+
+	return instance
+}
+
+// isReturning tells if a return statement is leaving the function being executed
+func (i *Interpreter) isReturning() bool {
+	return len(i.callStack) > 0 && i.hasReturned
+}
+
+func newLoopJump(breaking bool, label *lexer.Token) *loopJump {
+	jump := loopJump{breaking: breaking, label: ""}
+	if label != nil {
+		jump.label = label.Lexeme
+	}
+
+	return &jump
+}
+
 func (i *Interpreter) execute(statement ast.Statement) {
+	i.steps++
+	if i.Debugger != nil {
+		if position := ast.StatementPosition(statement); position != nil {
+			i.Debugger.BeforeStatement(i, *position)
+		}
+	}
 	statement.Accept(i)
 }
 
 func (i *Interpreter) evaluate(expression ast.Expression) ast.LoxValue {
+	i.steps++
 	oldValue := i.Value
 	expression.Accept(i)
 	evalValue := i.Value
@@ -322,7 +766,128 @@ func (i *Interpreter) evaluate(expression ast.Expression) ast.LoxValue {
 	return evalValue
 }
 
-func (i *Interpreter) resolve(e ast.Expression, depth int) {
+// CheckLimits stops the execution of a backend if its context is done or if it ran more steps than its budget, the
+// budget is unlimited if it's 0. The errors are reported at the span.
+func CheckLimits(ctx context.Context, steps int, stepBudget int, span loxerror.Span) {
+	if stepBudget > 0 && steps > stepBudget {
+		panic(NewStepBudgetExceeded(span, stepBudget))
+	}
+
+	select {
+	case <-ctx.Done():
+		panic(NewExecutionInterrupted(span, context.Cause(ctx).Error()))
+	default:
+	}
+}
+
+// checkLimits is called on each iteration of the while, for and for-in loops and on each call, since only they can
+// make a program run forever
+func (i *Interpreter) checkLimits(position lexer.Token) {
+	CheckLimits(i.context, i.steps, i.StepBudget, position.Span)
+}
+
+// call invokes the function in a new call frame, callSite is the token reported by the errors
+func (i *Interpreter) call(function LoxCallable, arguments []ast.LoxValue, callSite lexer.Token) ast.LoxValue {
+	if function.Arity() != len(arguments) {
+		panic(NewBadArity(callSite.Span, function.Name(), function.Arity(), len(arguments)))
+	}
+	if i.MaxCallDepth > 0 && len(i.callStack) >= i.MaxCallDepth {
+		panic(NewStackOverflow(callSite.Span))
+	}
+	i.checkLimits(callSite)
+
+	i.callStack = append(i.callStack, callFrame{
+		function:    callFrameName(function),
+		callSite:    callSite,
+		environment: i.environment,
+	})
+	value := function.Call(i, arguments)
+	i.callStack = i.callStack[:len(i.callStack)-1]
+
+	return value
+}
+
+// callGetter returns the value of a property, a getter is called to compute it
+func (i *Interpreter) callGetter(property ast.LoxValue, name lexer.Token) ast.LoxValue {
+	if method, ok := property.(*LoxFunction); ok && method.Declaration.IsGetter {
+		return i.call(method, []ast.LoxValue{}, name)
+	}
+
+	return property
+}
+
+// callSpecialMethod calls the special method overloading an operator, ok is false if the value doesn't define it
+func (i *Interpreter) callSpecialMethod(
+	value ast.LoxValue,
+	name string,
+	arguments []ast.LoxValue,
+	position lexer.Token,
+) (result ast.LoxValue, ok bool) {
+	instance, ok := value.(*LoxInstance)
+	if !ok || name == "" {
+		return nil, false
+	}
+	method, ok := instance.class.findMethod(name)
+	if !ok {
+		return nil, false
+	}
+
+	return i.call(method.Bind(instance), arguments, position), true
+}
+
+// stringify converts a value to a string, the instances defining __str__ are converted by it
+func (i *Interpreter) stringify(value ast.LoxValue, position lexer.Token) string {
+	result, ok := i.callSpecialMethod(value, StringMethod, []ast.LoxValue{}, position)
+	if !ok {
+		return value.String()
+	}
+
+	str, ok := result.(*ast.StringValue)
+	if !ok {
+		panic(NewInvalidStringConversion(position.Span, value.(*LoxInstance).class.name))
+	}
+
+	return str.Value
+}
+
+// callSite returns the position of the call in progress
+func (i *Interpreter) callSite() lexer.Token {
+	return i.callStack[len(i.callStack)-1].callSite
+}
+
+// stackTrace returns the current call stack, most recent call first.
+// Consecutive identical frames are collapsed to keep deep recursions readable.
+func (i *Interpreter) stackTrace() []loxerror.StackFrame {
+	stackTrace := make([]loxerror.StackFrame, 0)
+	for index := len(i.callStack) - 1; index >= 0; index-- {
+		frame := i.callStack[index]
+		stackTrace = loxerror.PushFrame(stackTrace, frame.function, frame.callSite.Line)
+	}
+
+	return stackTrace
+}
+
+// Call calls a Lox value from a native function
+func (i *Interpreter) Call(callee ast.LoxValue, arguments []ast.LoxValue) ast.LoxValue {
+	function, ok := callee.(LoxCallable)
+	if !ok {
+		panic(NewNotCallable(i.callSite().Span))
+	}
+
+	return i.call(function, arguments, i.callSite())
+}
+
+// Stdout returns where the print statements write
+func (i *Interpreter) Stdout() io.Writer {
+	return i.OutputStream
+}
+
+// Stdin returns where the scripts read their input
+func (i *Interpreter) Stdin() io.Reader {
+	return i.InputStream
+}
+
+func (i *Interpreter) Resolve(e ast.Expression, depth int) {
 	i.locals[e] = depth
 }
 
@@ -338,7 +903,7 @@ func assertNumberOperands(operator lexer.Token, lhs ast.LoxValue, rhs ast.LoxVal
 	if !(lhs.Kind() == ast.Number) || !(rhs.Kind() == ast.Number) {
 		panic(
 			NewUnsupportedBinaryOperation(
-				operator.Line,
+				operator.Span,
 				operator.Lexeme,
 				ast.KindString[lhs.Kind()],
 				ast.KindString[rhs.Kind()],
@@ -349,6 +914,6 @@ func assertNumberOperands(operator lexer.Token, lhs ast.LoxValue, rhs ast.LoxVal
 
 func assertNumberOperand(operator lexer.Token, rhs ast.LoxValue) {
 	if !(rhs.Kind() == ast.Number) {
-		panic(NewUnsupportedUnaryOperation(operator.Line, operator.Lexeme, ast.KindString[rhs.Kind()]))
+		panic(NewUnsupportedUnaryOperation(operator.Span, operator.Lexeme, ast.KindString[rhs.Kind()]))
 	}
 }
@@ -1,6 +1,11 @@
 package runtime
 
-import "github.com/fpotier/lox/go/pkg/ast"
+import (
+	"io"
+
+	"github.com/fpotier/lox/go/pkg/ast"
+	"github.com/fpotier/lox/go/pkg/lexer"
+)
 
 type LoxCallable interface {
 	Call(interpreter *Interpreter, arguments []ast.LoxValue) ast.LoxValue
@@ -8,4 +13,30 @@ type LoxCallable interface {
 	Name() string
 }
 
-type CallableCode func(*Interpreter, []ast.LoxValue) ast.LoxValue
+// Evaluator is the backend running a native function
+type Evaluator interface {
+	Stdout() io.Writer
+	Stdin() io.Reader
+	// Call calls a Lox value from a native function, the errors are raised at the call site of the native function
+	Call(callee ast.LoxValue, arguments []ast.LoxValue) ast.LoxValue
+}
+
+// CallableCode implements a native function, a returned error is raised as a runtime error at the call site
+type CallableCode func(Evaluator, []ast.LoxValue) (ast.LoxValue, error)
+
+// callFrame is a call in progress
+type callFrame struct {
+	function string
+	callSite lexer.Token
+	// environment is the one of the caller, the debugger shows its variables
+	environment *Environment
+}
+
+// callFrameName returns the name displayed in stack traces, methods are qualified by their class
+func callFrameName(callee LoxCallable) string {
+	if function, ok := callee.(*LoxFunction); ok {
+		return function.QualifiedName()
+	}
+
+	return callee.Name()
+}
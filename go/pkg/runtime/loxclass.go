@@ -4,19 +4,31 @@ import (
 	"fmt"
 
 	"github.com/fpotier/lox/go/pkg/ast"
+	"github.com/fpotier/lox/go/pkg/lexer"
+	"github.com/fpotier/lox/go/pkg/loxerror"
 )
 
 type LoxClass struct {
 	name       string
 	superclass *LoxClass
 	methods    map[string]*LoxFunction
+	// classMethods and fields are the properties of the class itself, they are inherited
+	classMethods map[string]*LoxFunction
+	fields       map[string]ast.LoxValue
 }
 
-func NewLoxClass(name string, superclass *LoxClass, methods map[string]*LoxFunction) *LoxClass {
+func NewLoxClass(
+	name string,
+	superclass *LoxClass,
+	methods map[string]*LoxFunction,
+	classMethods map[string]*LoxFunction,
+) *LoxClass {
 	return &LoxClass{
-		name:       name,
-		superclass: superclass,
-		methods:    methods,
+		name:         name,
+		superclass:   superclass,
+		methods:      methods,
+		classMethods: classMethods,
+		fields:       make(map[string]ast.LoxValue),
 	}
 }
 
@@ -26,8 +38,59 @@ func (c *LoxClass) String() string             { return c.name }
 func (c *LoxClass) Name() string               { return fmt.Sprintf("%s::%s", c.name, c.name) }
 func (c *LoxClass) Equals(v ast.LoxValue) bool { return c == v }
 
+// Get returns a field of the class or a class method bound to it, the ones of the superclasses are inherited
+func (c *LoxClass) Get(name lexer.Token) ast.LoxValue {
+	for class := c; class != nil; class = class.superclass {
+		if value, ok := class.fields[name.Lexeme]; ok {
+			return value
+		}
+		if method, ok := class.classMethods[name.Lexeme]; ok {
+			return method.Bind(c)
+		}
+	}
+
+	panic(NewUndefinedClassProperty(name.Span, name.Lexeme, c.name, c.hasClassMembers()))
+}
+
+// Set assigns a field declared by the class or by a superclass, the class gets its own copy of an inherited field
+func (c *LoxClass) Set(name lexer.Token, value ast.LoxValue) {
+	for class := c; class != nil; class = class.superclass {
+		if _, ok := class.fields[name.Lexeme]; ok {
+			c.fields[name.Lexeme] = value
+			return
+		}
+	}
+
+	panic(NewUndefinedClassProperty(name.Span, name.Lexeme, c.name, c.hasClassMembers()))
+}
+
+// defineField declares a class field with its initial value
+func (c *LoxClass) defineField(name string, value ast.LoxValue) {
+	c.fields[name] = value
+}
+
+func (c *LoxClass) hasClassMembers() bool {
+	for class := c; class != nil; class = class.superclass {
+		if len(class.fields) > 0 || len(class.classMethods) > 0 {
+			return true
+		}
+	}
+
+	return false
+}
+
+// NewUndefinedClassProperty reports a property missing from a class. A class without class methods or fields has no
+// properties, like in the original language.
+func NewUndefinedClassProperty(span loxerror.Span, name string, className string, hasClassMembers bool) loxerror.LoxError {
+	if !hasClassMembers {
+		return NewInvalidSetGet(span)
+	}
+
+	return NewUndefinedProperty(span, name, className)
+}
+
 func (c *LoxClass) Call(i *Interpreter, arguments []ast.LoxValue) ast.LoxValue {
-	instance := NewLoxInstance(*c)
+	instance := NewLoxInstance(c)
 	if constructor, ok := c.findMethod("init"); ok {
 		constructor.Bind(instance).Call(i, arguments)
 	}
@@ -42,6 +105,17 @@ func (c *LoxClass) Arity() int {
 	return 0
 }
 
+// isSubclassOf tells if the class is the given class or inherits from it
+func (c *LoxClass) isSubclassOf(class *LoxClass) bool {
+	for current := c; current != nil; current = current.superclass {
+		if current == class {
+			return true
+		}
+	}
+
+	return false
+}
+
 func (c *LoxClass) findMethod(name string) (*LoxFunction, bool) {
 	if method, ok := c.methods[name]; ok {
 		return method, true
@@ -19,19 +19,30 @@ func NewLoxFunction(declaration *ast.FunctionStatement, closure *Environment, is
 func (f *LoxFunction) setClassName(className string) { f.className = className }
 func (f LoxFunction) Kind() ast.Kind                 { return ast.Function }
 func (f LoxFunction) IsTruthy() bool                 { return true }
-func (f LoxFunction) String() string                 { return fmt.Sprintf("<fn %s>", f.Declaration.Name.Lexeme) }
+func (f LoxFunction) String() string {
+	if f.Declaration.IsAnonymous() {
+		return "<fn>"
+	}
+
+	return fmt.Sprintf("<fn %s>", f.Declaration.Name.Lexeme)
+}
 func (f LoxFunction) Name() string {
+	if f.Declaration.IsAnonymous() {
+		return ast.AnonymousFunctionName
+	}
+
+	return f.Declaration.Name.Lexeme
+}
+func (f LoxFunction) QualifiedName() string {
 	if len(f.className) == 0 {
-		return f.Declaration.Name.Lexeme
+		return f.Name()
 	}
 
 	return f.className + "::" + f.Declaration.Name.Lexeme
 }
-func (f LoxFunction) Equals(v ast.LoxValue) bool {
-	if v, ok := v.(*LoxFunction); ok {
-		return f.Closure == v.Closure
-	}
-	return false
+func (f *LoxFunction) Equals(v ast.LoxValue) bool {
+	// Each evaluation of a declaration creates a new function
+	return f == v
 }
 
 func (f LoxFunction) Call(i *Interpreter, arguments []ast.LoxValue) (returnValue ast.LoxValue) {
@@ -40,6 +51,11 @@ func (f LoxFunction) Call(i *Interpreter, arguments []ast.LoxValue) (returnValue
 		environment.Define(f.Declaration.Parameters[i].Lexeme, arguments[i])
 	}
 
+	// The function sees the globals of the module where it was declared
+	globals := i.globals
+	i.globals = f.Closure.root()
+	defer func() { i.globals = globals }()
+
 	hasReturned := i.hasReturned
 	i.hasReturned = false
 	i.executeBlock(f.Declaration.Body, environment)
@@ -53,11 +69,13 @@ func (f LoxFunction) Call(i *Interpreter, arguments []ast.LoxValue) (returnValue
 }
 func (f LoxFunction) Arity() int { return len(f.Declaration.Parameters) }
 
-func (f *LoxFunction) Bind(this *LoxInstance) *LoxFunction {
+// Bind returns the method with 'this' bound to an instance, or to the class for the class methods
+func (f *LoxFunction) Bind(this ast.LoxValue) *LoxFunction {
 	environment := NewSubEnvironment(f.Closure)
 	environment.Define("this", this)
 
 	boundFunction := NewLoxFunction(f.Declaration, environment, f.isConstructor)
+	boundFunction.setClassName(f.className)
 
 	return boundFunction
 }
@@ -5,12 +5,19 @@ import (
 	"github.com/fpotier/lox/go/pkg/lexer"
 )
 
+// ObjectValue is implemented by the values having properties
+type ObjectValue interface {
+	ast.LoxValue
+	Get(name lexer.Token) ast.LoxValue
+	Set(name lexer.Token, value ast.LoxValue)
+}
+
 type LoxInstance struct {
-	class  LoxClass
+	class  *LoxClass
 	fields map[string]ast.LoxValue
 }
 
-func NewLoxInstance(class LoxClass) *LoxInstance {
+func NewLoxInstance(class *LoxClass) *LoxInstance {
 	return &LoxInstance{
 		class:  class,
 		fields: make(map[string]ast.LoxValue),
@@ -26,7 +33,7 @@ func (i *LoxInstance) Get(name lexer.Token) ast.LoxValue {
 		return method.Bind(i)
 	}
 
-	panic(NewUndefinedProperty(name.Line, name.Lexeme, i.class.name))
+	panic(NewUndefinedProperty(name.Span, name.Lexeme, i.class.name))
 }
 
 func (i *LoxInstance) Set(name lexer.Token, value ast.LoxValue) {
@@ -36,4 +43,17 @@ func (i *LoxInstance) Set(name lexer.Token, value ast.LoxValue) {
 func (i *LoxInstance) Kind() ast.Kind             { return ast.Instance }
 func (i *LoxInstance) IsTruthy() bool             { return true }
 func (i *LoxInstance) String() string             { return i.class.name + " instance" }
-func (i *LoxInstance) Equals(_ ast.LoxValue) bool { return false }
+func (i *LoxInstance) Equals(v ast.LoxValue) bool { return i == v }
+
+// fieldOrNil returns the value of a field, nil if it's not set
+func fieldOrNil(instance *LoxInstance, name string) ast.LoxValue {
+	if value, ok := instance.fields[name]; ok {
+		return value
+	}
+
+	return ast.NewNilValue()
+}
+
+func isNil(value ast.LoxValue) bool {
+	return value == nil || value.Kind() == ast.Nil
+}
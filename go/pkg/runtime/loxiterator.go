@@ -0,0 +1,60 @@
+package runtime
+
+import (
+	"github.com/fpotier/lox/go/pkg/ast"
+)
+
+// LoxIterator steps through the values of an iterable, it's held by a for-in loop and never reaches the scripts
+type LoxIterator struct {
+	next func() (ast.LoxValue, bool)
+}
+
+// NewLoxIterator creates an iterator from a function returning the next value, or false once there are no more
+func NewLoxIterator(next func() (ast.LoxValue, bool)) *LoxIterator {
+	return &LoxIterator{next: next}
+}
+
+func (it *LoxIterator) Kind() ast.Kind             { return ast.Iterator }
+func (it *LoxIterator) IsTruthy() bool             { return true }
+func (it *LoxIterator) String() string             { return "<iterator>" }
+func (it *LoxIterator) Equals(v ast.LoxValue) bool { return it == v }
+
+// Next returns the next value of the iterable, it returns false once all the values are consumed
+func (it *LoxIterator) Next() (ast.LoxValue, bool) {
+	return it.next()
+}
+
+// BuiltinIterator returns an iterator over the elements of a list, the keys of a map or the characters of a string.
+// The elements and keys added during the iteration are visited.
+func BuiltinIterator(value ast.LoxValue) (*LoxIterator, bool) {
+	index := 0
+	switch value := value.(type) {
+	case *LoxList:
+		return NewLoxIterator(func() (ast.LoxValue, bool) {
+			if index >= len(value.elements) {
+				return nil, false
+			}
+			index++
+			return value.elements[index-1], true
+		}), true
+	case *LoxMap:
+		return NewLoxIterator(func() (ast.LoxValue, bool) {
+			if index >= len(value.keys) {
+				return nil, false
+			}
+			index++
+			return value.keys[index-1], true
+		}), true
+	case *ast.StringValue:
+		characters := []rune(value.Value)
+		return NewLoxIterator(func() (ast.LoxValue, bool) {
+			if index >= len(characters) {
+				return nil, false
+			}
+			index++
+			return ast.NewStringValue(string(characters[index-1])), true
+		}), true
+	default:
+		return nil, false
+	}
+}
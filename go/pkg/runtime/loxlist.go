@@ -0,0 +1,149 @@
+package runtime
+
+import (
+	"math"
+	"strings"
+
+	"github.com/fpotier/lox/go/pkg/ast"
+	"github.com/fpotier/lox/go/pkg/lexer"
+	"github.com/fpotier/lox/go/pkg/loxerror"
+)
+
+// IndexableValue is implemented by the values supporting the subscript operator
+type IndexableValue interface {
+	ast.LoxValue
+	GetIndex(index ast.LoxValue, span loxerror.Span) ast.LoxValue
+	SetIndex(index ast.LoxValue, value ast.LoxValue, span loxerror.Span)
+}
+
+// LoxList is a growable sequence of values, its methods are native functions
+type LoxList struct {
+	elements []ast.LoxValue
+}
+
+func NewLoxList(elements []ast.LoxValue) *LoxList {
+	return &LoxList{elements: elements}
+}
+
+func (l *LoxList) Kind() ast.Kind { return ast.List }
+func (l *LoxList) IsTruthy() bool { return true }
+func (l *LoxList) Equals(v ast.LoxValue) bool {
+	other, ok := v.(*LoxList)
+	return ok && l == other
+}
+func (l *LoxList) String() string {
+	var builder strings.Builder
+	builder.WriteByte('[')
+	for index, element := range l.elements {
+		if index > 0 {
+			builder.WriteString(", ")
+		}
+		builder.WriteString(element.String())
+	}
+	builder.WriteByte(']')
+
+	return builder.String()
+}
+
+// Elements returns the values of the list
+func (l *LoxList) Elements() []ast.LoxValue { return l.elements }
+
+func (l *LoxList) GetIndex(index ast.LoxValue, span loxerror.Span) ast.LoxValue {
+	return l.elements[l.index(index, len(l.elements), span)]
+}
+
+func (l *LoxList) SetIndex(index ast.LoxValue, value ast.LoxValue, span loxerror.Span) {
+	l.elements[l.index(index, len(l.elements), span)] = value
+}
+
+func (l *LoxList) Get(name lexer.Token) ast.LoxValue {
+	if method, ok := l.method(name); ok {
+		return method
+	}
+
+	panic(NewUndefinedProperty(name.Span, name.Lexeme, ast.KindString[ast.List]))
+}
+
+func (l *LoxList) Set(name lexer.Token, _ ast.LoxValue) {
+	panic(NewUndefinedProperty(name.Span, name.Lexeme, ast.KindString[ast.List]))
+}
+
+// method returns the native function implementing a list method, the errors are reported at its name
+func (l *LoxList) method(name lexer.Token) (*NativeFunction, bool) {
+	span := name.Span
+	switch name.Lexeme {
+	case "len":
+		return NewNativeFunction(name.Lexeme, 0, func(Evaluator, []ast.LoxValue) (ast.LoxValue, error) {
+			return ast.NewNumberValue(float64(len(l.elements))), nil
+		}), true
+	case "push":
+		return NewNativeFunction(name.Lexeme, 1, func(_ Evaluator, args []ast.LoxValue) (ast.LoxValue, error) {
+			l.elements = append(l.elements, args[0])
+			return ast.NewNilValue(), nil
+		}), true
+	case "pop":
+		return NewNativeFunction(name.Lexeme, 0, func(Evaluator, []ast.LoxValue) (ast.LoxValue, error) {
+			if len(l.elements) == 0 {
+				panic(NewEmptyList(span))
+			}
+			last := l.elements[len(l.elements)-1]
+			l.elements = l.elements[:len(l.elements)-1]
+			return last, nil
+		}), true
+	case "insert":
+		return NewNativeFunction(name.Lexeme, 2, func(_ Evaluator, args []ast.LoxValue) (ast.LoxValue, error) {
+			// Inserting at the length appends the value
+			index := l.index(args[0], len(l.elements)+1, span)
+			l.elements = append(l.elements, nil)
+			copy(l.elements[index+1:], l.elements[index:])
+			l.elements[index] = args[1]
+			return ast.NewNilValue(), nil
+		}), true
+	case "slice":
+		return NewNativeFunction(name.Lexeme, 2, func(_ Evaluator, args []ast.LoxValue) (ast.LoxValue, error) {
+			start, end := l.integer(args[0], span), l.integer(args[1], span)
+			if start < 0 || end < start || end > len(l.elements) {
+				panic(NewInvalidSliceBounds(span, start, end, len(l.elements)))
+			}
+			elements := make([]ast.LoxValue, end-start)
+			copy(elements, l.elements[start:end])
+			return NewLoxList(elements), nil
+		}), true
+	case "map":
+		return NewNativeFunction(name.Lexeme, 1, func(evaluator Evaluator, args []ast.LoxValue) (ast.LoxValue, error) {
+			// The function may modify the list, it's applied to the elements present when map is called
+			source := make([]ast.LoxValue, len(l.elements))
+			copy(source, l.elements)
+			elements := make([]ast.LoxValue, len(source))
+			for index, element := range source {
+				elements[index] = evaluator.Call(args[0], []ast.LoxValue{element})
+			}
+			return NewLoxList(elements), nil
+		}), true
+	default:
+		return nil, false
+	}
+}
+
+// index checks that the value is a valid index for a sequence of the given length
+func (l *LoxList) index(value ast.LoxValue, length int, span loxerror.Span) int {
+	index := l.integer(value, span)
+	if index < 0 || index >= length {
+		panic(NewIndexOutOfRange(span, index, len(l.elements)))
+	}
+
+	return index
+}
+
+func (l *LoxList) integer(value ast.LoxValue, span loxerror.Span) int {
+	number, ok := value.(*ast.NumberValue)
+	if !ok || number.Value != math.Trunc(number.Value) || math.IsInf(number.Value, 0) {
+		panic(NewInvalidIndex(span, value.String()))
+	}
+	if math.Abs(number.Value) > math.MaxInt32 {
+		// Far out of range anyway, the value is clamped to be representable
+		return int(math.Copysign(math.MaxInt32, number.Value))
+	}
+
+	return int(number.Value)
+}
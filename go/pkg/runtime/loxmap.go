@@ -0,0 +1,151 @@
+package runtime
+
+import (
+	"math"
+	"strings"
+
+	"github.com/fpotier/lox/go/pkg/ast"
+	"github.com/fpotier/lox/go/pkg/lexer"
+	"github.com/fpotier/lox/go/pkg/loxerror"
+)
+
+// LoxMap associates hashable values to values, the entries are kept in insertion order
+type LoxMap struct {
+	keys    []ast.LoxValue
+	entries map[ast.HashKey]ast.LoxValue
+}
+
+func NewLoxMap() *LoxMap {
+	return &LoxMap{
+		keys:    make([]ast.LoxValue, 0),
+		entries: make(map[ast.HashKey]ast.LoxValue),
+	}
+}
+
+func (m *LoxMap) Kind() ast.Kind { return ast.Map }
+func (m *LoxMap) IsTruthy() bool { return true }
+func (m *LoxMap) Equals(v ast.LoxValue) bool {
+	other, ok := v.(*LoxMap)
+	return ok && m == other
+}
+func (m *LoxMap) String() string {
+	var builder strings.Builder
+	builder.WriteByte('{')
+	for index, key := range m.keys {
+		if index > 0 {
+			builder.WriteString(", ")
+		}
+		builder.WriteString(key.String())
+		builder.WriteString(": ")
+		builder.WriteString(m.entries[key.(ast.Hashable).HashKey()].String())
+	}
+	builder.WriteByte('}')
+
+	return builder.String()
+}
+
+// Keys returns the keys of the map in insertion order
+func (m *LoxMap) Keys() []ast.LoxValue { return m.keys }
+
+// Lookup returns the value associated to a key
+func (m *LoxMap) Lookup(key ast.LoxValue) (ast.LoxValue, bool) {
+	hashable, ok := key.(ast.Hashable)
+	if !ok {
+		return nil, false
+	}
+
+	value, ok := m.entries[hashable.HashKey()]
+	return value, ok
+}
+
+func (m *LoxMap) GetIndex(key ast.LoxValue, span loxerror.Span) ast.LoxValue {
+	value, ok := m.entries[m.hashKey(key, span)]
+	if !ok {
+		panic(NewUndefinedKey(span, key.String()))
+	}
+
+	return value
+}
+
+func (m *LoxMap) SetIndex(key ast.LoxValue, value ast.LoxValue, span loxerror.Span) {
+	hashKey := m.hashKey(key, span)
+	if _, ok := m.entries[hashKey]; !ok {
+		m.keys = append(m.keys, key)
+	}
+	m.entries[hashKey] = value
+}
+
+func (m *LoxMap) Get(name lexer.Token) ast.LoxValue {
+	if method, ok := m.method(name); ok {
+		return method
+	}
+
+	panic(NewUndefinedProperty(name.Span, name.Lexeme, ast.KindString[ast.Map]))
+}
+
+func (m *LoxMap) Set(name lexer.Token, _ ast.LoxValue) {
+	panic(NewUndefinedProperty(name.Span, name.Lexeme, ast.KindString[ast.Map]))
+}
+
+// method returns the native function implementing a map method, the errors are reported at its name
+func (m *LoxMap) method(name lexer.Token) (*NativeFunction, bool) {
+	span := name.Span
+	switch name.Lexeme {
+	case "len":
+		return NewNativeFunction(name.Lexeme, 0, func(Evaluator, []ast.LoxValue) (ast.LoxValue, error) {
+			return ast.NewNumberValue(float64(len(m.keys))), nil
+		}), true
+	case "keys":
+		return NewNativeFunction(name.Lexeme, 0, func(Evaluator, []ast.LoxValue) (ast.LoxValue, error) {
+			keys := make([]ast.LoxValue, len(m.keys))
+			copy(keys, m.keys)
+			return NewLoxList(keys), nil
+		}), true
+	case "values":
+		return NewNativeFunction(name.Lexeme, 0, func(Evaluator, []ast.LoxValue) (ast.LoxValue, error) {
+			values := make([]ast.LoxValue, 0, len(m.keys))
+			for _, key := range m.keys {
+				values = append(values, m.entries[key.(ast.Hashable).HashKey()])
+			}
+			return NewLoxList(values), nil
+		}), true
+	case "has":
+		return NewNativeFunction(name.Lexeme, 1, func(_ Evaluator, args []ast.LoxValue) (ast.LoxValue, error) {
+			_, ok := m.entries[m.hashKey(args[0], span)]
+			return ast.NewBooleanValue(ok), nil
+		}), true
+	case "remove":
+		// remove returns the value of the removed entry, or nil if the key is missing
+		return NewNativeFunction(name.Lexeme, 1, func(_ Evaluator, args []ast.LoxValue) (ast.LoxValue, error) {
+			hashKey := m.hashKey(args[0], span)
+			value, ok := m.entries[hashKey]
+			if !ok {
+				return ast.NewNilValue(), nil
+			}
+			delete(m.entries, hashKey)
+			for index, key := range m.keys {
+				if key.(ast.Hashable).HashKey() == hashKey {
+					m.keys = append(m.keys[:index], m.keys[index+1:]...)
+					break
+				}
+			}
+			return value, nil
+		}), true
+	default:
+		return nil, false
+	}
+}
+
+// hashKey returns the key identifying a value in the map
+func (m *LoxMap) hashKey(key ast.LoxValue, span loxerror.Span) ast.HashKey {
+	hashable, ok := key.(ast.Hashable)
+	if !ok {
+		panic(NewUnhashableKey(span, ast.KindString[key.Kind()]))
+	}
+	// NaN isn't equal to itself, it could never be found
+	if number, ok := key.(*ast.NumberValue); ok && math.IsNaN(number.Value) {
+		panic(NewUnhashableKey(span, "NaN"))
+	}
+
+	return hashable.HashKey()
+}
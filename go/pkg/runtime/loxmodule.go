@@ -0,0 +1,130 @@
+package runtime
+
+import (
+	"os"
+	"path/filepath"
+
+	"github.com/fpotier/lox/go/pkg/ast"
+	"github.com/fpotier/lox/go/pkg/lexer"
+	"github.com/fpotier/lox/go/pkg/loxerror"
+	"github.com/fpotier/lox/go/pkg/parser"
+)
+
+// LoxModule is an imported module, its properties are the global variables it declared
+type LoxModule struct {
+	// name is the path of the module in the first import statement
+	name    string
+	globals map[string]ast.LoxValue
+	// builtins are defined in the globals of every module, they aren't exported unless the module redefines them
+	builtins map[string]ast.LoxValue
+}
+
+func NewLoxModule(name string, globals map[string]ast.LoxValue, builtins map[string]ast.LoxValue) *LoxModule {
+	return &LoxModule{name: name, globals: globals, builtins: builtins}
+}
+
+func (m *LoxModule) Kind() ast.Kind { return ast.Module }
+func (m *LoxModule) IsTruthy() bool { return true }
+func (m *LoxModule) String() string { return "<module " + m.name + ">" }
+func (m *LoxModule) Equals(v ast.LoxValue) bool {
+	other, ok := v.(*LoxModule)
+	return ok && m == other
+}
+
+func (m *LoxModule) Get(name lexer.Token) ast.LoxValue {
+	if value, ok := m.globals[name.Lexeme]; ok && !m.isBuiltin(name.Lexeme, value) {
+		return value
+	}
+
+	panic(NewUndefinedExport(name.Span, name.Lexeme, m.name))
+}
+
+func (m *LoxModule) Set(name lexer.Token, _ ast.LoxValue) {
+	panic(NewImmutableModule(name.Span, name.Lexeme, m.name))
+}
+
+func (m *LoxModule) isBuiltin(name string, value ast.LoxValue) bool {
+	builtin, ok := m.builtins[name]
+	return ok && builtin == value
+}
+
+// ModuleEvaluator resolves and runs the statements of a module, it returns the global variables of the module.
+// The static errors are pushed to the error formatter, the returned globals are ignored if there are any.
+type ModuleEvaluator func(statements []ast.Statement, errorFormatter loxerror.ErrorFormatter) map[string]ast.LoxValue
+
+// Modules caches the modules imported by a backend, each module is evaluated once
+type Modules struct {
+	// builtins are the globals of the backend given to every module
+	builtins map[string]ast.LoxValue
+	loaded   map[string]*LoxModule
+	// loading holds the modules being evaluated, importing one of them again is a circular import
+	loading map[string]bool
+}
+
+func NewModules(builtins map[string]ast.LoxValue) *Modules {
+	return &Modules{
+		builtins: builtins,
+		loaded:   make(map[string]*LoxModule),
+		loading:  make(map[string]bool),
+	}
+}
+
+// Import returns the module at the path of an import statement, it's evaluated by evaluate the first time.
+// The span locates the path, a relative path is resolved from the directory of its file.
+// The source code is given to errorFormatter if it quotes it, the static errors of the module are raised together.
+func (m *Modules) Import(
+	name string,
+	span loxerror.Span,
+	errorFormatter loxerror.ErrorFormatter,
+	evaluate ModuleEvaluator,
+) *LoxModule {
+	file := modulePath(name, span.File)
+	key, err := filepath.Abs(file)
+	if err != nil {
+		panic(NewModuleNotFound(span, name))
+	}
+
+	if module, ok := m.loaded[key]; ok {
+		return module
+	}
+	if m.loading[key] {
+		panic(NewCircularImport(span, name))
+	}
+
+	sourceCode, err := os.ReadFile(file)
+	if err != nil {
+		panic(NewModuleNotFound(span, name))
+	}
+	if sourceFormatter, ok := errorFormatter.(loxerror.SourceFormatter); ok {
+		sourceFormatter.SetSource(file, string(sourceCode))
+	}
+
+	m.loading[key] = true
+	defer delete(m.loading, key)
+
+	// The static errors are kept apart from the errors of the importing script
+	moduleErrors := loxerror.NewJSONErrorFormatter()
+	tokens := lexer.NewFileLexer(moduleErrors, file, string(sourceCode)).Tokens()
+	statements := parser.NewParser(moduleErrors, tokens).Parse()
+	var globals map[string]ast.LoxValue
+	if !moduleErrors.HasErrors() {
+		globals = evaluate(statements, moduleErrors)
+	}
+	if moduleErrors.HasErrors() {
+		panic(loxerror.Group(moduleErrors.Errors()))
+	}
+
+	module := NewLoxModule(name, globals, m.builtins)
+	m.loaded[key] = module
+
+	return module
+}
+
+// modulePath returns the path of an imported file, a relative path is relative to the directory of the importing file
+func modulePath(path string, importingFile string) string {
+	if filepath.IsAbs(path) || importingFile == "" {
+		return filepath.Clean(path)
+	}
+
+	return filepath.Join(filepath.Dir(importingFile), path)
+}
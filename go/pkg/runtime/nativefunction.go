@@ -1,7 +1,6 @@
 package runtime
 
 import (
-	"os"
 	"time"
 
 	"github.com/fpotier/lox/go/pkg/ast"
@@ -13,36 +12,51 @@ type NativeFunction struct {
 	code  CallableCode
 }
 
+func NewNativeFunction(name string, arity int, code CallableCode) *NativeFunction {
+	return &NativeFunction{name: name, arity: arity, code: code}
+}
+
 func (f NativeFunction) Kind() ast.Kind             { return ast.NativeFunc }
 func (f NativeFunction) IsTruthy() bool             { return true }
 func (f NativeFunction) String() string             { return "<native fn>" }
 func (f NativeFunction) Name() string               { return f.name }
 func (f NativeFunction) Equals(_ ast.LoxValue) bool { return false }
 func (f NativeFunction) Call(i *Interpreter, arguments []ast.LoxValue) ast.LoxValue {
-	return f.code(i, arguments)
+	value, err := f.Invoke(i, arguments)
+	if err != nil {
+		panic(NewNativeFunctionError(i.callSite().Span, f.name, err.Error()))
+	}
+
+	return value
+}
+
+// Invoke runs the Go code of the function, the backends turn the returned error into a runtime error
+func (f NativeFunction) Invoke(evaluator Evaluator, arguments []ast.LoxValue) (ast.LoxValue, error) {
+	value, err := f.code(evaluator, arguments)
+	if err != nil {
+		return nil, err
+	}
+	if value == nil {
+		return ast.NewNilValue(), nil
+	}
+
+	return value, nil
 }
 func (f NativeFunction) Arity() int { return f.arity }
 
-var builtinNativeFunctions = []NativeFunction{
-	{
-		name:  "clock",
-		arity: 0,
-		code: func(*Interpreter, []ast.LoxValue) ast.LoxValue {
-			return ast.NewNumberValue(float64(time.Now().Unix()))
-		},
-	},
-	{
-		name:  "getchar",
-		arity: 0,
-		code: func(*Interpreter, []ast.LoxValue) ast.LoxValue {
-			// FIXME: terminates the REPL mode
-			var buffer [1]byte
-			bytesRead, err := os.Stdin.Read(buffer[:])
-			if bytesRead == 0 || err != nil {
-				return ast.NewNumberValue(-1)
-			}
-
-			return ast.NewNumberValue(float64(int(buffer[0])))
-		},
-	},
+// BuiltinNativeFunctions are defined in the globals of every program
+var BuiltinNativeFunctions = []*NativeFunction{
+	NewNativeFunction("clock", 0, func(Evaluator, []ast.LoxValue) (ast.LoxValue, error) {
+		return ast.NewNumberValue(float64(time.Now().Unix())), nil
+	}),
+	NewNativeFunction("getchar", 0, func(evaluator Evaluator, _ []ast.LoxValue) (ast.LoxValue, error) {
+		// FIXME: terminates the REPL mode
+		var buffer [1]byte
+		bytesRead, err := evaluator.Stdin().Read(buffer[:])
+		if bytesRead == 0 || err != nil {
+			return ast.NewNumberValue(-1), nil
+		}
+
+		return ast.NewNumberValue(float64(int(buffer[0]))), nil
+	}),
 }
@@ -21,23 +21,64 @@ const (
 	NoClass ClassType = iota
 	InClass
 	InSubClass
+	// InClassMethod is a method called on the class, 'this' is the class and there is no 'super'
+	InClassMethod
 )
 
+// ScopeRecorder is told at which scope distance each local variable is used
+type ScopeRecorder interface {
+	Resolve(expression ast.Expression, depth int)
+}
+
+// SymbolKind is the kind of a declaration reported to a SymbolRecorder
+type SymbolKind uint8
+
+const (
+	VariableSymbol SymbolKind = iota
+	FunctionSymbol
+	ClassSymbol
+	ParameterSymbol
+	// MethodSymbol covers the methods, the getters and the class methods
+	MethodSymbol
+	// FieldSymbol covers the class fields and the annotated instance fields
+	FieldSymbol
+)
+
+// SymbolRecorder is told where the names are declared and used, the language server indexes the symbols with it
+type SymbolRecorder interface {
+	// Declare is called for each declaration, global is set for the global variables, functions and classes
+	Declare(name lexer.Token, kind SymbolKind, global bool)
+	// Refer is called for each use of a variable, declaration is nil for a global variable
+	Refer(name lexer.Token, declaration *lexer.Token)
+	// ReferProperty is called for each property read or assigned, a property is only known by its name
+	ReferProperty(name lexer.Token)
+}
+
 type Resolver struct {
-	errorFormatter   loxerror.ErrorFormatter
-	interpreter      *Interpreter
-	scopes           []map[string]bool
+	errorFormatter loxerror.ErrorFormatter
+	scopeRecorder  ScopeRecorder
+	// SymbolRecorder is optional, it's nil when the symbols aren't needed
+	SymbolRecorder SymbolRecorder
+	scopes         []map[string]bool
+	// declarations holds the declaring token of the names of scopes
+	declarations     []map[string]lexer.Token
 	currentFnType    FunctionType
 	currentClassType ClassType
+	// loopLabels holds the labels of the loops enclosing the current statement in the current function,
+	// the unlabelled loops have an empty label
+	loopLabels []string
 }
 
-func NewResolver(errorFormatter loxerror.ErrorFormatter, i *Interpreter) *Resolver {
+func NewResolver(errorFormatter loxerror.ErrorFormatter, scopeRecorder ScopeRecorder) *Resolver {
 	r := Resolver{
 		errorFormatter:   errorFormatter,
-		interpreter:      i,
+		scopeRecorder:    scopeRecorder,
+		SymbolRecorder:   nil,
 		scopes:           make([]map[string]bool, 0),
+		declarations:     make([]map[string]lexer.Token, 0),
 		currentFnType:    NoFunc,
 		currentClassType: NoClass,
+		loopLabels:       make([]string, 0),
 	}
 
 	return &r
@@ -52,6 +93,7 @@ func (r *Resolver) ResolveProgram(program []ast.Statement) {
 func (r *Resolver) VisitAssignmentExpression(e *ast.AssignmentExpression) {
 	r.resolveExpression(e.Value)
 	r.resolveLocal(e, e.Name)
+	r.refer(e.Name)
 }
 
 func (r *Resolver) VisitBinaryExpression(e *ast.BinaryExpression) {
@@ -67,14 +109,43 @@ func (r *Resolver) VisitCallExpression(e *ast.CallExpression) {
 	}
 }
 
+func (r *Resolver) VisitFunctionExpression(e *ast.FunctionExpression) {
+	r.resolveFunction(*e.Function, Func)
+}
+
 func (r *Resolver) VisitGetExpression(e *ast.GetExpression) {
 	r.resolveExpression(e.Object)
+	r.referProperty(e.Name)
 }
 
 func (r *Resolver) VisitGroupingExpression(e *ast.GroupingExpression) {
 	r.resolveExpression(e.Expr)
 }
 
+func (r *Resolver) VisitIndexGetExpression(e *ast.IndexGetExpression) {
+	r.resolveExpression(e.Object)
+	r.resolveExpression(e.Index)
+}
+
+func (r *Resolver) VisitIndexSetExpression(e *ast.IndexSetExpression) {
+	r.resolveExpression(e.Value)
+	r.resolveExpression(e.Object)
+	r.resolveExpression(e.Index)
+}
+
+func (r *Resolver) VisitListExpression(e *ast.ListExpression) {
+	for _, element := range e.Elements {
+		r.resolveExpression(element)
+	}
+}
+
+func (r *Resolver) VisitMapExpression(e *ast.MapExpression) {
+	for index, key := range e.Keys {
+		r.resolveExpression(key)
+		r.resolveExpression(e.Values[index])
+	}
+}
+
 func (r *Resolver) VisitLiteralExpression(*ast.LiteralExpression) {}
 
 func (r *Resolver) VisitLogicalExpression(e *ast.LogicalExpression) {
@@ -85,21 +156,29 @@ func (r *Resolver) VisitLogicalExpression(e *ast.LogicalExpression) {
 func (r *Resolver) VisitSetExpression(e *ast.SetExpression) {
 	r.resolveExpression(e.Value)
 	r.resolveExpression(e.Object)
+	r.referProperty(e.Name)
+}
+
+func (r *Resolver) VisitStringifyExpression(e *ast.StringifyExpression) {
+	r.resolveExpression(e.Value)
 }
 
 func (r *Resolver) VisitSuperExpression(e *ast.SuperExpression) {
-	if r.currentClassType == NoClass {
-		r.errorFormatter.PushError(NewInvalidSuper(e.Keyword.Line, "outside of a class"))
+	if r.currentClassType == InClassMethod {
+		r.errorFormatter.PushError(NewInvalidSuper(e.Keyword.Span, "in a class method"))
+	} else if r.currentClassType == NoClass {
+		r.errorFormatter.PushError(NewInvalidSuper(e.Keyword.Span, "outside of a class"))
 	} else if r.currentClassType != InSubClass {
-		r.errorFormatter.PushError(NewInvalidSuper(e.Keyword.Line, "in a class with no superclass"))
+		r.errorFormatter.PushError(NewInvalidSuper(e.Keyword.Span, "in a class with no superclass"))
 	}
 
 	r.resolveLocal(e, e.Keyword)
+	r.referProperty(e.Method)
 }
 
 func (r *Resolver) VisitThisExpression(e *ast.ThisExpression) {
 	if r.currentClassType == NoClass {
-		r.errorFormatter.PushError(NewInvalidThis(e.Keyword.Line, "outside of a class"))
+		r.errorFormatter.PushError(NewInvalidThis(e.Keyword.Span, "outside of a class"))
 	}
 
 	r.resolveLocal(e, e.Keyword)
@@ -112,32 +191,33 @@ func (r *Resolver) VisitUnaryExpression(e *ast.UnaryExpression) {
 func (r *Resolver) VisitVariableExpression(e *ast.VariableExpression) {
 	if len(r.scopes) > 0 {
 		if declared, ok := r.scopes[len(r.scopes)-1][e.Name.Lexeme]; ok && !declared {
-			r.errorFormatter.PushError(NewUninitializedRead(e.Name.Line))
+			r.errorFormatter.PushError(NewUninitializedRead(e.Name.Span))
 		}
 	}
 
 	r.resolveLocal(e, e.Name)
+	r.refer(e.Name)
 }
 
 func (r *Resolver) VisitBlockStatement(s *ast.BlockStatement) {
-	r.beginScope()
-	for _, statement := range s.Statements {
-		r.resolveStatement(statement)
-	}
-	r.endScope()
+	r.resolveBlock(s.Statements)
+}
+
+func (r *Resolver) VisitBreakStatement(s *ast.BreakStatement) {
+	r.resolveLoopControl(s.Keyword, s.Label)
 }
 
 func (r *Resolver) VisitClassStatement(s *ast.ClassStatement) {
 	enclosingClass := r.currentClassType
 	r.currentClassType = InClass
 
-	r.declare(s.Name)
+	r.declareSymbol(s.Name, ClassSymbol)
 	r.define(s.Name)
 
 	if s.Superclass != nil {
 		r.currentClassType = InSubClass
 		if s.Name.Lexeme == s.Superclass.Name.Lexeme {
-			r.errorFormatter.PushError(NewInvalidInheritance(s.Superclass.Name.Line, "A class can't inherit from itself"))
+			r.errorFormatter.PushError(NewInvalidInheritance(s.Superclass.Name.Span, "A class can't inherit from itself"))
 		}
 		r.resolveExpression(s.Superclass)
 
@@ -145,6 +225,7 @@ func (r *Resolver) VisitClassStatement(s *ast.ClassStatement) {
 		r.scopes[len(r.scopes)-1]["super"] = true
 	}
 
+	r.recordMembers(s)
 	r.beginScope()
 	r.scopes[len(r.scopes)-1]["this"] = true
 	for _, method := range s.Methods {
@@ -154,6 +235,12 @@ func (r *Resolver) VisitClassStatement(s *ast.ClassStatement) {
 		}
 		r.resolveFunction(*method, fnType)
 	}
+	instanceClassType := r.currentClassType
+	r.currentClassType = InClassMethod
+	for _, method := range s.ClassMethods {
+		r.resolveFunction(*method, Method)
+	}
+	r.currentClassType = instanceClassType
 	r.endScope()
 
 	if s.Superclass != nil {
@@ -161,16 +248,46 @@ func (r *Resolver) VisitClassStatement(s *ast.ClassStatement) {
 	}
 
 	r.currentClassType = enclosingClass
+
+	// The class fields are initialized in the scope of the class declaration
+	for _, field := range s.ClassFields {
+		if field.Initializer != nil {
+			r.resolveExpression(field.Initializer)
+		}
+	}
+}
+
+func (r *Resolver) VisitContinueStatement(s *ast.ContinueStatement) {
+	r.resolveLoopControl(s.Keyword, s.Label)
 }
 
 func (r *Resolver) VisitExpressionStatement(s *ast.ExpressionStatement) {
 	r.resolveExpression(s.Expression)
 }
 
-func (r *Resolver) VisitFunctionStatement(s *ast.FunctionStatement) {
+func (r *Resolver) VisitForInStatement(s *ast.ForInStatement) {
+	r.resolveExpression(s.Iterable)
+
+	// The loop variable is declared once, each iteration assigns it
+	r.beginScope()
 	r.declare(s.Name)
 	r.define(s.Name)
 
+	label := ""
+	if s.Label != nil {
+		label = s.Label.Lexeme
+	}
+	r.loopLabels = append(r.loopLabels, label)
+	r.resolveStatement(s.Body)
+	r.loopLabels = r.loopLabels[:len(r.loopLabels)-1]
+
+	r.endScope()
+}
+
+func (r *Resolver) VisitFunctionStatement(s *ast.FunctionStatement) {
+	r.declareSymbol(s.Name, FunctionSymbol)
+	r.define(s.Name)
+
 	r.resolveFunction(*s, Func)
 }
 
@@ -182,24 +299,58 @@ func (r *Resolver) VisitIfStatement(s *ast.IfStatement) {
 	}
 }
 
+func (r *Resolver) VisitImportStatement(s *ast.ImportStatement) {
+	if s.Alias != nil {
+		r.declare(*s.Alias)
+		r.define(*s.Alias)
+	}
+	for _, name := range s.Names {
+		r.declare(name)
+		r.define(name)
+	}
+}
+
 func (r *Resolver) VisitPrintStatement(s *ast.PrintStatement) {
 	r.resolveExpression(s.Expression)
 }
 
 func (r *Resolver) VisitReturnStatement(s *ast.ReturnStatement) {
 	if r.currentFnType == NoFunc {
-		r.errorFormatter.PushError(NewInvalidReturn(s.Keyword.Line, "top-level code"))
+		r.errorFormatter.PushError(NewInvalidReturn(s.Keyword.Span, "top-level code"))
 	}
 
 	if s.Value != nil {
 		if r.currentFnType == Constructor {
-			r.errorFormatter.PushError(NewInvalidReturn(s.Keyword.Line, "constructor"))
+			r.errorFormatter.PushError(NewInvalidReturn(s.Keyword.Span, "constructor"))
 		}
 
 		r.resolveExpression(s.Value)
 	}
 }
 
+func (r *Resolver) VisitThrowStatement(s *ast.ThrowStatement) {
+	r.resolveExpression(s.Value)
+}
+
+func (r *Resolver) VisitTryStatement(s *ast.TryStatement) {
+	r.resolveBlock(s.Body)
+
+	if s.Catch != nil {
+		// The caught value is a variable of the scope of the catch body
+		r.beginScope()
+		r.declare(s.Catch.Name)
+		r.define(s.Catch.Name)
+		for _, statement := range s.Catch.Body {
+			r.resolveStatement(statement)
+		}
+		r.endScope()
+	}
+
+	if s.Finally != nil {
+		r.resolveBlock(s.Finally.Statements)
+	}
+}
+
 func (r *Resolver) VisitVariableStatement(s *ast.VariableStatement) {
 	r.declare(s.Name)
 	if s.Initializer != nil {
@@ -210,7 +361,26 @@ func (r *Resolver) VisitVariableStatement(s *ast.VariableStatement) {
 
 func (r *Resolver) VisitWhileStatement(s *ast.WhileStatement) {
 	r.resolveExpression(s.Condition)
+
+	label := ""
+	if s.Label != nil {
+		label = s.Label.Lexeme
+	}
+	r.loopLabels = append(r.loopLabels, label)
 	r.resolveStatement(s.Body)
+	r.loopLabels = r.loopLabels[:len(r.loopLabels)-1]
+
+	if s.Increment != nil {
+		r.resolveExpression(s.Increment)
+	}
+}
+
+func (r *Resolver) resolveBlock(statements []ast.Statement) {
+	r.beginScope()
+	for _, statement := range statements {
+		r.resolveStatement(statement)
+	}
+	r.endScope()
 }
 
 func (r *Resolver) resolveStatement(s ast.Statement)   { s.Accept(r) }
@@ -219,7 +389,7 @@ func (r *Resolver) resolveExpression(e ast.Expression) { e.Accept(r) }
 func (r *Resolver) resolveLocal(e ast.Expression, name lexer.Token) {
 	for i := len(r.scopes) - 1; i >= 0; i-- {
 		if _, ok := r.scopes[i][name.Lexeme]; ok {
-			r.interpreter.resolve(e, len(r.scopes)-1-i)
+			r.scopeRecorder.Resolve(e, len(r.scopes)-1-i)
 			return
 		}
 	}
@@ -228,10 +398,13 @@ func (r *Resolver) resolveLocal(e ast.Expression, name lexer.Token) {
 func (r *Resolver) resolveFunction(function ast.FunctionStatement, kind FunctionType) {
 	enclosingFunction := r.currentFnType
 	r.currentFnType = kind
+	// A function body can't break out of the loops around its declaration
+	enclosingLoops := r.loopLabels
+	r.loopLabels = make([]string, 0)
 
 	r.beginScope()
 	for _, parameter := range function.Parameters {
-		r.declare(parameter)
+		r.declareSymbol(parameter, ParameterSymbol)
 		r.define(parameter)
 	}
 	for _, statement := range function.Body {
@@ -239,18 +412,92 @@ func (r *Resolver) resolveFunction(function ast.FunctionStatement, kind Function
 	}
 	r.endScope()
 
+	r.loopLabels = enclosingLoops
 	r.currentFnType = enclosingFunction
 }
 
-func (r *Resolver) beginScope() { r.scopes = append(r.scopes, make(map[string]bool)) }
-func (r *Resolver) endScope()   { r.scopes = r.scopes[:len(r.scopes)-1] }
+// resolveLoopControl checks that a break or a continue statement is in a loop having its label
+func (r *Resolver) resolveLoopControl(keyword lexer.Token, label *lexer.Token) {
+	if len(r.loopLabels) == 0 {
+		r.errorFormatter.PushError(NewInvalidLoopControl(keyword.Span, keyword.Lexeme))
+		return
+	}
+	if label == nil {
+		return
+	}
+
+	for _, loopLabel := range r.loopLabels {
+		if loopLabel == label.Lexeme {
+			return
+		}
+	}
+	r.errorFormatter.PushError(NewUndefinedLabel(label.Span, label.Lexeme))
+}
+
+// recordMembers declares the methods and the fields of a class to the symbol recorder
+func (r *Resolver) recordMembers(s *ast.ClassStatement) {
+	if r.SymbolRecorder == nil {
+		return
+	}
+
+	for _, methods := range [][]*ast.FunctionStatement{s.Methods, s.ClassMethods} {
+		for _, method := range methods {
+			r.SymbolRecorder.Declare(method.Name, MethodSymbol, false)
+		}
+	}
+	for _, fields := range [][]*ast.VariableStatement{s.ClassFields, s.Fields} {
+		for _, field := range fields {
+			r.SymbolRecorder.Declare(field.Name, FieldSymbol, false)
+		}
+	}
+}
+
+// refer tells the symbol recorder which declaration a variable refers to
+func (r *Resolver) refer(name lexer.Token) {
+	if r.SymbolRecorder == nil {
+		return
+	}
+
+	for i := len(r.declarations) - 1; i >= 0; i-- {
+		if declaration, ok := r.declarations[i][name.Lexeme]; ok {
+			r.SymbolRecorder.Refer(name, &declaration)
+			return
+		}
+	}
+	r.SymbolRecorder.Refer(name, nil)
+}
+
+func (r *Resolver) referProperty(name lexer.Token) {
+	if r.SymbolRecorder != nil {
+		r.SymbolRecorder.ReferProperty(name)
+	}
+}
+
+func (r *Resolver) beginScope() {
+	r.scopes = append(r.scopes, make(map[string]bool))
+	r.declarations = append(r.declarations, make(map[string]lexer.Token))
+}
+
+func (r *Resolver) endScope() {
+	r.scopes = r.scopes[:len(r.scopes)-1]
+	r.declarations = r.declarations[:len(r.declarations)-1]
+}
 
 func (r *Resolver) declare(name lexer.Token) {
+	r.declareSymbol(name, VariableSymbol)
+}
+
+func (r *Resolver) declareSymbol(name lexer.Token, kind SymbolKind) {
+	if r.SymbolRecorder != nil {
+		r.SymbolRecorder.Declare(name, kind, len(r.scopes) == 0)
+	}
+
 	if len(r.scopes) > 0 {
 		if _, ok := r.scopes[len(r.scopes)-1][name.Lexeme]; ok {
-			r.errorFormatter.PushError(NewVariableRedeclaration(name.Line, name.Lexeme))
+			r.errorFormatter.PushError(NewVariableRedeclaration(name.Span, name.Lexeme))
 		}
 		r.scopes[len(r.scopes)-1][name.Lexeme] = false
+		r.declarations[len(r.declarations)-1][name.Lexeme] = name
 	}
 }
 
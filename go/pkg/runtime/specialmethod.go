@@ -0,0 +1,35 @@
+package runtime
+
+import "github.com/fpotier/lox/go/pkg/lexer"
+
+// The classes overload the operators by defining special methods, they are called on the left operand.
+// An instance of a class not defining them is only equal to itself.
+const (
+	NegateMethod = "__neg__"
+	// StringMethod converts an instance to a string for print and the interpolated strings
+	StringMethod = "__str__"
+)
+
+// BinaryOperatorMethods associates the binary operators to the special methods overloading them, it's indexed by the
+// token type of the operator. != is the negation of __eq__.
+var BinaryOperatorMethods = [...]string{
+	lexer.Plus:         "__add__",
+	lexer.Dash:         "__sub__",
+	lexer.Star:         "__mul__",
+	lexer.Slash:        "__div__",
+	lexer.Less:         "__lt__",
+	lexer.LessEqual:    "__le__",
+	lexer.Greater:      "__gt__",
+	lexer.GreaterEqual: "__ge__",
+	lexer.EqualEqual:   "__eq__",
+	lexer.BangEqual:    "__eq__",
+}
+
+// The iteration protocol of the for-in loops: the method IterMethod of an iterable returns an iterator,
+// the loop calls its method NextMethod until its property DoneProperty is true.
+// IterMethod may also return a list, a map or a string.
+const (
+	IterMethod   = "iter"
+	NextMethod   = "next"
+	DoneProperty = "done"
+)
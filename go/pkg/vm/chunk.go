@@ -0,0 +1,97 @@
+package vm
+
+import (
+	"math"
+
+	"github.com/fpotier/lox/go/pkg/ast"
+	"github.com/fpotier/lox/go/pkg/loxerror"
+)
+
+type OpCode byte
+
+const (
+	OpConstant OpCode = iota
+	OpNil
+	OpTrue
+	OpFalse
+	OpPop
+	OpGetLocal
+	OpSetLocal
+	OpGetGlobal
+	OpDefineGlobal
+	OpSetGlobal
+	OpGetUpvalue
+	OpSetUpvalue
+	OpGetProperty
+	OpSetProperty
+	OpGetSuper
+	OpEqual
+	OpGreater
+	OpGreaterEqual
+	OpLess
+	OpLessEqual
+	OpAdd
+	OpSubtract
+	OpMultiply
+	OpDivide
+	OpNot
+	OpNegate
+	OpStringify
+	OpPrint
+	OpJump
+	OpJumpIfFalse
+	// OpIterator replaces the iterable on top of the stack by its iterator
+	OpIterator
+	// OpForIter sets the loop variable on top of the stack to the next value of the iterator below it,
+	// it jumps out of the loop once the iterator is exhausted
+	OpForIter
+	OpLoop
+	OpCall
+	OpInvoke
+	OpSuperInvoke
+	OpClosure
+	OpCloseUpvalue
+	OpReturn
+	OpClass
+	OpInherit
+	OpMethod
+	OpClassMethod
+	// OpClassField declares a field of the class below the value on top of the stack, both are popped
+	OpClassField
+	OpList
+	OpMap
+	OpGetIndex
+	OpSetIndex
+	OpThrow
+	OpTry
+	OpTryFinally
+	OpEndTry
+	OpImport
+)
+
+// The operands are single bytes, except for the jump offsets and the sizes of the literals which are two bytes long
+const (
+	maxConstants = math.MaxUint8 + 1
+	maxLocals    = math.MaxUint8 + 1
+	maxUpvalues  = math.MaxUint8 + 1
+	maxJump      = math.MaxUint16
+	maxElements  = math.MaxUint16
+)
+
+// Chunk is the bytecode of a function
+type Chunk struct {
+	Code []byte
+	// Spans holds the position in the source code of each byte of Code
+	Spans     []loxerror.Span
+	Constants []ast.LoxValue
+}
+
+func (c *Chunk) write(b byte, span loxerror.Span) {
+	c.Code = append(c.Code, b)
+	c.Spans = append(c.Spans, span)
+}
+
+func (c *Chunk) addConstant(value ast.LoxValue) int {
+	c.Constants = append(c.Constants, value)
+	return len(c.Constants) - 1
+}
@@ -0,0 +1,944 @@
+//go:generate go run ../../cmd/code-generator vm
+
+package vm
+
+import (
+	"github.com/fpotier/lox/go/pkg/ast"
+	"github.com/fpotier/lox/go/pkg/lexer"
+	"github.com/fpotier/lox/go/pkg/loxerror"
+)
+
+type functionType uint8
+
+const (
+	scriptFunc functionType = iota
+	funcFunc
+	methodFunc
+	initializerFunc
+)
+
+type local struct {
+	name       string
+	depth      int
+	isCaptured bool
+}
+
+type upvalueReference struct {
+	index   byte
+	isLocal bool
+}
+
+// loop is a loop being compiled, its break and continue jumps are patched once its body is compiled
+type loop struct {
+	// label is empty for the unlabelled loops
+	label string
+	// scopeDepth is the depth of the loop statement, the locals of the body are popped by the jumps
+	scopeDepth    int
+	breakJumps    []int
+	continueJumps []int
+}
+
+// tryBlock is a try statement whose handler is active, the statements leaving it pop the handler and run its finally clause
+type tryBlock struct {
+	// loopCount is the number of loops enclosing the try statement in the function
+	loopCount int
+	// finally is nil if the statement has no finally clause
+	finally *ast.BlockStatement
+}
+
+// functionCompiler holds the state of the function being compiled
+type functionCompiler struct {
+	enclosing  *functionCompiler
+	function   *Function
+	kind       functionType
+	locals     []local
+	upvalues   []upvalueReference
+	scopeDepth int
+	loops      []*loop
+	tries      []*tryBlock
+}
+
+func newFunctionCompiler(enclosing *functionCompiler, function *Function, kind functionType) *functionCompiler {
+	f := functionCompiler{
+		enclosing:  enclosing,
+		function:   function,
+		kind:       kind,
+		locals:     make([]local, 0, maxLocals),
+		upvalues:   make([]upvalueReference, 0),
+		scopeDepth: 0,
+		loops:      make([]*loop, 0),
+		tries:      make([]*tryBlock, 0),
+	}
+
+	// The first slot holds the called function, or the instance in methods
+	slotZero := ""
+	if kind == methodFunc || kind == initializerFunc {
+		slotZero = "this"
+	}
+	f.locals = append(f.locals, local{name: slotZero, depth: 0, isCaptured: false})
+
+	return &f
+}
+
+// Compiler turns the resolved AST into bytecode. It is given the local variables by the resolver.
+type Compiler struct {
+	errorFormatter loxerror.ErrorFormatter
+	hadError       bool
+	current        *functionCompiler
+	locals         map[ast.Expression]int
+	// position is the token of the node being compiled, the emitted bytes are located with it
+	position lexer.Token
+}
+
+func NewCompiler(errorFormatter loxerror.ErrorFormatter) *Compiler {
+	return &Compiler{
+		errorFormatter: errorFormatter,
+		hadError:       false,
+		current:        nil,
+		locals:         make(map[ast.Expression]int),
+		position:       lexer.Token{},
+	}
+}
+
+func (c *Compiler) Resolve(e ast.Expression, depth int) {
+	c.locals[e] = depth
+}
+
+// Compile returns the function running the program, or nil if it can't be compiled
+func (c *Compiler) Compile(statements []ast.Statement) *Function {
+	c.current = newFunctionCompiler(nil, newFunction(""), scriptFunc)
+	for _, statement := range statements {
+		statement.Accept(c)
+	}
+	c.emitReturn()
+
+	if c.hadError {
+		return nil
+	}
+
+	return c.current.function
+}
+
+func (c *Compiler) VisitAssignmentExpression(assignmentExpression *ast.AssignmentExpression) {
+	c.compileExpression(assignmentExpression.Value)
+	c.setVariable(assignmentExpression.Name, assignmentExpression)
+}
+
+func (c *Compiler) VisitBinaryExpression(binaryExpression *ast.BinaryExpression) {
+	c.compileExpression(binaryExpression.LHS)
+	c.compileExpression(binaryExpression.RHS)
+
+	c.position = binaryExpression.Operator
+	switch binaryExpression.Operator.Type {
+	case lexer.Plus:
+		c.emit(byte(OpAdd))
+	case lexer.Dash:
+		c.emit(byte(OpSubtract))
+	case lexer.Star:
+		c.emit(byte(OpMultiply))
+	case lexer.Slash:
+		c.emit(byte(OpDivide))
+	case lexer.Greater:
+		c.emit(byte(OpGreater))
+	case lexer.GreaterEqual:
+		c.emit(byte(OpGreaterEqual))
+	case lexer.Less:
+		c.emit(byte(OpLess))
+	case lexer.LessEqual:
+		c.emit(byte(OpLessEqual))
+	case lexer.EqualEqual:
+		c.emit(byte(OpEqual))
+	case lexer.BangEqual:
+		c.emit(byte(OpEqual), byte(OpNot))
+	}
+}
+
+func (c *Compiler) VisitCallExpression(callExpression *ast.CallExpression) {
+	switch callee := callExpression.Callee.(type) {
+	case *ast.GetExpression:
+		// A method is called without creating the bound method
+		c.compileExpression(callee.Object)
+		c.compileArguments(callExpression.Args)
+		name := c.identifierConstant(callee.Name)
+		c.position = callExpression.Position
+		c.emit(byte(OpInvoke))
+		// The property name is located on its own so that a missing property is reported where it's written
+		c.emitAt(callee.Name, name)
+		c.emit(byte(len(callExpression.Args)))
+	case *ast.SuperExpression:
+		c.namedVariable(thisToken(callee.Keyword), callee)
+		c.compileArguments(callExpression.Args)
+		c.namedVariable(callee.Keyword, callee)
+		name := c.identifierConstant(callee.Method)
+		c.position = callExpression.Position
+		c.emit(byte(OpSuperInvoke))
+		c.emitAt(callee.Method, name)
+		c.emit(byte(len(callExpression.Args)))
+	default:
+		c.compileExpression(callExpression.Callee)
+		c.compileArguments(callExpression.Args)
+		c.position = callExpression.Position
+		c.emit(byte(OpCall), byte(len(callExpression.Args)))
+	}
+}
+
+func (c *Compiler) VisitFunctionExpression(functionExpression *ast.FunctionExpression) {
+	c.function(functionExpression.Function, funcFunc, "")
+}
+
+func (c *Compiler) VisitGetExpression(getExpression *ast.GetExpression) {
+	c.compileExpression(getExpression.Object)
+	name := c.identifierConstant(getExpression.Name)
+	c.emit(byte(OpGetProperty), name)
+}
+
+func (c *Compiler) VisitGroupingExpression(groupingExpression *ast.GroupingExpression) {
+	c.compileExpression(groupingExpression.Expr)
+}
+
+func (c *Compiler) VisitIndexGetExpression(indexGetExpression *ast.IndexGetExpression) {
+	c.compileExpression(indexGetExpression.Object)
+	c.compileExpression(indexGetExpression.Index)
+	c.position = indexGetExpression.Bracket
+	c.emit(byte(OpGetIndex))
+}
+
+func (c *Compiler) VisitIndexSetExpression(indexSetExpression *ast.IndexSetExpression) {
+	c.compileExpression(indexSetExpression.Object)
+	c.compileExpression(indexSetExpression.Index)
+	c.compileExpression(indexSetExpression.Value)
+	c.position = indexSetExpression.Bracket
+	c.emit(byte(OpSetIndex))
+}
+
+func (c *Compiler) VisitListExpression(listExpression *ast.ListExpression) {
+	for _, element := range listExpression.Elements {
+		c.compileExpression(element)
+	}
+
+	c.position = listExpression.Bracket
+	c.emitLiteral(OpList, len(listExpression.Elements), "list")
+}
+
+func (c *Compiler) VisitMapExpression(mapExpression *ast.MapExpression) {
+	for index, key := range mapExpression.Keys {
+		c.compileExpression(key)
+		c.compileExpression(mapExpression.Values[index])
+	}
+
+	c.position = mapExpression.Brace
+	c.emitLiteral(OpMap, len(mapExpression.Keys), "map")
+}
+
+func (c *Compiler) VisitLiteralExpression(literalExpression *ast.LiteralExpression) {
+	c.position = literalExpression.Token
+	switch value := literalExpression.LoxValue().(type) {
+	case *ast.NilValue:
+		c.emit(byte(OpNil))
+	case *ast.BooleanValue:
+		if value.Value {
+			c.emit(byte(OpTrue))
+		} else {
+			c.emit(byte(OpFalse))
+		}
+	default:
+		c.emit(byte(OpConstant), c.makeConstant(value))
+	}
+}
+
+func (c *Compiler) VisitLogicalExpression(logicalExpression *ast.LogicalExpression) {
+	c.compileExpression(logicalExpression.LHS)
+
+	c.position = logicalExpression.Operator
+	if logicalExpression.Operator.Type == lexer.And {
+		endJump := c.emitJump(OpJumpIfFalse)
+		c.emit(byte(OpPop))
+		c.compileExpression(logicalExpression.RHS)
+		c.patchJump(endJump)
+		return
+	}
+
+	elseJump := c.emitJump(OpJumpIfFalse)
+	endJump := c.emitJump(OpJump)
+	c.patchJump(elseJump)
+	c.emit(byte(OpPop))
+	c.compileExpression(logicalExpression.RHS)
+	c.patchJump(endJump)
+}
+
+func (c *Compiler) VisitSetExpression(setExpression *ast.SetExpression) {
+	c.compileExpression(setExpression.Object)
+	c.compileExpression(setExpression.Value)
+	name := c.identifierConstant(setExpression.Name)
+	c.emit(byte(OpSetProperty), name)
+}
+
+func (c *Compiler) VisitStringifyExpression(stringifyExpression *ast.StringifyExpression) {
+	c.compileExpression(stringifyExpression.Value)
+	c.position = stringifyExpression.Position
+	c.emit(byte(OpStringify))
+}
+
+func (c *Compiler) VisitSuperExpression(superExpression *ast.SuperExpression) {
+	c.namedVariable(thisToken(superExpression.Keyword), superExpression)
+	c.namedVariable(superExpression.Keyword, superExpression)
+	name := c.identifierConstant(superExpression.Method)
+	c.emit(byte(OpGetSuper), name)
+}
+
+func (c *Compiler) VisitThisExpression(thisExpression *ast.ThisExpression) {
+	c.namedVariable(thisExpression.Keyword, thisExpression)
+}
+
+func (c *Compiler) VisitUnaryExpression(unaryExpression *ast.UnaryExpression) {
+	c.compileExpression(unaryExpression.RHS)
+
+	c.position = unaryExpression.Operator
+	switch unaryExpression.Operator.Type {
+	case lexer.Bang:
+		c.emit(byte(OpNot))
+	case lexer.Dash:
+		c.emit(byte(OpNegate))
+	}
+}
+
+func (c *Compiler) VisitVariableExpression(variableExpression *ast.VariableExpression) {
+	c.namedVariable(variableExpression.Name, variableExpression)
+}
+
+func (c *Compiler) VisitBlockStatement(blockStatement *ast.BlockStatement) {
+	c.block(blockStatement.Statements)
+}
+
+func (c *Compiler) VisitBreakStatement(breakStatement *ast.BreakStatement) {
+	if target := c.loopJump(breakStatement.Keyword, breakStatement.Label); target != nil {
+		target.breakJumps = append(target.breakJumps, c.emitJump(OpJump))
+	}
+}
+
+func (c *Compiler) VisitClassStatement(classStatement *ast.ClassStatement) {
+	name := c.identifierConstant(classStatement.Name)
+	isLocal := c.current.scopeDepth > 0
+	if isLocal {
+		c.declareVariable(classStatement.Name)
+	}
+	c.emit(byte(OpClass), name)
+	c.defineVariable(name)
+
+	if classStatement.Superclass != nil {
+		c.compileExpression(classStatement.Superclass)
+
+		// 'super' is a local variable of the scope enclosing the methods
+		c.beginScope()
+		c.addLocal("super")
+		c.loadVariable(classStatement.Name, isLocal)
+		c.position = classStatement.Superclass.Name
+		c.emit(byte(OpInherit))
+	}
+
+	c.loadVariable(classStatement.Name, isLocal)
+	for _, method := range classStatement.Methods {
+		kind := methodFunc
+		if method.Name.Lexeme == "init" {
+			kind = initializerFunc
+		}
+		c.function(method, kind, classStatement.Name.Lexeme)
+		c.emit(byte(OpMethod), c.identifierConstant(method.Name))
+	}
+	for _, method := range classStatement.ClassMethods {
+		c.function(method, methodFunc, classStatement.Name.Lexeme)
+		c.emit(byte(OpClassMethod), c.identifierConstant(method.Name))
+	}
+	c.emit(byte(OpPop))
+
+	if classStatement.Superclass != nil {
+		c.endScope()
+	}
+
+	// The initializers of the class fields may use the class
+	for _, field := range classStatement.ClassFields {
+		c.loadVariable(classStatement.Name, isLocal)
+		if field.Initializer != nil {
+			c.compileExpression(field.Initializer)
+		} else {
+			c.emit(byte(OpNil))
+		}
+		c.position = field.Name
+		c.emit(byte(OpClassField), c.identifierConstant(field.Name))
+	}
+}
+
+func (c *Compiler) VisitContinueStatement(continueStatement *ast.ContinueStatement) {
+	if target := c.loopJump(continueStatement.Keyword, continueStatement.Label); target != nil {
+		target.continueJumps = append(target.continueJumps, c.emitJump(OpJump))
+	}
+}
+
+func (c *Compiler) VisitExpressionStatement(expressionStatement *ast.ExpressionStatement) {
+	c.compileExpression(expressionStatement.Expression)
+	c.emit(byte(OpPop))
+}
+
+func (c *Compiler) VisitForInStatement(forInStatement *ast.ForInStatement) {
+	// The iterator and the loop variable are locals of a scope enclosing the body
+	c.beginScope()
+	c.compileExpression(forInStatement.Iterable)
+	c.position = forInStatement.Keyword
+	c.emit(byte(OpIterator))
+	c.addLocal("")
+	c.emit(byte(OpNil))
+	c.declareVariable(forInStatement.Name)
+
+	loopStart := len(c.chunk().Code)
+	c.position = forInStatement.Keyword
+	exitJump := c.emitJump(OpForIter)
+
+	label := ""
+	if forInStatement.Label != nil {
+		label = forInStatement.Label.Lexeme
+	}
+	compiledLoop := &loop{label: label, scopeDepth: c.current.scopeDepth, breakJumps: nil, continueJumps: nil}
+	c.current.loops = append(c.current.loops, compiledLoop)
+	forInStatement.Body.Accept(c)
+	c.current.loops = c.current.loops[:len(c.current.loops)-1]
+
+	for _, continueJump := range compiledLoop.continueJumps {
+		c.patchJump(continueJump)
+	}
+
+	c.position = forInStatement.End
+	if c.emitLoop(loopStart) {
+		c.patchJump(exitJump)
+	}
+	for _, breakJump := range compiledLoop.breakJumps {
+		c.patchJump(breakJump)
+	}
+	c.endScope()
+}
+
+func (c *Compiler) VisitFunctionStatement(functionStatement *ast.FunctionStatement) {
+	name := c.declareVariable(functionStatement.Name)
+	c.function(functionStatement, funcFunc, "")
+	c.defineVariable(name)
+}
+
+func (c *Compiler) VisitIfStatement(ifStatement *ast.IfStatement) {
+	c.compileExpression(ifStatement.Condition)
+
+	thenJump := c.emitJump(OpJumpIfFalse)
+	c.emit(byte(OpPop))
+	ifStatement.ThenCode.Accept(c)
+	elseJump := c.emitJump(OpJump)
+
+	c.patchJump(thenJump)
+	c.emit(byte(OpPop))
+	if ifStatement.ElseCode != nil {
+		ifStatement.ElseCode.Accept(c)
+	}
+	c.patchJump(elseJump)
+}
+
+func (c *Compiler) VisitImportStatement(importStatement *ast.ImportStatement) {
+	if importStatement.Alias != nil {
+		name := c.declareVariable(*importStatement.Alias)
+		c.emitImport(importStatement.Path)
+		c.defineVariable(name)
+		return
+	}
+
+	// The module is evaluated once, the next imports get it from the cache
+	for _, importedName := range importStatement.Names {
+		name := c.declareVariable(importedName)
+		c.emitImport(importStatement.Path)
+		c.emit(byte(OpGetProperty), c.identifierConstant(importedName))
+		c.defineVariable(name)
+	}
+}
+
+func (c *Compiler) VisitPrintStatement(printStatement *ast.PrintStatement) {
+	c.compileExpression(printStatement.Expression)
+	c.position = printStatement.Keyword
+	c.emit(byte(OpPrint))
+}
+
+func (c *Compiler) VisitReturnStatement(returnStatement *ast.ReturnStatement) {
+	if returnStatement.Value == nil {
+		c.position = returnStatement.Keyword
+		c.emitImplicitValue()
+	} else {
+		c.compileExpression(returnStatement.Value)
+		c.position = returnStatement.Keyword
+	}
+
+	if len(c.current.tries) == 0 {
+		c.emit(byte(OpReturn))
+		return
+	}
+
+	// The value is kept in a local while the finally clauses run
+	c.beginScope()
+	c.addLocal("")
+	slot := byte(len(c.current.locals) - 1)
+	c.exitTries(len(c.current.tries))
+	c.position = returnStatement.Keyword
+	c.emit(byte(OpGetLocal), slot, byte(OpReturn))
+	c.endScope()
+}
+
+func (c *Compiler) VisitThrowStatement(throwStatement *ast.ThrowStatement) {
+	c.compileExpression(throwStatement.Value)
+	c.position = throwStatement.Keyword
+	c.emit(byte(OpThrow))
+}
+
+// VisitTryStatement compiles the body protected by a handler jumping to the catch clause, or to a copy of the
+// finally clause throwing the error again. The finally clause is also copied after the body and the catch clause.
+func (c *Compiler) VisitTryStatement(tryStatement *ast.TryStatement) {
+	c.position = tryStatement.Keyword
+	instruction := OpTryFinally
+	if tryStatement.Catch != nil {
+		instruction = OpTry
+	}
+	handlerJump := c.emitJump(instruction)
+	c.enterTry(tryStatement.Finally)
+	c.block(tryStatement.Body)
+	c.exitTry()
+	c.position = tryStatement.Keyword
+	c.emit(byte(OpEndTry))
+
+	if tryStatement.Catch != nil {
+		endJump := c.emitJump(OpJump)
+		c.patchJump(handlerJump)
+		if tryStatement.Finally != nil {
+			// The errors of the catch clause go through the finally clause
+			handlerJump = c.emitJump(OpTryFinally)
+			c.enterTry(tryStatement.Finally)
+		}
+
+		// The caught value is pushed by the VM
+		c.beginScope()
+		c.position = tryStatement.Catch.Name
+		c.addLocal(tryStatement.Catch.Name.Lexeme)
+		for _, statement := range tryStatement.Catch.Body {
+			statement.Accept(c)
+		}
+		c.endScope()
+
+		if tryStatement.Finally != nil {
+			c.exitTry()
+			c.position = tryStatement.Keyword
+			c.emit(byte(OpEndTry))
+		}
+		c.patchJump(endJump)
+	}
+
+	if tryStatement.Finally == nil {
+		return
+	}
+
+	c.block(tryStatement.Finally.Statements)
+	c.position = tryStatement.Keyword
+	endJump := c.emitJump(OpJump)
+
+	// The pending error is pushed by the VM, above the caught value if it's raised in the catch clause
+	c.patchJump(handlerJump)
+	c.beginScope()
+	if tryStatement.Catch != nil {
+		c.addLocal("")
+	}
+	c.addLocal("")
+	slot := byte(len(c.current.locals) - 1)
+	c.block(tryStatement.Finally.Statements)
+	c.position = tryStatement.Keyword
+	c.emit(byte(OpGetLocal), slot, byte(OpThrow))
+	c.endScope()
+	c.patchJump(endJump)
+}
+
+func (c *Compiler) VisitVariableStatement(variableStatement *ast.VariableStatement) {
+	name := c.declareVariable(variableStatement.Name)
+
+	if variableStatement.Initializer != nil {
+		c.compileExpression(variableStatement.Initializer)
+	} else {
+		c.position = variableStatement.Name
+		c.emit(byte(OpNil))
+	}
+
+	c.defineVariable(name)
+}
+
+func (c *Compiler) VisitWhileStatement(whileStatement *ast.WhileStatement) {
+	loopStart := len(c.chunk().Code)
+	c.compileExpression(whileStatement.Condition)
+
+	c.position = whileStatement.Keyword
+	exitJump := c.emitJump(OpJumpIfFalse)
+	c.emit(byte(OpPop))
+
+	label := ""
+	if whileStatement.Label != nil {
+		label = whileStatement.Label.Lexeme
+	}
+	compiledLoop := &loop{label: label, scopeDepth: c.current.scopeDepth, breakJumps: nil, continueJumps: nil}
+	c.current.loops = append(c.current.loops, compiledLoop)
+	whileStatement.Body.Accept(c)
+	c.current.loops = c.current.loops[:len(c.current.loops)-1]
+
+	for _, continueJump := range compiledLoop.continueJumps {
+		c.patchJump(continueJump)
+	}
+	if whileStatement.Increment != nil {
+		c.compileExpression(whileStatement.Increment)
+		c.emit(byte(OpPop))
+	}
+
+	c.position = whileStatement.End
+	// The exit jump is longer than the loop, the error is reported once
+	if c.emitLoop(loopStart) {
+		c.patchJump(exitJump)
+	}
+	c.emit(byte(OpPop))
+
+	// The condition is already popped when the body breaks
+	for _, breakJump := range compiledLoop.breakJumps {
+		c.patchJump(breakJump)
+	}
+}
+
+func (c *Compiler) block(statements []ast.Statement) {
+	c.beginScope()
+	for _, statement := range statements {
+		statement.Accept(c)
+	}
+	c.endScope()
+}
+
+func (c *Compiler) compileExpression(expression ast.Expression) {
+	expression.Accept(c)
+}
+
+func (c *Compiler) compileArguments(arguments []ast.Expression) {
+	for _, argument := range arguments {
+		c.compileExpression(argument)
+	}
+}
+
+// function compiles the declaration in a new function compiler and emits the closure creation
+func (c *Compiler) function(declaration *ast.FunctionStatement, kind functionType, className string) {
+	function := newFunction(declaration.Name.Lexeme)
+	if declaration.IsAnonymous() {
+		function.name = ast.AnonymousFunctionName
+		function.anonymous = true
+	}
+	function.isGetter = declaration.IsGetter
+	function.className = className
+	function.arity = len(declaration.Parameters)
+
+	c.current = newFunctionCompiler(c.current, function, kind)
+	c.beginScope()
+	for _, parameter := range declaration.Parameters {
+		c.declareVariable(parameter)
+	}
+	for _, statement := range declaration.Body {
+		statement.Accept(c)
+	}
+	c.emitReturn()
+
+	compiled := c.current
+	c.current = compiled.enclosing
+	function.upvalueCount = len(compiled.upvalues)
+
+	c.position = declaration.Name
+	c.emit(byte(OpClosure), c.makeConstant(function))
+	for _, upvalue := range compiled.upvalues {
+		isLocal := byte(0)
+		if upvalue.isLocal {
+			isLocal = 1
+		}
+		c.emit(isLocal, upvalue.index)
+	}
+}
+
+// namedVariable loads a variable, it's local if the resolver found it in a scope
+func (c *Compiler) namedVariable(name lexer.Token, expression ast.Expression) {
+	_, isLocal := c.locals[expression]
+	c.loadVariable(name, isLocal)
+}
+
+func (c *Compiler) loadVariable(name lexer.Token, isLocal bool) {
+	getOp, _, operand := c.variableOperand(name, isLocal)
+	c.position = name
+	c.emit(byte(getOp), operand)
+}
+
+func (c *Compiler) setVariable(name lexer.Token, expression ast.Expression) {
+	_, isLocal := c.locals[expression]
+	_, setOp, operand := c.variableOperand(name, isLocal)
+	c.position = name
+	c.emit(byte(setOp), operand)
+}
+
+// variableOperand returns the instructions reading and writing the variable and their operand
+func (c *Compiler) variableOperand(name lexer.Token, isLocal bool) (OpCode, OpCode, byte) {
+	c.position = name
+	if isLocal {
+		if slot, ok := c.current.resolveLocal(name.Lexeme); ok {
+			return OpGetLocal, OpSetLocal, slot
+		}
+		if index, ok := c.resolveUpvalue(c.current, name.Lexeme); ok {
+			return OpGetUpvalue, OpSetUpvalue, index
+		}
+	}
+
+	return OpGetGlobal, OpSetGlobal, c.identifierConstant(name)
+}
+
+func (f *functionCompiler) resolveLocal(name string) (byte, bool) {
+	for index := len(f.locals) - 1; index >= 0; index-- {
+		if f.locals[index].name == name {
+			return byte(index), true
+		}
+	}
+
+	return 0, false
+}
+
+func (c *Compiler) resolveUpvalue(f *functionCompiler, name string) (byte, bool) {
+	if f.enclosing == nil {
+		return 0, false
+	}
+
+	if slot, ok := f.enclosing.resolveLocal(name); ok {
+		f.enclosing.locals[slot].isCaptured = true
+		return c.addUpvalue(f, slot, true), true
+	}
+
+	if index, ok := c.resolveUpvalue(f.enclosing, name); ok {
+		return c.addUpvalue(f, index, false), true
+	}
+
+	return 0, false
+}
+
+func (c *Compiler) addUpvalue(f *functionCompiler, index byte, isLocal bool) byte {
+	for i, upvalue := range f.upvalues {
+		if upvalue.index == index && upvalue.isLocal == isLocal {
+			return byte(i)
+		}
+	}
+
+	if len(f.upvalues) == maxUpvalues {
+		c.error(NewTooManyUpvalues(c.position.Span))
+		return 0
+	}
+	f.upvalues = append(f.upvalues, upvalueReference{index: index, isLocal: isLocal})
+
+	return byte(len(f.upvalues) - 1)
+}
+
+// declareVariable adds a local variable to the current scope.
+// The global variables are defined at runtime, their name constant is returned.
+func (c *Compiler) declareVariable(name lexer.Token) byte {
+	if c.current.scopeDepth == 0 {
+		return c.identifierConstant(name)
+	}
+
+	c.position = name
+	c.addLocal(name.Lexeme)
+
+	return 0
+}
+
+func (c *Compiler) addLocal(name string) {
+	if len(c.current.locals) == maxLocals {
+		c.error(NewTooManyLocals(c.position.Span))
+		return
+	}
+
+	// The resolver already rejects the variables read in their own initializer
+	c.current.locals = append(c.current.locals, local{name: name, depth: c.current.scopeDepth, isCaptured: false})
+}
+
+// defineVariable emits the definition of a global variable, a local variable is the value on top of the stack
+func (c *Compiler) defineVariable(name byte) {
+	if c.current.scopeDepth > 0 {
+		return
+	}
+
+	c.emit(byte(OpDefineGlobal), name)
+}
+
+func (c *Compiler) beginScope() {
+	c.current.scopeDepth++
+}
+
+func (c *Compiler) endScope() {
+	c.current.scopeDepth--
+
+	locals := c.current.locals
+	for len(locals) > 0 && locals[len(locals)-1].depth > c.current.scopeDepth {
+		if locals[len(locals)-1].isCaptured {
+			c.emit(byte(OpCloseUpvalue))
+		} else {
+			c.emit(byte(OpPop))
+		}
+		locals = locals[:len(locals)-1]
+	}
+	c.current.locals = locals
+}
+
+func (c *Compiler) identifierConstant(name lexer.Token) byte {
+	c.position = name
+	return c.makeConstant(ast.NewStringValue(name.Lexeme))
+}
+
+func (c *Compiler) makeConstant(value ast.LoxValue) byte {
+	index := c.chunk().addConstant(value)
+	if index >= maxConstants {
+		c.error(NewTooManyConstants(c.position.Span))
+		return 0
+	}
+
+	return byte(index)
+}
+
+func (c *Compiler) chunk() *Chunk {
+	return &c.current.function.chunk
+}
+
+func (c *Compiler) emit(bytes ...byte) {
+	for _, b := range bytes {
+		c.chunk().write(b, c.position.Span)
+	}
+}
+
+// emitAt emits a byte located at the given token
+func (c *Compiler) emitAt(position lexer.Token, b byte) {
+	c.chunk().write(b, position.Span)
+}
+
+// loopJump pops the locals of the loop targeted by a break or a continue statement and returns the loop
+func (c *Compiler) loopJump(keyword lexer.Token, label *lexer.Token) *loop {
+	c.position = keyword
+	loops := c.current.loops
+	for index := len(loops) - 1; index >= 0; index-- {
+		if label != nil && loops[index].label != label.Lexeme {
+			continue
+		}
+
+		tries := c.current.tries
+		count := 0
+		for count < len(tries) && tries[len(tries)-1-count].loopCount > index {
+			count++
+		}
+		c.exitTries(count)
+		c.position = keyword
+
+		// The locals stay declared for the rest of the body, they're only removed from the stack.
+		// A closure compiled after the jump may capture them in an inner loop, so their upvalues are always closed.
+		locals := c.current.locals
+		for local := len(locals) - 1; local >= 0 && locals[local].depth > loops[index].scopeDepth; local-- {
+			c.emit(byte(OpCloseUpvalue))
+		}
+
+		return loops[index]
+	}
+
+	// Reported by the resolver
+	return nil
+}
+
+// emitLiteral emits the instruction building a list or a map from the elements on the stack
+func (c *Compiler) emitLiteral(instruction OpCode, count int, literal string) {
+	if count > maxElements {
+		c.error(NewTooManyElements(c.position.Span, literal))
+	}
+	c.emit(byte(instruction), byte(count>>8), byte(count))
+}
+
+// emitReturn emits the implicit return at the end of a function
+func (c *Compiler) emitReturn() {
+	c.emitImplicitValue()
+	c.emit(byte(OpReturn))
+}
+
+// emitImplicitValue emits the value returned without a return value, an initializer returns 'this'
+func (c *Compiler) emitImplicitValue() {
+	if c.current.kind == initializerFunc {
+		c.emit(byte(OpGetLocal), 0)
+	} else {
+		c.emit(byte(OpNil))
+	}
+}
+
+func (c *Compiler) enterTry(finally *ast.BlockStatement) {
+	c.current.tries = append(c.current.tries, &tryBlock{loopCount: len(c.current.loops), finally: finally})
+}
+
+func (c *Compiler) exitTry() {
+	c.current.tries = c.current.tries[:len(c.current.tries)-1]
+}
+
+// exitTries emits the code leaving the innermost try statements, a return, a break or a continue statement
+// pops their handlers and runs their finally clauses
+func (c *Compiler) exitTries(count int) {
+	tries := c.current.tries
+	for index := len(tries) - 1; index >= len(tries)-count; index-- {
+		c.emit(byte(OpEndTry))
+		if tries[index].finally != nil {
+			// The finally clause is only protected by the enclosing try statements
+			c.current.tries = tries[:index]
+			c.block(tries[index].finally.Statements)
+		}
+	}
+	c.current.tries = tries
+}
+
+// emitJump emits a jump with a placeholder offset and returns the offset position to patch
+// emitImport emits the instruction pushing the module at the given path
+func (c *Compiler) emitImport(path lexer.Token) {
+	c.position = path
+	c.emit(byte(OpImport), c.makeConstant(ast.NewStringValue(path.Literal.(*lexer.StringLiteral).Value)))
+}
+
+func (c *Compiler) emitJump(instruction OpCode) int {
+	c.emit(byte(instruction), 0xff, 0xff)
+	return len(c.chunk().Code) - 2
+}
+
+func (c *Compiler) patchJump(offset int) {
+	// -2 to skip the offset itself
+	jump := len(c.chunk().Code) - offset - 2
+	if jump > maxJump {
+		c.error(NewJumpTooLarge(c.position.Span))
+	}
+
+	c.chunk().Code[offset] = byte(jump >> 8)
+	c.chunk().Code[offset+1] = byte(jump)
+}
+
+// emitLoop emits a jump back to the start of the loop, it returns false if the loop is too large
+func (c *Compiler) emitLoop(loopStart int) bool {
+	c.emit(byte(OpLoop))
+
+	// +2 to skip the offset of the instruction
+	offset := len(c.chunk().Code) - loopStart + 2
+	if offset > maxJump {
+		c.error(NewLoopTooLarge(c.position.Span))
+		return false
+	}
+	c.emit(byte(offset>>8), byte(offset))
+
+	return true
+}
+
+// thisToken returns a 'this' located at the 'super' keyword, the instance is loaded along with the superclass
+func thisToken(superKeyword lexer.Token) lexer.Token {
+	return *lexer.NewToken(lexer.This, "this", nil, superKeyword.Span)
+}
+
+func (c *Compiler) error(err loxerror.LoxError) {
+	c.hadError = true
+	c.errorFormatter.PushError(err)
+}
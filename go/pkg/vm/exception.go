@@ -0,0 +1,105 @@
+package vm
+
+import (
+	"github.com/fpotier/lox/go/pkg/ast"
+	"github.com/fpotier/lox/go/pkg/loxerror"
+	"github.com/fpotier/lox/go/pkg/runtime"
+)
+
+// handler is the code run when an error is raised in the body of a try statement
+type handler struct {
+	// frameCount is the number of frames when the try statement started, the frames above are unwound
+	frameCount int
+	ip         int
+	stackDepth int
+	// finally is set for the handlers running a finally clause, they get the pending error instead of the caught value
+	finally bool
+}
+
+// pendingError is an error propagating through a finally clause, it's thrown again at the end of the clause
+type pendingError struct {
+	err loxerror.LoxError
+}
+
+func (p *pendingError) Kind() ast.Kind             { return ast.Nil }
+func (p *pendingError) IsTruthy() bool             { return true }
+func (p *pendingError) String() string             { return "<error>" }
+func (p *pendingError) Equals(v ast.LoxValue) bool { return p == v }
+
+// throw raises the value of a throw statement, the instances of Error get their kind and line
+func (vm *VM) throw(value ast.LoxValue, span loxerror.Span) {
+	if pending, ok := value.(*pendingError); ok {
+		panic(pending.err)
+	}
+
+	description := value.String()
+	if instance, ok := value.(*Instance); ok && instance.class.isSubclassOf(vm.errorClass) {
+		if isNil(instance.fields["kind"]) {
+			instance.fields["kind"] = ast.NewStringValue(instance.class.name)
+		}
+		if isNil(instance.fields["line"]) {
+			instance.fields["line"] = ast.NewNumberValue(float64(span.Start.Line))
+		}
+		message, ok := instance.fields["message"]
+		if !ok {
+			message = ast.NewNilValue()
+		}
+		description = runtime.DescribeError(instance.fields["kind"], message)
+	}
+
+	panic(runtime.NewThrow(span, value, description))
+}
+
+// catch unwinds the stack to the innermost handler and resumes the execution there.
+// It returns false if the error can't be caught by the frames run down to exitDepth.
+func (vm *VM) catch(err loxerror.LoxError, exitDepth int) bool {
+	if len(vm.handlers) == 0 || !runtime.IsCatchable(err) {
+		return false
+	}
+	handler := vm.handlers[len(vm.handlers)-1]
+	// The handler belongs to a frame of an enclosing run, the error goes through the native function calling this one
+	if handler.frameCount <= exitDepth {
+		return false
+	}
+
+	if _, ok := err.(loxerror.TracedError); !ok {
+		err = loxerror.WithStackTrace(err, vm.stackTrace())
+	}
+	vm.handlers = vm.handlers[:len(vm.handlers)-1]
+	vm.frames = vm.frames[:handler.frameCount]
+	vm.closeUpvalues(handler.stackDepth)
+	vm.stack = vm.stack[:handler.stackDepth]
+
+	if handler.finally {
+		vm.push(&pendingError{err: err})
+	} else {
+		vm.push(vm.caughtValue(err))
+	}
+	vm.frames[len(vm.frames)-1].ip = handler.ip
+
+	return true
+}
+
+// caughtValue returns the value bound by a catch clause, a built-in error becomes an instance of its prelude class
+func (vm *VM) caughtValue(err loxerror.LoxError) ast.LoxValue {
+	err = loxerror.Unwrap(err)
+	if thrown, ok := err.(*runtime.Throw); ok {
+		return thrown.Value()
+	}
+
+	kind := runtime.ErrorKind(err)
+	class, ok := vm.errorKinds[kind]
+	if !ok {
+		class = vm.runtimeErrorClass
+	}
+	instance := newInstance(class)
+	instance.fields["message"] = ast.NewStringValue(err.Message())
+	instance.fields["kind"] = ast.NewStringValue(kind)
+	instance.fields["line"] = ast.NewNumberValue(float64(err.Line()))
+
+	return instance
+}
+
+func isNil(value ast.LoxValue) bool {
+	return value == nil || value.Kind() == ast.Nil
+}
@@ -0,0 +1,165 @@
+package vm
+
+import (
+	"github.com/fpotier/lox/go/pkg/ast"
+)
+
+// Function is a compiled function, it becomes callable once wrapped in a closure
+type Function struct {
+	name string
+	// anonymous is set for the functions defined by an expression, their name is only used in the errors
+	anonymous bool
+	// isGetter is set for the methods declared without parameters, they are called when the property is read
+	isGetter     bool
+	className    string
+	arity        int
+	upvalueCount int
+	chunk        Chunk
+}
+
+func newFunction(name string) *Function {
+	return &Function{
+		name:         name,
+		anonymous:    false,
+		isGetter:     false,
+		className:    "",
+		arity:        0,
+		upvalueCount: 0,
+		chunk:        Chunk{},
+	}
+}
+
+func (f *Function) Kind() ast.Kind { return ast.Function }
+func (f *Function) IsTruthy() bool { return true }
+func (f *Function) String() string {
+	if len(f.name) == 0 {
+		return "<script>"
+	}
+	if f.anonymous {
+		return "<fn>"
+	}
+
+	return "<fn " + f.name + ">"
+}
+func (f *Function) Equals(v ast.LoxValue) bool { return f == v }
+
+// Chunk returns the bytecode of the function
+func (f *Function) Chunk() *Chunk { return &f.chunk }
+
+// qualifiedName returns the name displayed in stack traces, methods are qualified by their class
+func (f *Function) qualifiedName() string {
+	if len(f.className) == 0 {
+		return f.name
+	}
+
+	return f.className + "::" + f.name
+}
+
+type Closure struct {
+	function *Function
+	upvalues []*upvalue
+	// globals are the global variables of the script or module where the closure was created
+	globals map[string]ast.LoxValue
+}
+
+func newClosure(function *Function, globals map[string]ast.LoxValue) *Closure {
+	return &Closure{function: function, upvalues: make([]*upvalue, function.upvalueCount), globals: globals}
+}
+
+func (c *Closure) Kind() ast.Kind             { return ast.Function }
+func (c *Closure) IsTruthy() bool             { return true }
+func (c *Closure) String() string             { return c.function.String() }
+func (c *Closure) Equals(v ast.LoxValue) bool { return c == v }
+
+// upvalue is a variable captured by a closure, it lives on the stack until it goes out of scope
+type upvalue struct {
+	// slot is the index of the variable on the stack while the upvalue is open
+	slot   int
+	open   bool
+	closed ast.LoxValue
+	// next is the open upvalue below this one on the stack
+	next *upvalue
+}
+
+type Class struct {
+	name string
+	// superclass is nil for the classes without a superclass, its methods are copied in methods
+	superclass *Class
+	methods    map[string]*Closure
+	// classMethods are copied from the superclass like methods, fields are looked up in the superclasses
+	classMethods map[string]*Closure
+	fields       map[string]ast.LoxValue
+}
+
+func newClass(name string) *Class {
+	return &Class{
+		name:         name,
+		superclass:   nil,
+		methods:      make(map[string]*Closure),
+		classMethods: make(map[string]*Closure),
+		fields:       make(map[string]ast.LoxValue),
+	}
+}
+
+func (c *Class) Kind() ast.Kind             { return ast.Class }
+func (c *Class) IsTruthy() bool             { return true }
+func (c *Class) String() string             { return c.name }
+func (c *Class) Equals(v ast.LoxValue) bool { return c == v }
+
+// field returns a field of the class or of one of its superclasses
+func (c *Class) field(name string) (ast.LoxValue, bool) {
+	for current := c; current != nil; current = current.superclass {
+		if value, ok := current.fields[name]; ok {
+			return value, true
+		}
+	}
+
+	return nil, false
+}
+
+// hasClassMembers tells if the class or one of its superclasses has class methods or fields
+func (c *Class) hasClassMembers() bool {
+	for current := c; current != nil; current = current.superclass {
+		if len(current.fields) > 0 || len(current.classMethods) > 0 {
+			return true
+		}
+	}
+
+	return false
+}
+
+// isSubclassOf tells if the class is the given class or inherits from it
+func (c *Class) isSubclassOf(class *Class) bool {
+	for current := c; current != nil; current = current.superclass {
+		if current == class {
+			return true
+		}
+	}
+
+	return false
+}
+
+type Instance struct {
+	class  *Class
+	fields map[string]ast.LoxValue
+}
+
+func newInstance(class *Class) *Instance {
+	return &Instance{class: class, fields: make(map[string]ast.LoxValue)}
+}
+
+func (i *Instance) Kind() ast.Kind             { return ast.Instance }
+func (i *Instance) IsTruthy() bool             { return true }
+func (i *Instance) String() string             { return i.class.name + " instance" }
+func (i *Instance) Equals(v ast.LoxValue) bool { return i == v }
+
+// BoundMethod is a method accessed on an instance, the instance becomes 'this' when it's called
+type BoundMethod struct {
+	receiver ast.LoxValue
+	method   *Closure
+}
+
+func (b *BoundMethod) Kind() ast.Kind             { return ast.Function }
+func (b *BoundMethod) IsTruthy() bool             { return true }
+func (b *BoundMethod) String() string             { return b.method.String() }
+func (b *BoundMethod) Equals(v ast.LoxValue) bool { return b == v }
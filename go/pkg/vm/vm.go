@@ -0,0 +1,970 @@
+// Package vm runs the programs compiled to bytecode on a stack machine
+package vm
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+
+	"github.com/fpotier/lox/go/pkg/ast"
+	"github.com/fpotier/lox/go/pkg/checker"
+	"github.com/fpotier/lox/go/pkg/lexer"
+	"github.com/fpotier/lox/go/pkg/loxerror"
+	"github.com/fpotier/lox/go/pkg/runtime"
+)
+
+// callFrame is a call in progress
+type callFrame struct {
+	// closure is nil for the native functions
+	closure *Closure
+	name    string
+	ip      int
+	// instruction is the index of the instruction being executed
+	instruction int
+	// base is the index on the stack of the first slot of the function
+	base     int
+	callSite loxerror.Span
+}
+
+type VM struct {
+	ErrorFormatter loxerror.ErrorFormatter
+	OutputStream   io.Writer
+	InputStream    io.Reader
+	// MaxCallDepth is the number of nested calls after which a StackOverflow is raised, 0 means no limit
+	MaxCallDepth int
+	// StepBudget is the number of instructions a run may execute, 0 means no limit
+	StepBudget int
+	steps      int
+	context    context.Context
+	stack      []ast.LoxValue
+	frames     []callFrame
+	// handlers are the try statements being executed, innermost last
+	handlers []handler
+	// globals are the global variables of the script, each module has its own
+	globals map[string]ast.LoxValue
+	// builtins are the native functions and prelude classes, they are defined in the globals of every module
+	builtins     map[string]ast.LoxValue
+	modules      *runtime.Modules
+	openUpvalues *upvalue
+	// errorClass and runtimeErrorClass are the classes of the prelude, the scripts may shadow their names
+	errorClass        *Class
+	runtimeErrorClass *Class
+	// errorKinds are the subclasses of RuntimeError of the prelude by name, the built-in errors are their instances
+	errorKinds map[string]*Class
+}
+
+func NewVM(outputStream io.Writer, errorFormatter loxerror.ErrorFormatter) *VM {
+	builtins := make(map[string]ast.LoxValue)
+	vm := VM{
+		ErrorFormatter:    errorFormatter,
+		OutputStream:      outputStream,
+		InputStream:       os.Stdin,
+		MaxCallDepth:      ast.Limits.MaxCallDepth,
+		StepBudget:        0,
+		steps:             0,
+		context:           context.Background(),
+		stack:             make([]ast.LoxValue, 0, maxLocals),
+		frames:            make([]callFrame, 0),
+		handlers:          make([]handler, 0),
+		globals:           make(map[string]ast.LoxValue),
+		builtins:          builtins,
+		modules:           runtime.NewModules(builtins),
+		openUpvalues:      nil,
+		errorClass:        nil,
+		runtimeErrorClass: nil,
+		errorKinds:        make(map[string]*Class),
+	}
+
+	for _, nativeFunction := range runtime.BuiltinNativeFunctions {
+		vm.globals[nativeFunction.Name()] = nativeFunction
+	}
+	vm.loadPrelude()
+	for name, value := range vm.globals {
+		vm.builtins[name] = value
+	}
+
+	return &vm
+}
+
+// loadPrelude runs the prelude and keeps its error classes
+func (vm *VM) loadPrelude() {
+	statements := runtime.PreludeStatements()
+	compiler := NewCompiler(vm.ErrorFormatter)
+	runtime.NewResolver(vm.ErrorFormatter, compiler).ResolveProgram(statements)
+	vm.Eval(compiler.Compile(statements))
+
+	vm.errorClass = vm.globals["Error"].(*Class)
+	vm.runtimeErrorClass = vm.globals["RuntimeError"].(*Class)
+	for name, value := range vm.globals {
+		if class, ok := value.(*Class); ok && class.superclass == vm.runtimeErrorClass {
+			vm.errorKinds[name] = class
+		}
+	}
+}
+
+func (vm *VM) Eval(function *Function) {
+	vm.EvalContext(context.Background(), function)
+}
+
+// EvalContext runs the compiled program until it completes, the context is done or the step budget is exhausted
+func (vm *VM) EvalContext(ctx context.Context, function *Function) {
+	vm.context = ctx
+	vm.steps = 0
+	defer func() { vm.context = context.Background() }()
+
+	defer func() {
+		if r := recover(); r != nil {
+			if err, ok := r.(loxerror.LoxError); ok {
+				// An error rethrown after a finally clause already has the stack trace of where it was raised
+				if _, ok := err.(loxerror.TracedError); !ok {
+					err = loxerror.WithStackTrace(err, vm.stackTrace())
+				}
+				for _, err := range loxerror.Split(err) {
+					vm.ErrorFormatter.PushError(err)
+				}
+				vm.stack = vm.stack[:0]
+				vm.frames = vm.frames[:0]
+				vm.handlers = vm.handlers[:0]
+				vm.openUpvalues = nil
+				return
+			}
+			panic(r)
+		}
+	}()
+
+	closure := newClosure(function, vm.globals)
+	vm.push(closure)
+	vm.frames = append(vm.frames, callFrame{
+		closure:     closure,
+		name:        "",
+		ip:          0,
+		instruction: 0,
+		base:        0,
+		callSite:    loxerror.Span{},
+	})
+	vm.run(0)
+	// The script returns nil
+	vm.pop()
+}
+
+// DefineNative registers a Go function as a global Lox function
+func (vm *VM) DefineNative(name string, arity int, code runtime.CallableCode) {
+	nativeFunction := runtime.NewNativeFunction(name, arity, code)
+	vm.builtins[name] = nativeFunction
+	vm.globals[name] = nativeFunction
+}
+
+// SetGlobal defines a global variable or overwrites its value
+func (vm *VM) SetGlobal(name string, value ast.LoxValue) {
+	vm.globals[name] = value
+}
+
+// GetGlobal returns the value of a global variable
+func (vm *VM) GetGlobal(name string) (ast.LoxValue, bool) {
+	value, ok := vm.globals[name]
+	return value, ok
+}
+
+// GlobalNames returns the sorted names of the global variables, the native functions included
+func (vm *VM) GlobalNames() []string {
+	names := make([]string, 0, len(vm.globals))
+	for name := range vm.globals {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	return names
+}
+
+// Call calls a Lox value from a native function, it runs until the callee returns
+func (vm *VM) Call(callee ast.LoxValue, arguments []ast.LoxValue) ast.LoxValue {
+	exitDepth := len(vm.frames)
+	vm.push(callee)
+	for _, argument := range arguments {
+		vm.push(argument)
+	}
+
+	vm.callValue(callee, len(arguments), vm.frames[exitDepth-1].callSite)
+	if len(vm.frames) > exitDepth {
+		vm.run(exitDepth)
+	}
+
+	return vm.pop()
+}
+
+// Stdout returns where the print statements write
+func (vm *VM) Stdout() io.Writer {
+	return vm.OutputStream
+}
+
+// Stdin returns where the scripts read their input
+func (vm *VM) Stdin() io.Reader {
+	return vm.InputStream
+}
+
+// run executes the instructions until the call frames are popped down to exitDepth.
+// An error caught by a try statement resumes the execution at its handler.
+func (vm *VM) run(exitDepth int) {
+	for !vm.execute(exitDepth) {
+	}
+}
+
+// execute runs the dispatch loop, it returns false if it was stopped by a caught error
+func (vm *VM) execute(exitDepth int) (done bool) {
+	defer func() {
+		if r := recover(); r != nil {
+			err, ok := r.(loxerror.LoxError)
+			if !ok || !vm.catch(err, exitDepth) {
+				panic(r)
+			}
+			done = false
+		}
+	}()
+
+	vm.dispatch(exitDepth)
+
+	return true
+}
+
+// dispatch executes the instructions until the call frames are popped down to exitDepth
+//
+//nolint:gocognit,gocyclo,maintidx // the dispatch loop is kept in one piece to stay fast
+func (vm *VM) dispatch(exitDepth int) {
+	frame := &vm.frames[len(vm.frames)-1]
+	for {
+		vm.steps++
+		chunk := &frame.closure.function.chunk
+		frame.instruction = frame.ip
+		instruction := OpCode(chunk.Code[frame.ip])
+		frame.ip++
+
+		switch instruction {
+		case OpConstant:
+			vm.push(chunk.Constants[vm.readByte(frame)])
+		case OpNil:
+			vm.push(ast.NewNilValue())
+		case OpTrue:
+			vm.push(ast.True)
+		case OpFalse:
+			vm.push(ast.False)
+		case OpPop:
+			vm.pop()
+		case OpGetLocal:
+			vm.push(vm.stack[frame.base+int(vm.readByte(frame))])
+		case OpSetLocal:
+			vm.stack[frame.base+int(vm.readByte(frame))] = vm.peek(0)
+		case OpGetGlobal:
+			name := vm.readString(frame)
+			value, ok := frame.closure.globals[name]
+			if !ok {
+				panic(runtime.NewUndefinedVariable(vm.span(frame), name))
+			}
+			vm.push(value)
+		case OpDefineGlobal:
+			frame.closure.globals[vm.readString(frame)] = vm.pop()
+		case OpSetGlobal:
+			name := vm.readString(frame)
+			if _, ok := frame.closure.globals[name]; !ok {
+				panic(runtime.NewUndefinedVariable(vm.span(frame), name))
+			}
+			frame.closure.globals[name] = vm.peek(0)
+		case OpGetUpvalue:
+			vm.push(vm.readUpvalue(frame.closure.upvalues[vm.readByte(frame)]))
+		case OpSetUpvalue:
+			vm.writeUpvalue(frame.closure.upvalues[vm.readByte(frame)], vm.peek(0))
+		case OpGetProperty:
+			name := vm.readString(frame)
+			vm.push(vm.getProperty(vm.pop(), name, vm.span(frame)))
+			frame = &vm.frames[len(vm.frames)-1]
+		case OpSetProperty:
+			name := vm.readString(frame)
+			value := vm.pop()
+			vm.setProperty(vm.pop(), name, value, vm.span(frame))
+			vm.push(value)
+		case OpGetSuper:
+			name := vm.readString(frame)
+			superclass := vm.pop().(*Class)
+			method := vm.findMethod(superclass, name, "super", vm.span(frame))
+			vm.push(vm.methodProperty(vm.pop(), method, vm.span(frame)))
+			frame = &vm.frames[len(vm.frames)-1]
+		case OpEqual:
+			if vm.overloadsOperator() && vm.callSpecialMethod(binaryOperators[instruction].method, 1, vm.span(frame)) {
+				frame = &vm.frames[len(vm.frames)-1]
+				break
+			}
+			rhs := vm.pop()
+			lhs := vm.pop()
+			vm.push(ast.NewBooleanValue(lhs.Equals(rhs)))
+		case OpGreater, OpGreaterEqual, OpLess, OpLessEqual, OpSubtract, OpMultiply, OpDivide:
+			if vm.overloadsOperator() && vm.callSpecialMethod(binaryOperators[instruction].method, 1, vm.span(frame)) {
+				frame = &vm.frames[len(vm.frames)-1]
+				break
+			}
+			vm.binaryOperation(instruction, vm.span(frame))
+		case OpAdd:
+			if vm.overloadsOperator() && vm.callSpecialMethod(binaryOperators[instruction].method, 1, vm.span(frame)) {
+				frame = &vm.frames[len(vm.frames)-1]
+				break
+			}
+			rhs := vm.pop()
+			lhs := vm.pop()
+			switch {
+			case lhs.Kind() == ast.Number && rhs.Kind() == ast.Number:
+				vm.push(ast.NewNumberValue(lhs.(*ast.NumberValue).Value + rhs.(*ast.NumberValue).Value))
+			case lhs.Kind() == ast.String && rhs.Kind() == ast.String:
+				vm.push(ast.NewStringValue(lhs.(*ast.StringValue).Value + rhs.(*ast.StringValue).Value))
+			default:
+				panic(runtime.NewUnsupportedBinaryOperation(vm.span(frame),
+					"+",
+					ast.KindString[lhs.Kind()],
+					ast.KindString[rhs.Kind()]))
+			}
+		case OpNot:
+			vm.push(ast.NewBooleanValue(!vm.pop().IsTruthy()))
+		case OpNegate:
+			if vm.callSpecialMethod(runtime.NegateMethod, 0, vm.span(frame)) {
+				frame = &vm.frames[len(vm.frames)-1]
+				break
+			}
+			number, ok := vm.peek(0).(*ast.NumberValue)
+			if !ok {
+				panic(runtime.NewUnsupportedUnaryOperation(vm.span(frame), "-", ast.KindString[vm.peek(0).Kind()]))
+			}
+			vm.stack[len(vm.stack)-1] = ast.NewNumberValue(-number.Value)
+		case OpStringify:
+			vm.stringify(vm.span(frame))
+			frame = &vm.frames[len(vm.frames)-1]
+		case OpPrint:
+			vm.stringify(vm.span(frame))
+			frame = &vm.frames[len(vm.frames)-1]
+			fmt.Fprintf(vm.OutputStream, "%s\n", vm.pop().String())
+		case OpJump:
+			offset := vm.readShort(frame)
+			frame.ip += offset
+		case OpJumpIfFalse:
+			offset := vm.readShort(frame)
+			if !vm.peek(0).IsTruthy() {
+				frame.ip += offset
+			}
+		case OpIterator:
+			vm.iterator(vm.span(frame))
+			frame = &vm.frames[len(vm.frames)-1]
+		case OpForIter:
+			offset := vm.readShort(frame)
+			value, ok := vm.peek(1).(*runtime.LoxIterator).Next()
+			frame = &vm.frames[len(vm.frames)-1]
+			if ok {
+				vm.stack[len(vm.stack)-1] = value
+			} else {
+				frame.ip += offset
+			}
+		case OpLoop:
+			offset := vm.readShort(frame)
+			vm.checkLimits(vm.span(frame))
+			frame.ip -= offset
+		case OpCall:
+			argCount := int(vm.readByte(frame))
+			vm.callValue(vm.peek(argCount), argCount, vm.span(frame))
+			frame = &vm.frames[len(vm.frames)-1]
+		case OpInvoke:
+			name := vm.readString(frame)
+			argCount := int(vm.readByte(frame))
+			vm.invoke(name, argCount, frame)
+			frame = &vm.frames[len(vm.frames)-1]
+		case OpSuperInvoke:
+			name := vm.readString(frame)
+			argCount := int(vm.readByte(frame))
+			superclass := vm.pop().(*Class)
+			method := vm.findMethod(superclass, name, "super", vm.nameSpan(frame))
+			vm.callMethod(method, argCount, vm.span(frame))
+			frame = &vm.frames[len(vm.frames)-1]
+		case OpClosure:
+			function := chunk.Constants[vm.readByte(frame)].(*Function)
+			closure := newClosure(function, frame.closure.globals)
+			for index := range closure.upvalues {
+				isLocal := vm.readByte(frame)
+				slot := int(vm.readByte(frame))
+				if isLocal == 1 {
+					closure.upvalues[index] = vm.captureUpvalue(frame.base + slot)
+				} else {
+					closure.upvalues[index] = frame.closure.upvalues[slot]
+				}
+			}
+			vm.push(closure)
+		case OpCloseUpvalue:
+			vm.closeUpvalues(len(vm.stack) - 1)
+			vm.pop()
+		case OpReturn:
+			result := vm.pop()
+			vm.closeUpvalues(frame.base)
+			vm.stack = vm.stack[:frame.base]
+			vm.frames = vm.frames[:len(vm.frames)-1]
+			vm.push(result)
+			if len(vm.frames) == exitDepth {
+				return
+			}
+
+			frame = &vm.frames[len(vm.frames)-1]
+		case OpClass:
+			vm.push(newClass(vm.readString(frame)))
+		case OpInherit:
+			superclass, ok := vm.peek(1).(*Class)
+			if !ok {
+				panic(runtime.NewInvalidInheritance(vm.span(frame), "Superclass must be a class"))
+			}
+			subclass := vm.peek(0).(*Class)
+			for name, method := range superclass.methods {
+				subclass.methods[name] = method
+			}
+			for name, method := range superclass.classMethods {
+				subclass.classMethods[name] = method
+			}
+			subclass.superclass = superclass
+			vm.pop()
+		case OpMethod:
+			name := vm.readString(frame)
+			method := vm.peek(0).(*Closure)
+			class := vm.peek(1).(*Class)
+			class.methods[name] = method
+			vm.pop()
+		case OpClassMethod:
+			name := vm.readString(frame)
+			method := vm.peek(0).(*Closure)
+			class := vm.peek(1).(*Class)
+			class.classMethods[name] = method
+			vm.pop()
+		case OpClassField:
+			name := vm.readString(frame)
+			value := vm.pop()
+			vm.pop().(*Class).fields[name] = value
+		case OpList:
+			count := vm.readShort(frame)
+			elements := make([]ast.LoxValue, count)
+			copy(elements, vm.stack[len(vm.stack)-count:])
+			vm.stack = vm.stack[:len(vm.stack)-count]
+			vm.push(runtime.NewLoxList(elements))
+		case OpMap:
+			count := vm.readShort(frame)
+			entries := vm.stack[len(vm.stack)-2*count:]
+			loxMap := runtime.NewLoxMap()
+			for index := 0; index < len(entries); index += 2 {
+				loxMap.SetIndex(entries[index], entries[index+1], vm.span(frame))
+			}
+			vm.stack = vm.stack[:len(vm.stack)-2*count]
+			vm.push(loxMap)
+		case OpGetIndex:
+			index := vm.pop()
+			object := vm.indexable(vm.pop(), vm.span(frame))
+			vm.push(object.GetIndex(index, vm.span(frame)))
+		case OpSetIndex:
+			value := vm.pop()
+			index := vm.pop()
+			object := vm.indexable(vm.pop(), vm.span(frame))
+			object.SetIndex(index, value, vm.span(frame))
+			vm.push(value)
+		case OpThrow:
+			vm.throw(vm.pop(), vm.span(frame))
+		case OpTry, OpTryFinally:
+			offset := vm.readShort(frame)
+			vm.handlers = append(vm.handlers, handler{
+				frameCount: len(vm.frames),
+				ip:         frame.ip + offset,
+				stackDepth: len(vm.stack),
+				finally:    instruction == OpTryFinally,
+			})
+		case OpEndTry:
+			vm.handlers = vm.handlers[:len(vm.handlers)-1]
+		case OpImport:
+			name := vm.readString(frame)
+			vm.push(vm.modules.Import(name, vm.span(frame), vm.ErrorFormatter, vm.evaluateModule))
+			// The module ran on the same frames, they may have been reallocated
+			frame = &vm.frames[len(vm.frames)-1]
+		}
+	}
+}
+
+// evaluateModule compiles and runs the statements of a module in its own globals and returns them
+func (vm *VM) evaluateModule(statements []ast.Statement, errorFormatter loxerror.ErrorFormatter) map[string]ast.LoxValue {
+	compiler := NewCompiler(errorFormatter)
+	runtime.NewResolver(errorFormatter, compiler).ResolveProgram(statements)
+	checker.NewChecker(errorFormatter).CheckProgram(statements)
+	if errorFormatter.HasErrors() {
+		return nil
+	}
+	function := compiler.Compile(statements)
+	if function == nil {
+		return nil
+	}
+
+	globals := make(map[string]ast.LoxValue, len(vm.builtins))
+	for name, value := range vm.builtins {
+		globals[name] = value
+	}
+
+	exitDepth := len(vm.frames)
+	closure := newClosure(function, globals)
+	vm.push(closure)
+	vm.frames = append(vm.frames, callFrame{
+		closure:     closure,
+		name:        "",
+		ip:          0,
+		instruction: 0,
+		base:        len(vm.stack) - 1,
+		callSite:    loxerror.Span{},
+	})
+	vm.run(exitDepth)
+	// The module returns nil
+	vm.pop()
+
+	return globals
+}
+
+func (vm *VM) push(value ast.LoxValue) {
+	vm.stack = append(vm.stack, value)
+}
+
+func (vm *VM) pop() ast.LoxValue {
+	value := vm.stack[len(vm.stack)-1]
+	vm.stack = vm.stack[:len(vm.stack)-1]
+
+	return value
+}
+
+func (vm *VM) peek(distance int) ast.LoxValue {
+	return vm.stack[len(vm.stack)-1-distance]
+}
+
+func (vm *VM) readByte(frame *callFrame) byte {
+	b := frame.closure.function.chunk.Code[frame.ip]
+	frame.ip++
+
+	return b
+}
+
+func (vm *VM) readShort(frame *callFrame) int {
+	code := frame.closure.function.chunk.Code
+	frame.ip += 2
+
+	return int(code[frame.ip-2])<<8 | int(code[frame.ip-1])
+}
+
+func (vm *VM) readString(frame *callFrame) string {
+	constant := frame.closure.function.chunk.Constants[vm.readByte(frame)].(*ast.StringValue)
+	return constant.Value
+}
+
+// span returns the position of the instruction being executed
+func (vm *VM) span(frame *callFrame) loxerror.Span {
+	return frame.closure.function.chunk.Spans[frame.instruction]
+}
+
+// nameSpan returns the position of the property name of an invoke instruction
+func (vm *VM) nameSpan(frame *callFrame) loxerror.Span {
+	return frame.closure.function.chunk.Spans[frame.instruction+1]
+}
+
+// callSpecialMethod calls the special method overloading an operator on the instance below its operands on the stack.
+// The method runs until it returns, its result replaces the instance and the operands.
+// It returns false if the value isn't an instance defining the method.
+func (vm *VM) callSpecialMethod(name string, argCount int, span loxerror.Span) bool {
+	instance, ok := vm.peek(argCount).(*Instance)
+	if !ok {
+		return false
+	}
+	method, ok := instance.class.methods[name]
+	if !ok {
+		return false
+	}
+
+	exitDepth := len(vm.frames)
+	vm.call(method, argCount, span)
+	vm.run(exitDepth)
+
+	return true
+}
+
+// iterator replaces the iterable on top of the stack by its iterator, see runtime.IterMethod for the iteration protocol.
+// The methods of an iterator implemented in Lox run until they return.
+func (vm *VM) iterator(span loxerror.Span) {
+	if iterator, ok := runtime.BuiltinIterator(vm.peek(0)); ok {
+		vm.stack[len(vm.stack)-1] = iterator
+		return
+	}
+	if !vm.callSpecialMethod(runtime.IterMethod, 0, span) {
+		panic(runtime.NewNotIterable(span, ast.KindString[vm.peek(0).Kind()]))
+	}
+	if iterator, ok := runtime.BuiltinIterator(vm.peek(0)); ok {
+		vm.stack[len(vm.stack)-1] = iterator
+		return
+	}
+
+	object := vm.peek(0)
+	if _, ok := object.(*Instance); !ok {
+		if _, ok := object.(runtime.ObjectValue); !ok {
+			panic(runtime.NewInvalidIterator(span, ast.KindString[object.Kind()]))
+		}
+	}
+	vm.stack[len(vm.stack)-1] = runtime.NewLoxIterator(func() (ast.LoxValue, bool) {
+		if vm.getProperty(object, runtime.DoneProperty, span).IsTruthy() {
+			return nil, false
+		}
+		return vm.callNested(vm.getProperty(object, runtime.NextMethod, span), span), true
+	})
+}
+
+// callNested calls a value without arguments and runs it until it returns its result
+func (vm *VM) callNested(callee ast.LoxValue, span loxerror.Span) ast.LoxValue {
+	vm.push(callee)
+	exitDepth := len(vm.frames)
+	vm.callValue(callee, 0, span)
+	// The native functions and the classes without initializer don't push a frame
+	if len(vm.frames) > exitDepth {
+		vm.run(exitDepth)
+	}
+
+	return vm.pop()
+}
+
+// stringify converts the value on top of the stack to a string, the instances defining __str__ are converted by it
+func (vm *VM) stringify(span loxerror.Span) {
+	value := vm.peek(0)
+	if _, ok := value.(*ast.StringValue); ok {
+		return
+	}
+	if !vm.callSpecialMethod(runtime.StringMethod, 0, span) {
+		vm.stack[len(vm.stack)-1] = ast.NewStringValue(value.String())
+		return
+	}
+
+	if _, ok := vm.peek(0).(*ast.StringValue); !ok {
+		panic(runtime.NewInvalidStringConversion(span, value.(*Instance).class.name))
+	}
+}
+
+func (vm *VM) binaryOperation(instruction OpCode, span loxerror.Span) {
+	rhs, rhsOk := vm.peek(0).(*ast.NumberValue)
+	lhs, lhsOk := vm.peek(1).(*ast.NumberValue)
+	if !lhsOk || !rhsOk {
+		panic(runtime.NewUnsupportedBinaryOperation(span,
+			binaryOperators[instruction].lexeme,
+			ast.KindString[vm.peek(1).Kind()],
+			ast.KindString[vm.peek(0).Kind()]))
+	}
+	vm.stack = vm.stack[:len(vm.stack)-2]
+
+	switch instruction {
+	case OpGreater:
+		vm.push(ast.NewBooleanValue(lhs.Value > rhs.Value))
+	case OpGreaterEqual:
+		vm.push(ast.NewBooleanValue(lhs.Value >= rhs.Value))
+	case OpLess:
+		vm.push(ast.NewBooleanValue(lhs.Value < rhs.Value))
+	case OpLessEqual:
+		vm.push(ast.NewBooleanValue(lhs.Value <= rhs.Value))
+	case OpSubtract:
+		vm.push(ast.NewNumberValue(lhs.Value - rhs.Value))
+	case OpMultiply:
+		vm.push(ast.NewNumberValue(lhs.Value * rhs.Value))
+	case OpDivide:
+		vm.push(ast.NewNumberValue(lhs.Value / rhs.Value))
+	}
+}
+
+// binaryOperator is the operator of a binary instruction and the special method overloading it
+type binaryOperator struct {
+	lexeme string
+	method string
+}
+
+// binaryOperators is indexed by the opcodes of the binary instructions
+var binaryOperators = [...]binaryOperator{
+	OpEqual:        {lexeme: "==", method: runtime.BinaryOperatorMethods[lexer.EqualEqual]},
+	OpGreater:      {lexeme: ">", method: runtime.BinaryOperatorMethods[lexer.Greater]},
+	OpGreaterEqual: {lexeme: ">=", method: runtime.BinaryOperatorMethods[lexer.GreaterEqual]},
+	OpLess:         {lexeme: "<", method: runtime.BinaryOperatorMethods[lexer.Less]},
+	OpLessEqual:    {lexeme: "<=", method: runtime.BinaryOperatorMethods[lexer.LessEqual]},
+	OpAdd:          {lexeme: "+", method: runtime.BinaryOperatorMethods[lexer.Plus]},
+	OpSubtract:     {lexeme: "-", method: runtime.BinaryOperatorMethods[lexer.Dash]},
+	OpMultiply:     {lexeme: "*", method: runtime.BinaryOperatorMethods[lexer.Star]},
+	OpDivide:       {lexeme: "/", method: runtime.BinaryOperatorMethods[lexer.Slash]},
+}
+
+// overloadsOperator tells if the left operand of a binary instruction may define a special method for it
+func (vm *VM) overloadsOperator() bool {
+	_, ok := vm.peek(1).(*Instance)
+	return ok
+}
+
+// callValue calls the value below the arguments on the stack
+func (vm *VM) callValue(callee ast.LoxValue, argCount int, span loxerror.Span) {
+	switch callee := callee.(type) {
+	case *Closure:
+		vm.call(callee, argCount, span)
+	case *BoundMethod:
+		vm.stack[len(vm.stack)-argCount-1] = callee.receiver
+		vm.call(callee.method, argCount, span)
+	case *Class:
+		vm.stack[len(vm.stack)-argCount-1] = newInstance(callee)
+		if initializer, ok := callee.methods["init"]; ok {
+			vm.checkArity(callee.name+"::"+callee.name, initializer.function.arity, argCount, span)
+			vm.call(initializer, argCount, span)
+		} else {
+			vm.checkArity(callee.name+"::"+callee.name, 0, argCount, span)
+		}
+	case *runtime.NativeFunction:
+		vm.callNative(callee, argCount, span)
+	default:
+		panic(runtime.NewNotCallable(span))
+	}
+}
+
+// call pushes the frame of a Lox function, it starts running at the next iteration of the dispatch loop
+func (vm *VM) call(closure *Closure, argCount int, span loxerror.Span) {
+	vm.checkArity(closure.function.name, closure.function.arity, argCount, span)
+	vm.checkCallDepth(span)
+	vm.checkLimits(span)
+
+	vm.frames = append(vm.frames, callFrame{
+		closure:     closure,
+		name:        closure.function.qualifiedName(),
+		ip:          0,
+		instruction: 0,
+		base:        len(vm.stack) - argCount - 1,
+		callSite:    span,
+	})
+}
+
+func (vm *VM) callNative(function *runtime.NativeFunction, argCount int, span loxerror.Span) {
+	vm.checkArity(function.Name(), function.Arity(), argCount, span)
+	vm.checkCallDepth(span)
+	vm.checkLimits(span)
+
+	// The frame only shows the native function in the stack traces
+	vm.frames = append(vm.frames, callFrame{
+		closure:     nil,
+		name:        function.Name(),
+		ip:          0,
+		instruction: 0,
+		base:        len(vm.stack) - argCount - 1,
+		callSite:    span,
+	})
+	arguments := make([]ast.LoxValue, argCount)
+	copy(arguments, vm.stack[len(vm.stack)-argCount:])
+	result, err := function.Invoke(vm, arguments)
+	if err != nil {
+		panic(runtime.NewNativeFunctionError(span, function.Name(), err.Error()))
+	}
+	vm.frames = vm.frames[:len(vm.frames)-1]
+
+	vm.stack = vm.stack[:len(vm.stack)-argCount-1]
+	vm.push(result)
+}
+
+func (vm *VM) checkArity(name string, arity int, argCount int, span loxerror.Span) {
+	if arity != argCount {
+		panic(runtime.NewBadArity(span, name, arity, argCount))
+	}
+}
+
+func (vm *VM) checkCallDepth(span loxerror.Span) {
+	// The first frame is the script
+	if vm.MaxCallDepth > 0 && len(vm.frames)-1 >= vm.MaxCallDepth {
+		panic(runtime.NewStackOverflow(span))
+	}
+}
+
+// checkLimits is called on the backward jumps of OpLoop and when a Lox or a native function is called, a program
+// can't run forever without them
+func (vm *VM) checkLimits(span loxerror.Span) {
+	runtime.CheckLimits(vm.context, vm.steps, vm.StepBudget, span)
+}
+
+// invoke calls a method of the receiver below the arguments, a field holding a function can be invoked too
+func (vm *VM) invoke(name string, argCount int, frame *callFrame) {
+	switch receiver := vm.peek(argCount).(type) {
+	case *Instance:
+		if field, ok := receiver.fields[name]; ok {
+			vm.stack[len(vm.stack)-argCount-1] = field
+			vm.callValue(field, argCount, vm.span(frame))
+			return
+		}
+		vm.callMethod(vm.findMethod(receiver.class, name, name, vm.nameSpan(frame)), argCount, vm.span(frame))
+	case *Class:
+		if field, ok := receiver.field(name); ok {
+			vm.stack[len(vm.stack)-argCount-1] = field
+			vm.callValue(field, argCount, vm.span(frame))
+			return
+		}
+		vm.callMethod(vm.findClassMethod(receiver, name, vm.nameSpan(frame)), argCount, vm.span(frame))
+	case runtime.ObjectValue:
+		property := receiver.Get(propertyToken(name, vm.nameSpan(frame)))
+		vm.stack[len(vm.stack)-argCount-1] = property
+		vm.callValue(property, argCount, vm.span(frame))
+	default:
+		panic(runtime.NewInvalidSetGet(vm.nameSpan(frame)))
+	}
+}
+
+// findMethod returns the method of a class, reportedName is the property reported missing
+func (vm *VM) findMethod(class *Class, name string, reportedName string, span loxerror.Span) *Closure {
+	method, ok := class.methods[name]
+	if !ok {
+		panic(runtime.NewUndefinedProperty(span, reportedName, class.name))
+	}
+
+	return method
+}
+
+// findClassMethod returns a class method of a class
+func (vm *VM) findClassMethod(class *Class, name string, span loxerror.Span) *Closure {
+	method, ok := class.classMethods[name]
+	if !ok {
+		panic(runtime.NewUndefinedClassProperty(span, name, class.name, class.hasClassMembers()))
+	}
+
+	return method
+}
+
+// callMethod calls a method on the receiver below the arguments, a getter is called and its result is called
+func (vm *VM) callMethod(method *Closure, argCount int, span loxerror.Span) {
+	if !method.function.isGetter {
+		vm.call(method, argCount, span)
+		return
+	}
+
+	receiver := len(vm.stack) - argCount - 1
+	property := vm.methodProperty(vm.stack[receiver], method, span)
+	vm.stack[receiver] = property
+	vm.callValue(property, argCount, span)
+}
+
+// methodProperty returns the value of a method accessed as a property: the method bound to the receiver,
+// or the value computed by a getter. The getter runs until it returns.
+func (vm *VM) methodProperty(receiver ast.LoxValue, method *Closure, span loxerror.Span) ast.LoxValue {
+	if !method.function.isGetter {
+		return &BoundMethod{receiver: receiver, method: method}
+	}
+
+	vm.push(receiver)
+	exitDepth := len(vm.frames)
+	vm.call(method, 0, span)
+	vm.run(exitDepth)
+
+	return vm.pop()
+}
+
+func (vm *VM) getProperty(object ast.LoxValue, name string, span loxerror.Span) ast.LoxValue {
+	switch object := object.(type) {
+	case *Instance:
+		if value, ok := object.fields[name]; ok {
+			return value
+		}
+		return vm.methodProperty(object, vm.findMethod(object.class, name, name, span), span)
+	case *Class:
+		if value, ok := object.field(name); ok {
+			return value
+		}
+		return vm.methodProperty(object, vm.findClassMethod(object, name, span), span)
+	case runtime.ObjectValue:
+		return object.Get(propertyToken(name, span))
+	default:
+		panic(runtime.NewInvalidSetGet(span))
+	}
+}
+
+func (vm *VM) setProperty(object ast.LoxValue, name string, value ast.LoxValue, span loxerror.Span) {
+	switch object := object.(type) {
+	case *Instance:
+		object.fields[name] = value
+	case *Class:
+		// Only the declared class fields can be assigned, a subclass gets its own copy of an inherited field
+		if _, ok := object.field(name); !ok {
+			panic(runtime.NewUndefinedClassProperty(span, name, object.name, object.hasClassMembers()))
+		}
+		object.fields[name] = value
+	case runtime.ObjectValue:
+		object.Set(propertyToken(name, span), value)
+	default:
+		panic(runtime.NewInvalidSetGet(span))
+	}
+}
+
+func (vm *VM) indexable(object ast.LoxValue, span loxerror.Span) runtime.IndexableValue {
+	indexable, ok := object.(runtime.IndexableValue)
+	if !ok {
+		panic(runtime.NewNotIndexable(span, ast.KindString[object.Kind()]))
+	}
+
+	return indexable
+}
+
+// propertyToken rebuilds the token of a property name for the objects defined by the runtime package
+func propertyToken(name string, span loxerror.Span) lexer.Token {
+	return *lexer.NewToken(lexer.Identifier, name, nil, span)
+}
+
+func (vm *VM) readUpvalue(upvalue *upvalue) ast.LoxValue {
+	if upvalue.open {
+		return vm.stack[upvalue.slot]
+	}
+
+	return upvalue.closed
+}
+
+func (vm *VM) writeUpvalue(upvalue *upvalue, value ast.LoxValue) {
+	if upvalue.open {
+		vm.stack[upvalue.slot] = value
+		return
+	}
+
+	upvalue.closed = value
+}
+
+// captureUpvalue returns the open upvalue of a stack slot, the closures capturing the same variable share it
+func (vm *VM) captureUpvalue(slot int) *upvalue {
+	var previous *upvalue
+	current := vm.openUpvalues
+	for current != nil && current.slot > slot {
+		previous = current
+		current = current.next
+	}
+	if current != nil && current.slot == slot {
+		return current
+	}
+
+	created := &upvalue{slot: slot, open: true, closed: nil, next: current}
+	if previous == nil {
+		vm.openUpvalues = created
+	} else {
+		previous.next = created
+	}
+
+	return created
+}
+
+// closeUpvalues moves the variables leaving the stack from the given slot in their upvalues
+func (vm *VM) closeUpvalues(last int) {
+	for vm.openUpvalues != nil && vm.openUpvalues.slot >= last {
+		upvalue := vm.openUpvalues
+		upvalue.closed = vm.stack[upvalue.slot]
+		upvalue.open = false
+		vm.openUpvalues = upvalue.next
+	}
+}
+
+// stackTrace returns the current call stack, most recent call first
+func (vm *VM) stackTrace() []loxerror.StackFrame {
+	stackTrace := make([]loxerror.StackFrame, 0)
+	for index := len(vm.frames) - 1; index >= 0; index-- {
+		frame := vm.frames[index]
+		// The frames of the script and of the modules aren't calls
+		if frame.name == "" {
+			continue
+		}
+		stackTrace = loxerror.PushFrame(stackTrace, frame.name, frame.callSite.Start.Line)
+	}
+
+	return stackTrace
+}